@@ -1,19 +1,35 @@
 package main
 
 import (
+	"bean/internal/buildinfo"
+	"bean/internal/challenge"
 	"bean/internal/configuration"
 	"bean/internal/dataset"
+	"bean/internal/metrics"
+	"bean/internal/notification"
+	"bean/internal/obfuscation"
+	"bean/internal/ratelimit"
 	"bean/internal/score"
+	"bean/internal/score/model"
 	"bean/internal/score/rule"
 	"bean/internal/score/scorer"
 	"bean/internal/server"
+	"bean/internal/session"
 	"bean/internal/trace"
+	"bean/public"
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"flag"
+	"fmt"
 	"log/slog"
+	"net/netip"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -46,30 +62,288 @@ func prepareLogger(level string) {
 
 // prepareScorers creates a list of scorers
 // Accepts list of scorers configurations.
-// Returns list of scorers.
-func prepareScorers(sc []configuration.ScorerConfig) []score.TracesScorer {
-	scorers := []score.TracesScorer{}
+// Returns list of composite scorer members.
+func prepareScorers(sc []configuration.ScorerConfig) []scorer.Member {
+	scorers := []scorer.Member{}
 	for i := range sc {
+		var s score.TracesScorer
 		switch sc[i].Type {
 		case configuration.ScorerTypeML:
-			mlScorer := scorer.NewClientInputScorer(sc[i].Url, time.Second, sc[i].Model)
-			scorers = append(scorers, mlScorer)
+			mlScorer := scorer.NewClientInputScorer(sc[i].Url, sc[i].Timeout, sc[i].Model).
+				WithRetries(sc[i].Retries, sc[i].RetryBackoff)
+			if sc[i].Breaker.Failures > 0 {
+				mlScorer.WithCircuitBreaker(scorer.NewCircuitBreaker(sc[i].Breaker.Failures, sc[i].Breaker.Cooldown))
+			}
+			s = mlScorer
 		case configuration.ScorerTypeRules:
 			rules, err := rule.LoadFromFile(sc[i].Rules, trace.NewMovementTraceEnv)
 			if err != nil {
 				slog.Error("Unable to load rules", "file", sc[i].Rules, "error", err)
 				os.Exit(1)
 			}
-			rulesScorer := scorer.NewRulesScorer(rules, -1.0, 1.0)
-			scorers = append(scorers, rulesScorer)
+			s = scorer.NewRulesScorer(rules, -1.0, 1.0)
+		case configuration.ScorerTypeModel:
+			m, key, err := model.LoadFromFile(sc[i].Model)
+			if err != nil {
+				slog.Error("Unable to load model", "file", sc[i].Model, "error", err)
+				os.Exit(1)
+			}
+			s = scorer.NewModelScorer(m, key)
 		default:
 			slog.Error("Unknown scorer", "scorer", sc[i].Type)
 			os.Exit(1)
 		}
+
+		scorers = append(scorers, scorer.Member{
+			Name:        sc[i].Name,
+			Scorer:      s,
+			OnError:     scorer.ErrorPolicy(sc[i].OnError),
+			Fallback:    sc[i].Fallback,
+			Deadline:    sc[i].Deadline,
+			Weight:      sc[i].Weight,
+			Incremental: sc[i].Incremental,
+		})
 	}
 	return scorers
 }
 
+// prepareAggregator creates score aggregation strategies
+// Accepts aggregation configuration.
+// Returns aggregator for the composite scorer.
+func prepareAggregator(ac configuration.AggregationConfig) scorer.Aggregator {
+	aggregator := scorer.Aggregator{
+		Default: scorer.Aggregation(ac.Default),
+		Keys:    make(map[string]scorer.Aggregation),
+	}
+	for key, strategy := range ac.Keys {
+		aggregator.Keys[key] = scorer.Aggregation(strategy)
+	}
+	return aggregator
+}
+
+// prepareVerdicts creates verdict bands
+// Accepts verdict configuration.
+// Returns verdicts for the composite scorer.
+func prepareVerdicts(vc configuration.VerdictConfig) score.Verdicts {
+	verdicts := score.Verdicts{Key: vc.Key}
+	for _, band := range vc.Bands {
+		verdicts.Bands = append(verdicts.Bands, score.Band{Name: band.Name, Min: band.Min})
+	}
+	return verdicts
+}
+
+// prepareTLSVersion converts the configured minimum TLS version
+// Accepts version string: 1.2 or 1.3.
+// Returns the version constant of crypto/tls.
+func prepareTLSVersion(version string) uint16 {
+	if version == configuration.TLSVersion13 {
+		return tls.VersionTLS13
+	}
+	return tls.VersionTLS12
+}
+
+// prepareIngest creates the client address resolver, the rate limiters and the session issuer of the trace ingestion
+// Accepts server configuration.
+// Returns ingest options, Serve must be started for each non-nil limiter and the session issuer.
+func prepareIngest(sc configuration.ServerConfig) server.IngestOptions {
+	trusted := []netip.Prefix{}
+	for _, proxy := range sc.TrustedProxies {
+		// Validated by the configuration
+		prefix, _ := configuration.ParsePrefix(proxy)
+		trusted = append(trusted, prefix)
+	}
+
+	ingest := server.IngestOptions{
+		ClientIP:      server.NewClientIPResolver(trusted),
+		RejectReplays: sc.Replay == configuration.ReplayReject,
+		Events: server.EventsOptions{
+			MaxEvents: sc.RawEvents.MaxEvents,
+			Keep:      sc.RawEvents.Keep,
+		},
+	}
+	if sc.RateLimit.IP.Rate > 0 {
+		ingest.IPLimiter = ratelimit.NewLimiter(sc.RateLimit.IP.Rate, sc.RateLimit.IP.Burst)
+	}
+	if sc.RateLimit.Session.Rate > 0 {
+		ingest.SessionLimiter = ratelimit.NewLimiter(sc.RateLimit.Session.Rate, sc.RateLimit.Session.Burst)
+	}
+	if sc.Sessions.Enabled {
+		ingest.Sessions = session.NewIssuer(sc.Sessions.Secrets, sc.Sessions.TTL, sc.Sessions.Rotate)
+	}
+	return ingest
+}
+
+// prepareCollector creates the served collector script and its obfuscator
+// Accepts server configuration.
+// Returns the customized collector script if its file is configured, the embedded one otherwise,
+// and the obfuscator if obfuscation is enabled; Serve must be started for the obfuscator.
+func prepareCollector(sc configuration.ServerConfig) (*server.Collector, *obfuscation.Obfuscator) {
+	script := public.Collector
+	if sc.Collector != "" {
+		var err error
+		script, err = os.ReadFile(sc.Collector)
+		if err != nil {
+			slog.Error("Unable to load collector script", "file", sc.Collector, "error", err)
+			os.Exit(1)
+		}
+	}
+	if !sc.Obfuscation.Enabled {
+		return server.NewCollector(script), nil
+	}
+
+	obfuscator, err := obfuscation.NewObfuscator(script, sc.Obfuscation.Secret, sc.Obfuscation.Rotate)
+	if err != nil {
+		slog.Error("Unable to obfuscate collector script", "file", sc.Collector, "error", err)
+		os.Exit(1)
+	}
+	collector := server.NewCollector(obfuscator.Script())
+	if retention := obfuscator.Retention(); retention > 0 {
+		// Browsers must not keep a variant whose mapping is no longer decoded
+		collector.WithMaxAge(retention)
+	}
+	obfuscator.OnRotate(collector.Update)
+	return collector, obfuscator
+}
+
+// prepareChallenges creates the proof-of-work challenges of suspicious sessions
+// Accepts analysis configuration.
+// Returns challenge options, without challenges if they are disabled.
+func prepareChallenges(ac configuration.AnalysisConfig) server.ChallengeOptions {
+	cc := ac.Challenge
+	if !cc.Enabled() {
+		return server.ChallengeOptions{}
+	}
+	return server.ChallengeOptions{
+		Challenges: challenge.NewChallenges(cc.MinBits, cc.MaxBits, cc.TTL),
+		Verdict:    cc.Verdict,
+		Key:        ac.Verdict.Key,
+		Adjustment: cc.Adjustment,
+	}
+}
+
+// prepareWebhooks creates notified webhooks
+// Accepts notifications configuration.
+// Returns list of webhooks, Serve must be started for each of them.
+func prepareWebhooks(nc configuration.NotificationsConfig) []*notification.Webhook {
+	if nc.Spool != "" {
+		if err := os.MkdirAll(nc.Spool, 0o700); err != nil {
+			slog.Error("Unable to create spool directory", "directory", nc.Spool, "error", err)
+			os.Exit(1)
+		}
+	}
+
+	webhooks := []*notification.Webhook{}
+	for _, wc := range nc.Webhooks {
+		condition := notification.Condition{Verdicts: wc.Verdicts, MinScore: wc.MinScore}
+		webhook := notification.NewWebhook(wc.Name, wc.Url, wc.Secret, condition, wc.Timeout, nc.QueueSize).
+			WithRetries(wc.Retries, wc.RetryBackoff)
+		if nc.Spool != "" {
+			file := filepath.Join(nc.Spool, wc.Name+".jsonl")
+			if _, err := webhook.WithSpool(file, nc.SpoolRetry); err != nil {
+				slog.Error("Unable to open webhook spool", "file", file, "error", err)
+				os.Exit(1)
+			}
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks
+}
+
+// rulesChecksums calculates the checksums of the rule files
+// Accepts the scorers configuration.
+// Returns the SHA-256 checksum of the rules file of each rules scorer.
+func rulesChecksums(sc []configuration.ScorerConfig) ([]server.FileChecksum, error) {
+	checksums := []server.FileChecksum{}
+	for i := range sc {
+		if sc[i].Type != configuration.ScorerTypeRules {
+			continue
+		}
+		content, err := os.ReadFile(sc[i].Rules)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(content)
+		checksums = append(checksums, server.FileChecksum{File: sc[i].Rules, Sha256: hex.EncodeToString(sum[:])})
+	}
+	return checksums, nil
+}
+
+// prepareHealth creates the service health
+// Accepts the configuration and the composite scorer members.
+// Returns health reporting the version, rule file checksums and configuration hash,
+// with a readiness check for each ML scorer. Only the scorers whose failure fails the scoring
+// gate the readiness; the others are reported.
+func prepareHealth(config *configuration.AppConfig, members []scorer.Member) *server.Health {
+	rules, err := rulesChecksums(config.Analysis.Scorers)
+	if err != nil {
+		slog.Error("Unable to read rules", "error", err)
+		os.Exit(1)
+	}
+	version := server.VersionInfo{Info: buildinfo.Get(), Rules: rules, ConfigSha256: config.Hash()}
+
+	checks := []server.ReadinessCheck{}
+	for _, m := range members {
+		if mlScorer, ok := m.Scorer.(*scorer.ClientInputScorer); ok {
+			checks = append(checks, server.ReadinessCheck{
+				Name:     m.Name,
+				Check:    mlScorer.Ping,
+				Optional: m.OnError != "" && m.OnError != scorer.ErrorPolicyFail,
+			})
+		}
+	}
+
+	return server.NewHealth(version, checks)
+}
+
+// prepareRulesReload creates the rules reload of the admin API
+// Accepts the configuration, the composite scorer with its members and the service health.
+// Returns a function loading all rule files again. The rules are replaced only if all files
+// are loaded successfully; then the cached scores are dropped and the reported checksums updated.
+func prepareRulesReload(
+	config *configuration.AppConfig,
+	members []scorer.Member,
+	compositeScorer *scorer.CompositeScorer,
+	health *server.Health,
+) func() ([]server.FileChecksum, error) {
+	var mu sync.Mutex
+	return func() ([]server.FileChecksum, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		sc := config.Analysis.Scorers
+		loaded := make(map[int][]rule.Rule)
+		for i := range sc {
+			if sc[i].Type != configuration.ScorerTypeRules {
+				continue
+			}
+			rules, err := rule.LoadFromFile(sc[i].Rules, trace.NewMovementTraceEnv)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", sc[i].Rules, err)
+			}
+			loaded[i] = rules
+		}
+		checksums, err := rulesChecksums(sc)
+		if err != nil {
+			return nil, err
+		}
+
+		scorers := make(map[int]*scorer.RulesScorer, len(loaded))
+		for i := range loaded {
+			rulesScorer, ok := members[i].Scorer.(*scorer.RulesScorer)
+			if !ok {
+				return nil, fmt.Errorf("scorer %s: rules can't be reloaded", members[i].Name)
+			}
+			scorers[i] = rulesScorer
+		}
+		for i, rules := range loaded {
+			scorers[i].SetRules(rules)
+		}
+		compositeScorer.ResetCache()
+		health.SetVersion(server.VersionInfo{Info: buildinfo.Get(), Rules: checksums, ConfigSha256: config.Hash()})
+		return checksums, nil
+	}
+}
+
 // On errors during config loading, rules reading, or component initialization,
 // the application exits with code 1.
 func main() {
@@ -93,21 +367,142 @@ func main() {
 
 	tracesRepo := trace.NewTracesRepository(config.Analysis.TracesLength, config.Analysis.TracesTtl)
 	go tracesRepo.Serve()
+	metrics.SessionsActive.Bind(func() float64 { return float64(tracesRepo.Len()) })
 
 	scorers := prepareScorers(config.Analysis.Scorers)
-	compositeScorer := scorer.NewCompositeScorer(scorers, tracesRepo)
+	compositeScorer := scorer.NewCompositeScorer(
+		scorers,
+		tracesRepo,
+		config.Analysis.ScoreBudget,
+		prepareAggregator(config.Analysis.Aggregation),
+		prepareVerdicts(config.Analysis.Verdict),
+	)
+
+	var notifier *notification.Notifier
+	webhooks := prepareWebhooks(config.Notifications)
+	if len(webhooks) > 0 {
+		targets := make([]notification.Target, len(webhooks))
+		for i, webhook := range webhooks {
+			targets[i] = webhook
+			go webhook.Serve()
+		}
+		notifier = notification.NewNotifier(compositeScorer.Score, targets)
+		tracesRepo.OnEvict(notifier.Forget)
+		go notifier.Serve()
+	}
+
+	var auth *server.Authenticator
+	if config.Server.Auth.Enabled() || config.Server.TLS.ClientCa != "" {
+		auth, err = server.NewAuthenticator(config.Server.Auth.Keys, config.Server.Auth.KeysFile, config.Server.Auth.Reload)
+		if err != nil {
+			slog.Error("Unable to load API keys", "file", config.Server.Auth.KeysFile, "error", err)
+			os.Exit(1)
+		}
+		if config.Server.TLS.ClientCa != "" {
+			auth.WithClientCertificates()
+		}
+		go auth.Serve()
+	}
+
+	ingest := prepareIngest(config.Server)
+	for _, limiter := range []*ratelimit.Limiter{ingest.IPLimiter, ingest.SessionLimiter} {
+		if limiter != nil {
+			go limiter.Serve()
+		}
+	}
+	if ingest.Sessions != nil {
+		go ingest.Sessions.Serve()
+	}
+	collector, obfuscator := prepareCollector(config.Server)
+	if obfuscator != nil {
+		ingest.Obfuscator = obfuscator
+		go obfuscator.Serve()
+	}
+
+	health := prepareHealth(config, scorers)
+	admin := server.NewAdminRouter(metrics.Default.Handler(), health).WithAuth(auth)
+	var adminAuth *server.Authenticator
+	if config.Admin.Auth.Enabled() {
+		adminAuth, err = server.NewAuthenticator(config.Admin.Auth.Keys, config.Admin.Auth.KeysFile, config.Admin.Auth.Reload)
+		if err != nil {
+			slog.Error("Unable to load admin API keys", "file", config.Admin.Auth.KeysFile, "error", err)
+			os.Exit(1)
+		}
+		go adminAuth.Serve()
+		admin.WithApi(server.NewAdminApiRouter(
+			adminAuth,
+			tracesRepo,
+			compositeScorer,
+			prepareRulesReload(config, scorers, compositeScorer, health),
+			datasetRepo,
+			notifier,
+		))
+	}
+	var adminSrv *server.Server
+	if config.Admin.Address != "" {
+		adminSrv = server.NewAdminServer(config.Admin.Address, admin).WithHealth(health)
+		admin = nil
+	} else if auth == nil {
+		slog.Warn("Metrics and version are not served: set admin.address or server.auth to enable them")
+	}
 
 	srv := server.NewServer(
 		config.Server.Address,
 		config.Server.Static,
+		collector,
 		config.Analysis.Token,
 		tracesRepo,
 		compositeScorer,
 		datasetRepo,
-	)
+		notifier,
+		server.StreamOptions{
+			Delta:     config.Server.Stream.Delta,
+			Heartbeat: config.Server.Stream.Heartbeat,
+		},
+		server.Limits{
+			ReadTimeout:    config.Server.ReadTimeout,
+			WriteTimeout:   config.Server.WriteTimeout,
+			IdleTimeout:    config.Server.IdleTimeout,
+			MaxHeaderBytes: config.Server.MaxHeaderBytes,
+			MaxTraceBytes:  config.Server.MaxTraceBytes,
+		},
+		ingest,
+		prepareChallenges(config.Analysis),
+		auth,
+		admin,
+	).WithHealth(health)
+
+	var certs *server.CertReloader
+	var redirectSrv *server.Server
+	if config.Server.TLS.Enabled() {
+		tc := config.Server.TLS
+		certs, err = server.NewCertReloader(tc.Cert, tc.Key, tc.Reload)
+		if err != nil {
+			slog.Error("Unable to load TLS certificate", "cert", tc.Cert, "error", err)
+			os.Exit(1)
+		}
+		go certs.Serve()
+
+		if _, err = srv.WithTLS(certs, prepareTLSVersion(tc.MinVersion), tc.ClientCa); err != nil {
+			slog.Error("Unable to load client CA", "file", tc.ClientCa, "error", err)
+			os.Exit(1)
+		}
+		if tc.Redirect != "" {
+			redirectSrv = server.NewRedirectServer(tc.Redirect, config.Server.Address)
+		}
+	}
 
 	go srv.ListenAndServe()
 	slog.Info("Server is listening " + config.Server.Address)
+	if redirectSrv != nil {
+		go redirectSrv.ListenAndServe()
+		slog.Info("Redirect server is listening " + config.Server.TLS.Redirect)
+	}
+	if adminSrv != nil {
+		go adminSrv.ListenAndServe()
+		slog.Info("Admin server is listening " + config.Admin.Address)
+	}
+	health.MarkReady()
 
 	<-appCtx.Done()
 
@@ -118,8 +513,46 @@ func main() {
 	if err != nil {
 		slog.Error("Server shutdown", "error", err)
 	}
+	if adminSrv != nil {
+		if err = adminSrv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Admin server shutdown", "error", err)
+		}
+	}
+	if redirectSrv != nil {
+		if err = redirectSrv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Redirect server shutdown", "error", err)
+		}
+	}
 
 	slog.Info("Server stopped")
+	if notifier != nil {
+		notifier.Stop()
+	}
+	for _, webhook := range webhooks {
+		webhook.Stop()
+	}
+	if auth != nil {
+		auth.Stop()
+	}
+	if adminAuth != nil {
+		adminAuth.Stop()
+	}
+	if certs != nil {
+		certs.Stop()
+	}
+	for _, limiter := range []*ratelimit.Limiter{ingest.IPLimiter, ingest.SessionLimiter} {
+		if limiter != nil {
+			limiter.Stop()
+		}
+	}
+	if ingest.Sessions != nil {
+		ingest.Sessions.Stop()
+	}
+	if obfuscator != nil {
+		obfuscator.Stop()
+	}
 	tracesRepo.Stop()
-	datasetRepo.Close()
+	if datasetRepo != nil {
+		datasetRepo.Close()
+	}
 }
@@ -0,0 +1,50 @@
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Build parameters set by the linker:
+//
+// go build -ldflags "-X bean/internal/buildinfo.Version=1.0.0 -X bean/internal/buildinfo.Commit=$(git rev-parse HEAD)"
+var (
+	// Version — release version of the build.
+	Version = "dev"
+	// Commit — git commit of the build.
+	Commit = ""
+)
+
+// Info describes the running build.
+type Info struct {
+	// Version — release version, "dev" if not set.
+	Version string `json:"version"`
+	// Commit — git commit, empty if unknown.
+	Commit string `json:"commit"`
+	// GoVersion — version of the Go toolchain.
+	GoVersion string `json:"go"`
+}
+
+// Get returns the build information. Values not set by the linker are taken
+// from the module and VCS information embedded by the Go toolchain, if available.
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, GoVersion: runtime.Version()}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	if info.Version == "dev" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		info.Version = bi.Main.Version
+	}
+	if info.Commit == "" {
+		for _, setting := range bi.Settings {
+			if setting.Key == "vcs.revision" {
+				info.Commit = setting.Value
+			}
+		}
+	}
+
+	return info
+}
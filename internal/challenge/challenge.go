@@ -0,0 +1,198 @@
+package challenge
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// difficultyBuckets is the number of score ranges with distinct difficulties.
+const difficultyBuckets = 4
+
+var (
+	// ErrNotFound is returned when the session has no pending challenge.
+	ErrNotFound = errors.New("challenge not found")
+	// ErrExpired is returned when the challenge has expired before it was solved.
+	ErrExpired = errors.New("challenge expired")
+	// ErrInvalidSolution is returned when the nonce does not solve the challenge.
+	ErrInvalidSolution = errors.New("invalid challenge solution")
+)
+
+// Challenge is a hashcash-style proof-of-work puzzle: find a nonce such that
+// SHA-256 of "<seed>:<nonce>" starts with at least Difficulty zero bits.
+type Challenge struct {
+	// Seed — random hex-encoded seed of the puzzle.
+	Seed string `json:"seed"`
+	// Difficulty — required number of leading zero bits of the hash.
+	Difficulty int `json:"difficulty"`
+	// ExpiresAt — time the challenge must be solved by.
+	ExpiresAt time.Time `json:"expiresAt"`
+
+	issuedAt time.Time // time the challenge was issued
+	required bool      // the challenge is required by the verdict of the session
+}
+
+// Solution describes a solved challenge.
+type Solution struct {
+	// Difficulty — number of leading zero bits of the solved challenge.
+	Difficulty int
+	// SolveTime — time between issuing the challenge and receiving the solution.
+	SolveTime time.Duration
+	// Required — the challenge was required by the verdict of the session.
+	// Solutions of optional challenges are not returned by Challenges.Solution.
+	Required bool
+}
+
+// Challenges issues proof-of-work challenges to sessions and verifies their solutions.
+// A challenge is required for sessions with the challenged verdict; its difficulty grows with
+// the session score from the minimum to the maximum in coarse steps, so sessions that look more
+// automated spend more work. Other sessions get optional challenges of the minimum difficulty,
+// so the presence of a challenge does not reveal the verdict. A session has at most one pending
+// challenge; requesting a challenge again returns the pending one until it expires, unless
+// an optional challenge is replaced by a required one.
+// A session that solved a required challenge is not challenged again; a session that solved
+// an optional challenge gets only a required one.
+//
+// Challenges is thread-safe.
+type Challenges struct {
+	minBits int                  // difficulty of the score 0
+	maxBits int                  // difficulty of the score 1
+	ttl     time.Duration        // time to solve a challenge
+	pending map[string]Challenge // unsolved challenges by session ID
+	solved  map[string]Solution  // solutions of required challenges by session ID
+	passed  map[string]struct{}  // sessions that solved an optional challenge
+	now     func() time.Time     // clock, replaced in tests
+	mu      sync.Mutex           // mutex to protect access to challenges
+}
+
+// Issue returns the pending challenge of the session or creates a new one. A required challenge
+// has the difficulty of the score, an optional one has the minimum difficulty.
+// Returns false if the session has already solved a required challenge, or an optional one
+// and the challenge is not required.
+func (c *Challenges) Issue(id string, score float32, required bool) (Challenge, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, solved := c.solved[id]; solved {
+		return Challenge{}, false
+	}
+	now := c.now()
+	if pending, found := c.pending[id]; found && now.Before(pending.ExpiresAt) && (pending.required || !required) {
+		return pending, true
+	}
+	if _, passed := c.passed[id]; passed && !required {
+		return Challenge{}, false
+	}
+
+	difficulty := c.minBits
+	if required {
+		difficulty = c.difficulty(score)
+	}
+	seed := make([]byte, 16)
+	rand.Read(seed)
+	challenge := Challenge{
+		Seed:       hex.EncodeToString(seed),
+		Difficulty: difficulty,
+		ExpiresAt:  now.Add(c.ttl),
+		issuedAt:   now,
+		required:   required,
+	}
+	c.pending[id] = challenge
+	return challenge, true
+}
+
+// Verify checks the nonce against the pending challenge of the session.
+// On success, the solution is returned and the challenge is marked as solved.
+// Returns ErrNotFound, ErrExpired or ErrInvalidSolution otherwise.
+func (c *Challenges) Verify(id string, nonce string) (Solution, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	challenge, found := c.pending[id]
+	if !found {
+		return Solution{}, ErrNotFound
+	}
+	now := c.now()
+	if !now.Before(challenge.ExpiresAt) {
+		delete(c.pending, id)
+		return Solution{}, ErrExpired
+	}
+	if LeadingZeroBits(challenge.Seed, nonce) < challenge.Difficulty {
+		return Solution{}, ErrInvalidSolution
+	}
+
+	solution := Solution{Difficulty: challenge.Difficulty, SolveTime: now.Sub(challenge.issuedAt), Required: challenge.required}
+	delete(c.pending, id)
+	if solution.Required {
+		c.solved[id] = solution
+	} else {
+		c.passed[id] = struct{}{}
+	}
+	return solution, nil
+}
+
+// Solution returns the solution of the session, if it has solved a required challenge.
+func (c *Challenges) Solution(id string) (Solution, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	solution, found := c.solved[id]
+	return solution, found
+}
+
+// Forget removes the challenges of a session removed from the traces repository.
+func (c *Challenges) Forget(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.pending, id)
+	delete(c.solved, id)
+	delete(c.passed, id)
+}
+
+// difficulty returns the difficulty of the score. The score is rounded down to one of
+// difficultyBuckets ranges, so the difficulty reveals only a coarse range of the score.
+func (c *Challenges) difficulty(score float32) int {
+	bucket := min(max(int(score*difficultyBuckets), 0), difficultyBuckets-1)
+	step := float64(c.maxBits-c.minBits) / (difficultyBuckets - 1)
+	return c.minBits + int(math.Round(float64(bucket)*step))
+}
+
+// LeadingZeroBits returns the number of leading zero bits of SHA-256 of "<seed>:<nonce>".
+func LeadingZeroBits(seed string, nonce string) int {
+	sum := sha256.Sum256([]byte(seed + ":" + nonce))
+	zeros := 0
+	for _, b := range sum {
+		if b != 0 {
+			return zeros + bits.LeadingZeros8(b)
+		}
+		zeros += 8
+	}
+	return zeros
+}
+
+// NewChallenges creates a new instance of Challenges.
+//
+// Parameters:
+//   - minBits: difficulty of the lowest score, in leading zero bits
+//   - maxBits: difficulty of the highest score, in leading zero bits
+//   - ttl: time to solve a challenge
+//
+// Returns a pointer to the Challenges. Register Forget as an evict handler
+// of the traces repository to remove the challenges of outdated sessions.
+func NewChallenges(minBits int, maxBits int, ttl time.Duration) *Challenges {
+	return &Challenges{
+		minBits: minBits,
+		maxBits: maxBits,
+		ttl:     ttl,
+		pending: make(map[string]Challenge),
+		solved:  make(map[string]Solution),
+		passed:  make(map[string]struct{}),
+		now:     time.Now,
+	}
+}
@@ -0,0 +1,126 @@
+package challenge
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// solve finds the nonce of the challenge by brute force.
+func solve(c Challenge) string {
+	for i := 0; ; i++ {
+		nonce := strconv.Itoa(i)
+		if LeadingZeroBits(c.Seed, nonce) >= c.Difficulty {
+			return nonce
+		}
+	}
+}
+
+func TestChallenges_Issue(t *testing.T) {
+	c := NewChallenges(4, 13, time.Minute)
+
+	low, ok := c.Issue("user1", 0, true)
+	require.True(t, ok)
+	assert.Equal(t, 4, low.Difficulty)
+	assert.Len(t, low.Seed, 32)
+
+	again, _ := c.Issue("user1", 1, true)
+	assert.Equal(t, low, again, "pending challenge should be returned until it expires")
+
+	for _, tt := range []struct {
+		score      float32
+		difficulty int
+	}{{0.2, 4}, {0.3, 7}, {0.49, 7}, {0.5, 10}, {0.8, 13}, {1, 13}, {1.5, 13}, {-1, 4}} {
+		issued, _ := NewChallenges(4, 13, time.Minute).Issue("user2", tt.score, true)
+		assert.Equal(t, tt.difficulty, issued.Difficulty, "score %v should be rounded down to its range", tt.score)
+	}
+}
+
+func TestChallenges_Optional(t *testing.T) {
+	c := NewChallenges(4, 13, time.Minute)
+
+	optional, ok := c.Issue("user1", 1, false)
+	require.True(t, ok)
+	assert.Equal(t, 4, optional.Difficulty, "optional challenge should have the minimum difficulty")
+	again, _ := c.Issue("user1", 1, false)
+	assert.Equal(t, optional, again)
+
+	solution, err := c.Verify("user1", solve(optional))
+	require.NoError(t, err)
+	assert.False(t, solution.Required)
+	_, found := c.Solution("user1")
+	assert.False(t, found, "optional solution should not be recorded")
+
+	_, ok = c.Issue("user1", 1, false)
+	assert.False(t, ok, "session that solved an optional challenge should not get another one")
+	required, ok := c.Issue("user1", 1, true)
+	require.True(t, ok, "optional solution should not exempt the session")
+	assert.Equal(t, 13, required.Difficulty)
+
+	kept, _ := c.Issue("user1", 0, false)
+	assert.Equal(t, required, kept, "pending required challenge should be kept")
+
+	optional, _ = c.Issue("user2", 1, false)
+	required, _ = c.Issue("user2", 1, true)
+	assert.NotEqual(t, optional.Seed, required.Seed, "pending optional challenge should be replaced by a required one")
+}
+
+func TestChallenges_Verify(t *testing.T) {
+	now := time.Now()
+	c := NewChallenges(8, 8, time.Minute)
+	c.now = func() time.Time { return now }
+
+	_, err := c.Verify("user1", "0")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	challenge, _ := c.Issue("user1", 0.5, true)
+	nonce := solve(challenge)
+	invalid := nonce + "x"
+	for LeadingZeroBits(challenge.Seed, invalid) >= challenge.Difficulty {
+		invalid += "x"
+	}
+	_, err = c.Verify("user1", invalid)
+	assert.ErrorIs(t, err, ErrInvalidSolution)
+
+	now = now.Add(1500 * time.Millisecond)
+	solution, err := c.Verify("user1", nonce)
+	require.NoError(t, err)
+	assert.Equal(t, Solution{Difficulty: 8, SolveTime: 1500 * time.Millisecond, Required: true}, solution)
+
+	stored, found := c.Solution("user1")
+	assert.True(t, found)
+	assert.Equal(t, solution, stored)
+
+	_, ok := c.Issue("user1", 1, true)
+	assert.False(t, ok, "solved session should not be challenged again")
+	_, err = c.Verify("user1", nonce)
+	assert.ErrorIs(t, err, ErrNotFound, "solution should not be accepted twice")
+
+	c.Forget("user1")
+	_, found = c.Solution("user1")
+	assert.False(t, found)
+}
+
+func TestChallenges_Expired(t *testing.T) {
+	now := time.Now()
+	c := NewChallenges(1, 1, time.Minute)
+	c.now = func() time.Time { return now }
+
+	challenge, _ := c.Issue("user1", 0, true)
+	now = now.Add(time.Minute)
+	_, err := c.Verify("user1", solve(challenge))
+	assert.ErrorIs(t, err, ErrExpired)
+
+	renewed, ok := c.Issue("user1", 0, true)
+	assert.True(t, ok)
+	assert.NotEqual(t, challenge.Seed, renewed.Seed, "expired challenge should be replaced")
+}
+
+func TestLeadingZeroBits(t *testing.T) {
+	assert.Equal(t, 1, LeadingZeroBits("seed", "0"), "sha256 starts with 0x58")
+	assert.Equal(t, 9, LeadingZeroBits("seed", "293"))
+	assert.Equal(t, 10, LeadingZeroBits("seed", "1456"))
+}
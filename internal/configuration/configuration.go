@@ -1,8 +1,13 @@
 package configuration
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"net/netip"
 	"net/url"
 	"strings"
 	"time"
@@ -13,6 +18,31 @@ import (
 const (
 	ScorerTypeML    = "ml"
 	ScorerTypeRules = "rules"
+	ScorerTypeModel = "model"
+)
+
+const (
+	AggregationSum     = "sum"
+	AggregationMean    = "mean"
+	AggregationMax     = "max"
+	AggregationNoisyOr = "noisy_or"
+	AggregationLogOdds = "log_odds"
+)
+
+const (
+	TLSVersion12 = "1.2"
+	TLSVersion13 = "1.3"
+)
+
+const (
+	ReplayFlag   = "flag"
+	ReplayReject = "reject"
+)
+
+const (
+	OnErrorFail     = "fail"
+	OnErrorSkip     = "skip"
+	OnErrorFallback = "fallback"
 )
 
 // AppConfig represents the complete application configuration.
@@ -25,6 +55,25 @@ type AppConfig struct {
 	Analysis AnalysisConfig `mapstructure:"analysis"`
 	// Dataset — behavioral dataset configuration
 	Dataset DatasetConfig `mapstructure:"dataset"`
+	// Notifications — verdict change notifications configuration
+	Notifications NotificationsConfig `mapstructure:"notifications"`
+	// Admin — admin listener configuration
+	Admin AdminConfig `mapstructure:"admin"`
+}
+
+// AdminConfig defines the listener of the operational endpoints.
+type AdminConfig struct {
+	// Address — address and port of a separate admin listener (e.g., "127.0.0.1:9090").
+	// If empty, the admin endpoints are served by the API server.
+	Address string `mapstructure:"address"`
+	// Auth — API keys of the admin API for managing sessions, separate from the score API keys.
+	// If no keys are set, the admin API is disabled.
+	Auth AuthConfig `mapstructure:"auth"`
+}
+
+// Validate checks the admin API keys.
+func (a *AdminConfig) Validate() error {
+	return a.Auth.Validate("admin.auth")
 }
 
 // LoggerConfig defines logging settings.
@@ -36,22 +85,266 @@ type LoggerConfig struct {
 
 // ServerConfig contains HTTP server parameters.
 type ServerConfig struct {
-	// Address — address and port where the server will listen (e.g., ":8080").
+	// Address — address and port where the server will listen (e.g., ":8080"),
+	// or path to a Unix domain socket with the "unix:" prefix (e.g., "unix:/run/bean/bean.sock").
 	Address string `mapstructure:"address"`
+	// ReadTimeout — maximum duration for reading the entire request (default 3s).
+	ReadTimeout time.Duration `mapstructure:"read_timeout"`
+	// WriteTimeout — maximum duration of writing the response (default 3s).
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	// IdleTimeout — maximum time to wait for the next request on a keep-alive connection (default 60s).
+	IdleTimeout time.Duration `mapstructure:"idle_timeout"`
+	// MaxHeaderBytes — maximum size of the request headers in bytes (default 10240).
+	MaxHeaderBytes int `mapstructure:"max_header_bytes"`
+	// MaxTraceBytes — maximum size of a trace request body in bytes (default 65536).
+	MaxTraceBytes int64 `mapstructure:"max_trace_bytes"`
 	// Static — path to directory with static files served by the server.
 	// Can be empty if static serving is not required.
 	Static string `mapstructure:"static"`
+	// Collector — path to a customized collector script served instead of the embedded one (optional).
+	Collector string `mapstructure:"collector"`
+	// Stream — score stream (server-sent events) settings.
+	Stream StreamConfig `mapstructure:"stream"`
+	// Auth — API key authentication of the score routes.
+	Auth AuthConfig `mapstructure:"auth"`
+	// TLS — HTTPS serving parameters.
+	TLS TLSConfig `mapstructure:"tls"`
+	// TrustedProxies — networks (CIDR) or addresses of the reverse proxies whose
+	// Forwarded and X-Forwarded-For headers are used to determine the client IP.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+	// RateLimit — rate limits of the trace ingestion.
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+	// Sessions — signed session tokens issued by the server.
+	Sessions SessionsConfig `mapstructure:"sessions"`
+	// Replay — handling of reports with a duplicate nonce: flag or reject (default flag).
+	Replay string `mapstructure:"replay"`
+	// Obfuscation — per-deployment obfuscated variants of the collector script.
+	Obfuscation ObfuscationConfig `mapstructure:"obfuscation"`
+	// RawEvents — decoding of the raw events sent by the collector.
+	RawEvents RawEventsConfig `mapstructure:"raw_events"`
+}
+
+// SessionsConfig contains the parameters of signed session tokens.
+type SessionsConfig struct {
+	// Enabled — issue session tokens at POST /api/v1/sessions and reject traces with invalid tokens.
+	Enabled bool `mapstructure:"enabled"`
+	// Secrets — HMAC secrets of the tokens (at least 32 characters), the first one signs new tokens.
+	// If empty, random keys are generated and rotated; tokens are then invalidated by a restart
+	// and are not shared between instances.
+	Secrets []string `mapstructure:"secrets" json:"-"`
+	// TTL — lifetime of issued tokens (default 24h).
+	TTL time.Duration `mapstructure:"ttl"`
+	// Rotate — interval of the generated key rotation (default 24h).
+	Rotate time.Duration `mapstructure:"rotate"`
+}
+
+// Validate checks the secrets and sets the default TTL and rotation interval.
+func (s *SessionsConfig) Validate() error {
+	for i, secret := range s.Secrets {
+		if len(secret) < 32 {
+			return fmt.Errorf("server.sessions.secrets[%d]: must be at least 32 characters", i)
+		}
+	}
+	if s.TTL < 0 || s.Rotate < 0 {
+		return errors.New("server.sessions: ttl and rotate must not be negative")
+	}
+	if s.TTL == 0 {
+		s.TTL = 24 * time.Hour
+	}
+	if s.Rotate == 0 {
+		s.Rotate = 24 * time.Hour
+	}
+	return nil
+}
+
+// ObfuscationConfig contains the parameters of the obfuscated collector variants.
+type ObfuscationConfig struct {
+	// Enabled — serve a variant of the collector script with randomized payload keys and
+	// minified method names, and decode the received traces.
+	Enabled bool `mapstructure:"enabled"`
+	// Secret — secret deriving the variants (at least 32 characters), shared by instances.
+	// If empty, variants are random; they then change on restart and differ between instances.
+	Secret string `mapstructure:"secret" json:"-"`
+	// Rotate — rotation period of the variant (default 24h).
+	Rotate time.Duration `mapstructure:"rotate"`
+}
+
+// Validate checks the secret and sets the default rotation period.
+func (o *ObfuscationConfig) Validate() error {
+	if o.Secret != "" && len(o.Secret) < 32 {
+		return errors.New("server.obfuscation.secret: must be at least 32 characters")
+	}
+	if o.Rotate < 0 {
+		return errors.New("server.obfuscation.rotate: must not be negative")
+	}
+	if o.Rotate == 0 {
+		o.Rotate = 24 * time.Hour
+	}
+	return nil
+}
+
+// RawEventsConfig contains the parameters of the raw events and keystrokes sent by the collector.
+type RawEventsConfig struct {
+	// MaxEvents — maximum number of events per stream and of keystrokes decoded (default 1000).
+	MaxEvents int `mapstructure:"max_events"`
+	// Keep — keep the raw events and keystrokes in the stored traces and the dataset.
+	Keep bool `mapstructure:"keep"`
+}
+
+// Validate sets the default limit of the raw events.
+func (r *RawEventsConfig) Validate() error {
+	if r.MaxEvents < 0 {
+		return errors.New("server.raw_events.max_events: must not be negative")
+	}
+	if r.MaxEvents == 0 {
+		r.MaxEvents = 1000
+	}
+	return nil
+}
+
+// RateLimitConfig contains the rate limits of the trace ingestion.
+type RateLimitConfig struct {
+	// IP — rate limit by client IP.
+	IP BucketConfig `mapstructure:"ip"`
+	// Session — rate limit by session token.
+	Session BucketConfig `mapstructure:"session"`
+}
+
+// BucketConfig defines a token bucket rate limit.
+// The limit is disabled if Rate is zero.
+type BucketConfig struct {
+	// Rate — sustained number of traces per second.
+	Rate float64 `mapstructure:"rate"`
+	// Burst — maximum number of traces at once (default rate rounded up).
+	Burst int `mapstructure:"burst"`
+}
+
+// ParsePrefix parses a trusted proxy network in CIDR notation or a single address.
+func ParsePrefix(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Validate checks that the rate is not negative and sets the default burst.
+func (b *BucketConfig) Validate(name string) error {
+	if b.Rate < 0 || b.Burst < 0 {
+		return fmt.Errorf("%s: rate and burst must not be negative", name)
+	}
+	if b.Rate > 0 && b.Burst == 0 {
+		b.Burst = int(math.Ceil(b.Rate))
+	}
+	return nil
+}
+
+// TLSConfig contains HTTPS serving parameters.
+// TLS is enabled if the certificate is specified.
+type TLSConfig struct {
+	// Cert — path to the PEM-encoded certificate chain.
+	Cert string `mapstructure:"cert"`
+	// Key — path to the PEM-encoded private key.
+	Key string `mapstructure:"key"`
+	// MinVersion — minimum TLS version: 1.2 or 1.3 (default 1.2).
+	MinVersion string `mapstructure:"min_version"`
+	// Reload — interval between checks of the certificate files for changes (default 1m).
+	Reload time.Duration `mapstructure:"reload"`
+	// ClientCa — path to the CA certificates verifying client certificates (optional).
+	// Requests with a verified client certificate are authorized without an API key.
+	ClientCa string `mapstructure:"client_ca"`
+	// Redirect — address of a plain HTTP listener redirecting to HTTPS (optional).
+	Redirect string `mapstructure:"redirect"`
+}
+
+// Enabled reports whether HTTPS is configured.
+func (t *TLSConfig) Enabled() bool {
+	return t.Cert != ""
+}
+
+// AuthConfig contains API key authentication parameters.
+// Authentication is enabled if any key or the keys file is specified.
+type AuthConfig struct {
+	// Keys — valid API keys.
+	Keys []string `mapstructure:"keys" json:"-"`
+	// KeysFile — path to the file with one API key per line (optional).
+	KeysFile string `mapstructure:"keys_file"`
+	// Reload — interval between checks of the keys file for changes (default 30s).
+	Reload time.Duration `mapstructure:"reload"`
+}
+
+// Enabled reports whether API key authentication is configured.
+func (a *AuthConfig) Enabled() bool {
+	return len(a.Keys) > 0 || a.KeysFile != ""
+}
+
+// Validate checks the keys and sets the default reload interval.
+// The name is the configuration path used in error messages.
+func (a *AuthConfig) Validate(name string) error {
+	for i, key := range a.Keys {
+		if key == "" {
+			return fmt.Errorf("%s.keys[%d]: must not be empty", name, i)
+		}
+	}
+	if a.Reload < 0 {
+		return fmt.Errorf("%s.reload: must not be negative", name)
+	}
+	if a.Reload == 0 {
+		a.Reload = 30 * time.Second
+	}
+	return nil
+}
+
+// StreamConfig contains score stream parameters.
+type StreamConfig struct {
+	// Delta — minimal change of any score key that is sent to the stream (default 0.05).
+	Delta float32 `mapstructure:"delta"`
+	// Heartbeat — interval between heartbeat messages (default 15s).
+	Heartbeat time.Duration `mapstructure:"heartbeat"`
 }
 
 type ScorerConfig struct {
+	// Name — scorer name reported in the score response (default "<type>-<index>")
+	Name string `mapstructure:"name"`
 	// Type — scorer type
 	Type string `mapstructure:"type"`
-	// Model — path to the model file
+	// Model — model name for the ML scorer or path to the model file for the model scorer
 	Model string `mapstructure:"model"`
 	// URL — URL to the scorer service
 	Url string `mapstructure:"url"`
 	// Rules — path to the file with analysis rules in YAML format.
 	Rules string `mapstructure:"rules"`
+	// Timeout — timeout of a single request to the ML service (default 1s)
+	Timeout time.Duration `mapstructure:"timeout"`
+	// Retries — number of retries of a failed request to the ML service
+	Retries int `mapstructure:"retries"`
+	// RetryBackoff — base delay between retries, doubled on each retry and randomized (default 100ms)
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+	// Breaker — circuit breaker of the ML service
+	Breaker BreakerConfig `mapstructure:"breaker"`
+	// OnError — failure handling policy: fail, skip or fallback (default fail)
+	OnError string `mapstructure:"on_error"`
+	// Fallback — static score used on failure with the fallback policy
+	Fallback map[string]float32 `mapstructure:"fallback"`
+	// Deadline — maximum time the scorer may run per score request (optional)
+	Deadline time.Duration `mapstructure:"deadline"`
+	// Weight — weight of the scorer values in the aggregation; 0 disables the scorer values (default 1)
+	Weight *float32 `mapstructure:"weight"`
+	// Incremental — evaluate rules once per trace at ingest instead of on every score request
+	Incremental bool `mapstructure:"incremental"`
+}
+
+// BreakerConfig defines circuit breaker parameters.
+// The breaker is disabled if Failures is zero.
+type BreakerConfig struct {
+	// Failures — number of consecutive failures that opens the breaker
+	Failures int `mapstructure:"failures"`
+	// Cooldown — time the breaker stays open before a trial request (default 30s)
+	Cooldown time.Duration `mapstructure:"cooldown"`
 }
 
 // AnalysisConfig defines behavioral analysis parameters.
@@ -67,6 +360,94 @@ type AnalysisConfig struct {
 	// TracesTtl — lifetime of traces (time.Duration), after which inactive records are deleted.
 	// Example: "5m", "1h", "24h".
 	TracesTtl time.Duration `mapstructure:"traces_ttl"`
+	// ScoreBudget — overall time limit of a score request; scorers that did not finish
+	// in time are reported as timed out (optional).
+	ScoreBudget time.Duration `mapstructure:"score_budget"`
+	// Aggregation — strategies of combining scorer results by score key
+	Aggregation AggregationConfig `mapstructure:"aggregation"`
+	// Verdict — verdict bands of the score (optional)
+	Verdict VerdictConfig `mapstructure:"verdict"`
+	// Challenge — proof-of-work challenges of sessions in a verdict band (optional)
+	Challenge ChallengeConfig `mapstructure:"challenge"`
+}
+
+// ChallengeConfig defines the proof-of-work challenges.
+// Challenges are enabled if the verdict is specified.
+type ChallengeConfig struct {
+	// Verdict — verdict band of the challenged sessions
+	Verdict string `mapstructure:"verdict"`
+	// MinBits — difficulty of the lowest score in leading zero bits (default 12)
+	MinBits int `mapstructure:"min_bits"`
+	// MaxBits — difficulty of the highest score in leading zero bits (default 20)
+	MaxBits int `mapstructure:"max_bits"`
+	// TTL — time to solve a challenge (default 5m)
+	TTL time.Duration `mapstructure:"ttl"`
+	// Adjustment — score adjustment of the sessions that solved a challenge (e.g., automation: -0.3)
+	Adjustment map[string]float32 `mapstructure:"adjustment"`
+}
+
+// Enabled reports whether challenges are configured.
+func (c *ChallengeConfig) Enabled() bool {
+	return c.Verdict != ""
+}
+
+// Validate checks that the challenged verdict is a configured band and sets the default
+// difficulty and TTL.
+func (c *ChallengeConfig) Validate(verdict VerdictConfig) error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	found := false
+	for _, band := range verdict.Bands {
+		found = found || band.Name == c.Verdict
+	}
+	if !found {
+		return fmt.Errorf("analysis.challenge.verdict: unknown verdict %s", c.Verdict)
+	}
+
+	if c.MinBits == 0 {
+		c.MinBits = 12
+	}
+	if c.MaxBits == 0 {
+		c.MaxBits = 20
+	}
+	if c.MinBits < 1 || c.MaxBits > 32 || c.MinBits > c.MaxBits {
+		return errors.New("analysis.challenge: bits must satisfy 1 <= min_bits <= max_bits <= 32")
+	}
+
+	if c.TTL < 0 {
+		return errors.New("analysis.challenge.ttl: must not be negative")
+	}
+	if c.TTL == 0 {
+		c.TTL = 5 * time.Minute
+	}
+
+	return nil
+}
+
+// VerdictConfig defines how the score is classified into verdicts.
+type VerdictConfig struct {
+	// Key — score key used for classification (default "automation")
+	Key string `mapstructure:"key"`
+	// Bands — verdict bands in ascending order of their lower bounds
+	Bands []BandConfig `mapstructure:"bands"`
+}
+
+// BandConfig defines a verdict band.
+type BandConfig struct {
+	// Name — verdict name
+	Name string `mapstructure:"name"`
+	// Min — lower bound of the band (inclusive)
+	Min float32 `mapstructure:"min"`
+}
+
+// AggregationConfig defines how the values of a score key produced by several scorers are combined.
+type AggregationConfig struct {
+	// Default — strategy for keys without an explicit strategy: sum, mean, max, noisy_or, log_odds (default sum)
+	Default string `mapstructure:"default"`
+	// Keys — strategies by score key
+	Keys map[string]string `mapstructure:"keys"`
 }
 
 // DatasetConfig defines behavioral dataset parameters
@@ -79,6 +460,39 @@ type DatasetConfig struct {
 	Amount int `mapstructure:"amount"`
 }
 
+// NotificationsConfig defines notifications about verdict changes of sessions.
+type NotificationsConfig struct {
+	// QueueSize — maximum number of events waiting for delivery per webhook (default 1000)
+	QueueSize int `mapstructure:"queue_size"`
+	// Spool — directory for events that could not be delivered (optional)
+	Spool string `mapstructure:"spool"`
+	// SpoolRetry — interval between redeliveries of spooled events (default 30s)
+	SpoolRetry time.Duration `mapstructure:"spool_retry"`
+	// Webhooks — notified webhooks
+	Webhooks []WebhookConfig `mapstructure:"webhooks"`
+}
+
+// WebhookConfig defines a webhook notified about verdict changes.
+type WebhookConfig struct {
+	// Name — webhook name used in logs and as the spool file name: letters, digits, '_' and '-',
+	// unique regardless of case (default "webhook-<index>")
+	Name string `mapstructure:"name"`
+	// Url — URL of the endpoint
+	Url string `mapstructure:"url"`
+	// Secret — HMAC-SHA256 signing secret (optional)
+	Secret string `mapstructure:"secret" json:"-"`
+	// Verdicts — verdicts the webhook is notified about; any verdict if empty
+	Verdicts []string `mapstructure:"verdicts"`
+	// MinScore — minimal values of score keys the webhook is notified about
+	MinScore map[string]float32 `mapstructure:"min_score"`
+	// Timeout — timeout of a single request (default 5s)
+	Timeout time.Duration `mapstructure:"timeout"`
+	// Retries — number of retries of a failed request
+	Retries int `mapstructure:"retries"`
+	// RetryBackoff — base delay between retries, doubled on each retry and randomized (default 1s)
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+}
+
 // Validate checks the correctness of the entire application configuration.
 // Calls validation for each nested structure and returns the first detected error.
 // Returns nil if the configuration is valid.
@@ -95,6 +509,14 @@ func (c *AppConfig) Validate() error {
 		return err
 	}
 
+	if err := c.Admin.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Notifications.Validate(c.Analysis.Verdict); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -137,24 +559,269 @@ func (c *ScorerConfig) Validate() error {
 		if _, err := url.Parse(c.Url); err != nil {
 			return errors.New("ML scorer: URL is incorrect")
 		}
+		if c.Timeout < 0 || c.Retries < 0 || c.RetryBackoff < 0 || c.Breaker.Failures < 0 || c.Breaker.Cooldown < 0 {
+			return errors.New("ML scorer: timeouts, retries and breaker parameters must not be negative")
+		}
+		if c.Timeout == 0 {
+			c.Timeout = time.Second
+		}
+		if c.RetryBackoff == 0 {
+			c.RetryBackoff = 100 * time.Millisecond
+		}
+		if c.Breaker.Cooldown == 0 {
+			c.Breaker.Cooldown = 30 * time.Second
+		}
 	case ScorerTypeRules:
 		if len(c.Rules) == 0 {
 			return errors.New("scorer rules: path must be specified")
 		}
+	case ScorerTypeModel:
+		if len(c.Model) == 0 {
+			return errors.New("model scorer: model file must be specified")
+		}
 	default:
 		return errors.New("Scorer type must be specified")
 	}
 
+	if c.Deadline < 0 {
+		return fmt.Errorf("scorer %s: deadline must not be negative", c.Name)
+	}
+
+	if c.Incremental && c.Type != ScorerTypeRules {
+		return fmt.Errorf("scorer %s: incremental mode is supported by rules scorers only", c.Name)
+	}
+
+	if c.Weight == nil {
+		weight := float32(1)
+		c.Weight = &weight
+	}
+	if *c.Weight < 0 {
+		return fmt.Errorf("scorer %s: weight must not be negative", c.Name)
+	}
+
+	switch c.OnError {
+	case "":
+		c.OnError = OnErrorFail
+	case OnErrorFail, OnErrorSkip:
+	case OnErrorFallback:
+		if len(c.Fallback) == 0 {
+			return fmt.Errorf("scorer %s: fallback score must be specified", c.Name)
+		}
+	default:
+		return fmt.Errorf("scorer %s: unsupported on_error policy '%s'", c.Name, c.OnError)
+	}
+
+	return nil
+}
+
+// Validate checks that the aggregation strategies are supported.
+func (g *AggregationConfig) Validate() error {
+	valid := map[string]bool{
+		AggregationSum:     true,
+		AggregationMean:    true,
+		AggregationMax:     true,
+		AggregationNoisyOr: true,
+		AggregationLogOdds: true,
+	}
+
+	if g.Default == "" {
+		g.Default = AggregationSum
+	}
+	if !valid[g.Default] {
+		return fmt.Errorf("analysis.aggregation.default: unsupported strategy '%s'", g.Default)
+	}
+
+	for key, strategy := range g.Keys {
+		if !valid[strategy] {
+			return fmt.Errorf("analysis.aggregation.keys.%s: unsupported strategy '%s'", key, strategy)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that verdict bands are named and sorted by their lower bounds.
+func (v *VerdictConfig) Validate() error {
+	if v.Key == "" {
+		v.Key = "automation"
+	}
+
+	for i, band := range v.Bands {
+		if band.Name == "" {
+			return fmt.Errorf("analysis.verdict.bands[%d]: name must be specified", i)
+		}
+		if i > 0 && band.Min <= v.Bands[i-1].Min {
+			return fmt.Errorf("analysis.verdict.bands[%d]: bands must be sorted by min in ascending order", i)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the correctness of the notifications configuration.
+// Webhook verdicts must be defined in the verdict bands.
+func (n *NotificationsConfig) Validate(verdict VerdictConfig) error {
+	if n.QueueSize < 0 || n.SpoolRetry < 0 {
+		return errors.New("notifications: queue_size and spool_retry must not be negative")
+	}
+	if n.QueueSize == 0 {
+		n.QueueSize = 1000
+	}
+	if n.SpoolRetry == 0 {
+		n.SpoolRetry = 30 * time.Second
+	}
+
+	bands := make(map[string]bool)
+	for _, band := range verdict.Bands {
+		bands[band.Name] = true
+	}
+
+	names := make(map[string]bool)
+	for i := range n.Webhooks {
+		w := &n.Webhooks[i]
+		if w.Name == "" {
+			w.Name = fmt.Sprintf("webhook-%d", i)
+		}
+		// The name is the spool file name: no path separators, and no names differing only in case
+		invalid := strings.ContainsFunc(w.Name, func(r rune) bool {
+			return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '_' || r == '-')
+		})
+		if invalid {
+			return fmt.Errorf("webhook %s: name may contain only letters, digits, '_' and '-'", w.Name)
+		}
+		if names[strings.ToLower(w.Name)] {
+			return fmt.Errorf("notifications.webhooks: duplicate webhook name '%s'", w.Name)
+		}
+		names[strings.ToLower(w.Name)] = true
+
+		u, err := url.Parse(w.Url)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook %s: URL is incorrect", w.Name)
+		}
+		for _, v := range w.Verdicts {
+			if !bands[v] {
+				return fmt.Errorf("webhook %s: verdict '%s' is not defined in analysis.verdict.bands", w.Name, v)
+			}
+		}
+		if w.Timeout < 0 || w.Retries < 0 || w.RetryBackoff < 0 {
+			return fmt.Errorf("webhook %s: timeout and retries parameters must not be negative", w.Name)
+		}
+		if w.Timeout == 0 {
+			w.Timeout = 5 * time.Second
+		}
+		if w.RetryBackoff == 0 {
+			w.RetryBackoff = time.Second
+		}
+	}
+
 	return nil
 }
 
 // Validate checks the correctness of the server configuration.
 // Verifies that the server address is set.
 func (n *ServerConfig) Validate() error {
-	if n.Address == "" {
+	if n.Address == "" || n.Address == "unix:" {
 		return errors.New("server.address: must be specified")
 	}
 
+	if n.ReadTimeout < 0 || n.WriteTimeout < 0 || n.IdleTimeout < 0 {
+		return errors.New("server: timeouts must not be negative")
+	}
+	if n.MaxHeaderBytes < 0 || n.MaxTraceBytes < 0 {
+		return errors.New("server: size limits must not be negative")
+	}
+	if n.ReadTimeout == 0 {
+		n.ReadTimeout = 3 * time.Second
+	}
+	if n.WriteTimeout == 0 {
+		n.WriteTimeout = 3 * time.Second
+	}
+	if n.IdleTimeout == 0 {
+		n.IdleTimeout = 60 * time.Second
+	}
+	if n.MaxHeaderBytes == 0 {
+		n.MaxHeaderBytes = 10 * 1024
+	}
+	if n.MaxTraceBytes == 0 {
+		n.MaxTraceBytes = 64 * 1024
+	}
+
+	if n.Stream.Delta < 0 || n.Stream.Heartbeat < 0 {
+		return errors.New("server.stream: delta and heartbeat must not be negative")
+	}
+	if n.Stream.Delta == 0 {
+		n.Stream.Delta = 0.05
+	}
+	if n.Stream.Heartbeat == 0 {
+		n.Stream.Heartbeat = 15 * time.Second
+	}
+
+	if err := n.Auth.Validate("server.auth"); err != nil {
+		return err
+	}
+
+	for i, proxy := range n.TrustedProxies {
+		if _, err := ParsePrefix(proxy); err != nil {
+			return fmt.Errorf("server.trusted_proxies[%d]: %w", i, err)
+		}
+	}
+
+	if err := n.RateLimit.IP.Validate("server.rate_limit.ip"); err != nil {
+		return err
+	}
+	if err := n.RateLimit.Session.Validate("server.rate_limit.session"); err != nil {
+		return err
+	}
+	if err := n.Sessions.Validate(); err != nil {
+		return err
+	}
+	if err := n.Obfuscation.Validate(); err != nil {
+		return err
+	}
+	if err := n.RawEvents.Validate(); err != nil {
+		return err
+	}
+
+	switch n.Replay {
+	case "":
+		n.Replay = ReplayFlag
+	case ReplayFlag, ReplayReject:
+	default:
+		return fmt.Errorf("server.replay: unsupported mode %s", n.Replay)
+	}
+
+	return n.TLS.Validate()
+}
+
+// Validate checks the correctness of the TLS configuration.
+// Sets default values for MinVersion and Reload.
+func (t *TLSConfig) Validate() error {
+	if !t.Enabled() {
+		if t.Key != "" || t.ClientCa != "" || t.Redirect != "" {
+			return errors.New("server.tls.cert: must be specified")
+		}
+		return nil
+	}
+
+	if t.Key == "" {
+		return errors.New("server.tls.key: must be specified")
+	}
+
+	switch t.MinVersion {
+	case "":
+		t.MinVersion = TLSVersion12
+	case TLSVersion12, TLSVersion13:
+	default:
+		return fmt.Errorf("server.tls.min_version: unsupported version %s", t.MinVersion)
+	}
+
+	if t.Reload < 0 {
+		return errors.New("server.tls.reload: must not be negative")
+	}
+	if t.Reload == 0 {
+		t.Reload = time.Minute
+	}
+
 	return nil
 }
 
@@ -165,7 +832,16 @@ func (a *AnalysisConfig) Validate() error {
 		return errors.New("analysis.scorers: must be specified")
 	}
 
+	names := make(map[string]bool)
 	for i := range a.Scorers {
+		if a.Scorers[i].Name == "" {
+			a.Scorers[i].Name = fmt.Sprintf("%s-%d", a.Scorers[i].Type, i)
+		}
+		if names[a.Scorers[i].Name] {
+			return fmt.Errorf("analysis.scorers: duplicate scorer name '%s'", a.Scorers[i].Name)
+		}
+		names[a.Scorers[i].Name] = true
+
 		if err := a.Scorers[i].Validate(); err != nil {
 			return err
 		}
@@ -175,9 +851,35 @@ func (a *AnalysisConfig) Validate() error {
 		return errors.New("analysis.token: must be specified")
 	}
 
+	if a.ScoreBudget < 0 {
+		return errors.New("analysis.score_budget: must not be negative")
+	}
+
+	if err := a.Aggregation.Validate(); err != nil {
+		return err
+	}
+
+	if err := a.Verdict.Validate(); err != nil {
+		return err
+	}
+
+	if err := a.Challenge.Validate(a.Verdict); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// Hash returns the hex-encoded SHA-256 of the configuration after defaults are applied.
+// Equal configurations have equal hashes regardless of the file formatting.
+// Secrets (fields tagged json:"-") are not hashed, so the published hash can't be used
+// to verify guessed secrets.
+func (c *AppConfig) Hash() string {
+	data, _ := json.Marshal(c)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
 // LoadConfig loads configuration from the specified file using Viper.
 // Supports YAML format. Also includes environment variable loading (AutomaticEnv),
 // which can override values from the file.
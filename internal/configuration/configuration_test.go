@@ -0,0 +1,38 @@
+package configuration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppConfig_HashIgnoresSecrets(t *testing.T) {
+	config := func() *AppConfig {
+		c := &AppConfig{}
+		c.Server.Address = ":8080"
+		c.Server.Auth.Keys = []string{"key-1"}
+		c.Server.Sessions.Secrets = []string{"sessions-secret-0123456789abcdef0123"}
+		c.Server.Obfuscation.Secret = "obfuscation-secret-0123456789abcdef"
+		c.Admin.Auth.Keys = []string{"admin-key"}
+		c.Notifications.Webhooks = []WebhookConfig{{Name: "fraud", Url: "http://fraud", Secret: "webhook-secret"}}
+		return c
+	}
+	hash := config().Hash()
+
+	secrets := map[string]func(c *AppConfig){
+		"server.auth.keys":          func(c *AppConfig) { c.Server.Auth.Keys = []string{"key-2"} },
+		"server.sessions.secrets":   func(c *AppConfig) { c.Server.Sessions.Secrets[0] = "other" },
+		"server.obfuscation.secret": func(c *AppConfig) { c.Server.Obfuscation.Secret = "other" },
+		"admin.auth.keys":           func(c *AppConfig) { c.Admin.Auth.Keys[0] = "other" },
+		"webhook secret":            func(c *AppConfig) { c.Notifications.Webhooks[0].Secret = "other" },
+	}
+	for name, change := range secrets {
+		c := config()
+		change(c)
+		assert.Equal(t, hash, c.Hash(), name)
+	}
+
+	c := config()
+	c.Notifications.Webhooks[0].Url = "http://other"
+	assert.NotEqual(t, hash, c.Hash())
+}
@@ -2,14 +2,25 @@ package dataset
 
 import (
 	"bean/internal/trace"
+	"bufio"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
+// maxErasePasses limits the repetitions of an erasure racing with the background compression of backups.
+const maxErasePasses = 3
+
 // customJSONHandler is a custom slog handler that outputs logs in JSON format
 // with time in "2006-01-01 15:04:05" format and without the log level field.
 // All attributes are written at the top level of the object.
@@ -86,6 +97,7 @@ func (h *customJSONHandler) Enabled(_ context.Context, _ slog.Level) bool {
 type JsonDatasetRepository struct {
 	lumberjack *lumberjack.Logger // rotating file logger
 	logger     *slog.Logger       // structured logger with custom output
+	mu         sync.Mutex         // mutex to block appends during an erasure
 }
 
 // NewJsonDatasetRepository creates a new repository for dataset collection.
@@ -115,9 +127,150 @@ func NewJsonDatasetRepository(file string, maxSize, maxBackups int) *JsonDataset
 // Recording occurs as a JSON object with "token" and "trace" fields.
 // The method is thread-safe thanks to lumberjack and slog.
 func (r *JsonDatasetRepository) Append(token string, t trace.Trace) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.logger.Info("", "token", token, "trace", t)
 }
 
+// Erase removes the traces of the session from the dataset file and its backups
+// (including compressed ones) and returns the number of removed traces.
+// Appends are blocked during the erasure. Backups compressed by lumberjack
+// in the background meanwhile are checked again, so the erasure is repeated
+// until no traces of the session are left.
+func (r *JsonDatasetRepository) Erase(token string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// The file is reopened by the next append
+	if err := r.lumberjack.Close(); err != nil {
+		return 0, err
+	}
+
+	var total int
+	for range maxErasePasses {
+		files, err := r.files()
+		if err != nil {
+			return total, err
+		}
+
+		var erased int
+		for _, file := range files {
+			n, err := eraseFile(file, token)
+			if err != nil {
+				return total, fmt.Errorf("erase %s: %w", file, err)
+			}
+			erased += n
+		}
+		if erased == 0 {
+			return total, nil
+		}
+		total += erased
+	}
+
+	return total, errors.New("traces are still being written")
+}
+
+// files returns the dataset file and its backups.
+func (r *JsonDatasetRepository) files() ([]string, error) {
+	ext := filepath.Ext(r.lumberjack.Filename)
+	prefix := strings.TrimSuffix(filepath.Base(r.lumberjack.Filename), ext) + "-"
+	entries, err := os.ReadDir(filepath.Dir(r.lumberjack.Filename))
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{r.lumberjack.Filename}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, prefix) &&
+			(strings.HasSuffix(name, ext) || strings.HasSuffix(name, ext+".gz")) {
+			files = append(files, filepath.Join(filepath.Dir(r.lumberjack.Filename), name))
+		}
+	}
+	return files, nil
+}
+
+// eraseFile removes the lines of the session from the file, gzip-compressed if its name ends with .gz.
+// The file is replaced atomically and only if it contains the session. Returns the number of removed lines.
+func eraseFile(file, token string) (int, error) {
+	src, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var in io.Reader = src
+	var out io.Writer = tmp
+	compressed := strings.HasSuffix(file, ".gz")
+	var zw *gzip.Writer
+	if compressed {
+		zr, err := gzip.NewReader(src)
+		if err != nil {
+			return 0, err
+		}
+		in = zr
+		zw = gzip.NewWriter(tmp)
+		out = zw
+	}
+
+	reader := bufio.NewReader(in)
+	writer := bufio.NewWriter(out)
+	var erased int
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var record struct {
+				Token string `json:"token"`
+			}
+			if json.Unmarshal(line, &record) == nil && record.Token == token {
+				erased++
+			} else if _, werr := writer.Write(line); werr != nil {
+				return 0, werr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	if erased == 0 {
+		return 0, nil
+	}
+
+	if err = writer.Flush(); err != nil {
+		return 0, err
+	}
+	if compressed {
+		if err = zw.Close(); err != nil {
+			return 0, err
+		}
+	}
+	info, err := src.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if err = tmp.Chmod(info.Mode()); err != nil {
+		return 0, err
+	}
+	if err = tmp.Close(); err != nil {
+		return 0, err
+	}
+
+	return erased, os.Rename(tmp.Name(), file)
+}
+
 // Close closes the underlying file. Should be called when shutting down
 // to ensure write completion and rotation of the last file.
 func (r *JsonDatasetRepository) Close() {
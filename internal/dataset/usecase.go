@@ -4,5 +4,6 @@ import "bean/internal/trace"
 
 type DatasetRepository interface {
 	Append(token string, t trace.Trace)
+	Erase(token string) (int, error)
 	Close()
 }
@@ -0,0 +1,53 @@
+package feature
+
+import (
+	"math"
+
+	"github.com/google/cel-go/cel"
+)
+
+// Field is a trace field set from the features of type F.
+type Field[F any] struct {
+	// Name — name of the trace field and of the CEL variable.
+	Name string
+	// Type — CEL type of the field.
+	Type *cel.Type
+	// Value — value of the field for the features: int64, bool or float64.
+	Value func(f F) any
+}
+
+// Fields are the trace fields set from the features of type F. Rules, the dataset and the ML scorers
+// receive every field set on the trace, so a new feature only needs to be added to its fields.
+type Fields[F any] []Field[F]
+
+// Set sets the trace fields of the features.
+func (fields Fields[F]) Set(f F, t map[string]any) {
+	for _, field := range fields {
+		t[field.Name] = field.Value(f)
+	}
+}
+
+// Finite reports whether all the fields of the features are finite. Features of forged input
+// may overflow; NaN and infinity can't be compared by rules or encoded to JSON for the dataset
+// and the ML scorers.
+func (fields Fields[F]) Finite(f F) bool {
+	for _, field := range fields {
+		if v, ok := field.Value(f).(float64); ok && (math.IsNaN(v) || math.IsInf(v, 0)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Variables returns the CEL environment option declaring the variables of the fields.
+func (fields Fields[F]) Variables() cel.EnvOption {
+	return func(env *cel.Env) (*cel.Env, error) {
+		var err error
+		for _, field := range fields {
+			if env, err = cel.Variable(field.Name, field.Type)(env); err != nil {
+				return nil, err
+			}
+		}
+		return env, nil
+	}
+}
@@ -0,0 +1,40 @@
+package feature
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/cel-go/cel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sample are the features of the tests.
+type sample struct {
+	count int
+	mean  float64
+}
+
+var sampleFields = Fields[sample]{
+	{Name: "sampleCount", Type: cel.IntType, Value: func(s sample) any { return int64(s.count) }},
+	{Name: "sampleMean", Type: cel.DoubleType, Value: func(s sample) any { return s.mean }},
+}
+
+func TestFields_Set(t *testing.T) {
+	fields := map[string]any{"other": true}
+	sampleFields.Set(sample{count: 2, mean: 1.5}, fields)
+	assert.Equal(t, map[string]any{"other": true, "sampleCount": int64(2), "sampleMean": 1.5}, fields)
+}
+
+func TestFields_Finite(t *testing.T) {
+	assert.True(t, sampleFields.Finite(sample{mean: 1}))
+	assert.False(t, sampleFields.Finite(sample{mean: math.NaN()}))
+	assert.False(t, sampleFields.Finite(sample{mean: math.Inf(-1)}))
+}
+
+func TestFields_Variables(t *testing.T) {
+	env, err := cel.NewEnv(sampleFields.Variables())
+	require.NoError(t, err)
+	_, issues := env.Compile("sampleCount > 1 && sampleMean < 2.0")
+	assert.NoError(t, issues.Err())
+}
@@ -0,0 +1,38 @@
+package feature
+
+import "math"
+
+// MeanStd returns the mean and the population standard deviation of the values.
+func MeanStd(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)))
+}
+
+// Entropy returns the Shannon entropy of the histogram with the given bin counts, in bits.
+func Entropy(counts []int) float64 {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+
+	var entropy float64
+	for _, count := range counts {
+		if count > 0 {
+			p := float64(count) / float64(total)
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
@@ -0,0 +1,25 @@
+package feature
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeanStd(t *testing.T) {
+	mean, std := MeanStd([]float64{2, 4, 4, 4, 5, 5, 7, 9})
+	assert.Equal(t, 5.0, mean)
+	assert.Equal(t, 2.0, std)
+
+	mean, std = MeanStd(nil)
+	assert.Zero(t, mean)
+	assert.Zero(t, std)
+}
+
+func TestEntropy(t *testing.T) {
+	assert.Zero(t, Entropy([]int{5}))
+	assert.Equal(t, 1.0, Entropy([]int{3, 0, 3}))
+	assert.InDelta(t, math.Log2(3), Entropy([]int{1, 1, 1}), 1e-12)
+	assert.Zero(t, Entropy(nil))
+}
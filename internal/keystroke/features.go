@@ -0,0 +1,88 @@
+package keystroke
+
+import (
+	"bean/internal/feature"
+	"maps"
+	"math"
+	"slices"
+)
+
+// entropyBin is the width of the histogram bins of the timing entropy, in milliseconds.
+const entropyBin = 10
+
+// Features are the keystroke dynamics features of a report.
+// Scripted typing has constant timings or timings drawn from a narrow uniform range,
+// while human timings depend on the keys and vary with a long tail.
+type Features struct {
+	// Keystrokes — number of keystrokes.
+	Keystrokes int
+	// DwellMean — mean time a key is held, in milliseconds.
+	DwellMean float64
+	// DwellCv — coefficient of variation (standard deviation divided by the mean) of the dwell times.
+	DwellCv float64
+	// DwellEntropy — Shannon entropy of the dwell times in 10 ms bins, in bits.
+	DwellEntropy float64
+	// FlightMean — mean time from a key release to the next press, in milliseconds.
+	FlightMean float64
+	// FlightCv — coefficient of variation of the flight times; zero if the mean is not positive.
+	FlightCv float64
+	// FlightEntropy — Shannon entropy of the flight times in 10 ms bins, in bits.
+	FlightEntropy float64
+	// LatencyVariance — variance of the digraph latencies (time between consecutive presses), in ms².
+	LatencyVariance float64
+	// BackspaceRatio — share of the Backspace and Delete keystrokes.
+	BackspaceRatio float64
+}
+
+// Extract computes the features of the keystrokes.
+// The flight time of the first keystroke is unknown and skipped.
+func Extract(keystrokes []Keystroke) Features {
+	features := Features{Keystrokes: len(keystrokes)}
+	if len(keystrokes) == 0 {
+		return features
+	}
+
+	dwells := make([]float64, 0, len(keystrokes))
+	flights := make([]float64, 0, len(keystrokes))
+	latencies := make([]float64, 0, len(keystrokes))
+	backspaces := 0
+	for i, k := range keystrokes {
+		dwells = append(dwells, k.Dwell)
+		if i > 0 {
+			flights = append(flights, k.Flight)
+			latencies = append(latencies, keystrokes[i-1].Dwell+k.Flight)
+		}
+		if k.Backspace {
+			backspaces++
+		}
+	}
+
+	features.DwellMean, features.DwellCv = meanCv(dwells)
+	features.DwellEntropy = entropy(dwells)
+	features.FlightMean, features.FlightCv = meanCv(flights)
+	features.FlightEntropy = entropy(flights)
+	_, std := feature.MeanStd(latencies)
+	features.LatencyVariance = std * std
+	features.BackspaceRatio = float64(backspaces) / float64(len(keystrokes))
+	return features
+}
+
+// meanCv returns the mean and the coefficient of variation of the values.
+// The coefficient is zero if the mean is not positive.
+func meanCv(values []float64) (float64, float64) {
+	mean, std := feature.MeanStd(values)
+	if mean <= 0 {
+		return mean, 0
+	}
+	return mean, std / mean
+}
+
+// entropy returns the Shannon entropy of the histogram of the values in entropyBin bins, in bits.
+func entropy(values []float64) float64 {
+	histogram := map[int]int{}
+	for _, v := range values {
+		histogram[int(math.Floor(v/entropyBin))]++
+	}
+
+	return feature.Entropy(slices.Collect(maps.Values(histogram)))
+}
@@ -0,0 +1,73 @@
+package keystroke
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtract(t *testing.T) {
+	f := Extract([]Keystroke{
+		{Dwell: 100, Flight: 0},
+		{Dwell: 80, Flight: -20},
+		{Dwell: 50, Flight: 100, Backspace: true},
+	})
+
+	assert.Equal(t, 3, f.Keystrokes)
+	assert.InDelta(t, 230.0/3, f.DwellMean, 1e-9)
+	assert.InDelta(t, math.Log2(3), f.DwellEntropy, 1e-9)
+	assert.InDelta(t, 40, f.FlightMean, 1e-9, "flight of the first keystroke should be skipped")
+	assert.InDelta(t, 1.5, f.FlightCv, 1e-9)
+	assert.InDelta(t, 1, f.FlightEntropy, 1e-9)
+	assert.InDelta(t, 2500, f.LatencyVariance, 1e-9, "latencies are 80 and 180 ms")
+	assert.InDelta(t, 1.0/3, f.BackspaceRatio, 1e-9)
+
+	assert.Equal(t, Features{}, Extract(nil))
+}
+
+func TestExtract_Scripted(t *testing.T) {
+	constant := make([]Keystroke, 20)
+	for i := range constant {
+		constant[i] = Keystroke{Dwell: 50, Flight: 100}
+	}
+	f := Extract(constant)
+	assert.Zero(t, f.DwellCv)
+	assert.Zero(t, f.DwellEntropy)
+	assert.Zero(t, f.FlightCv)
+	assert.Zero(t, f.FlightEntropy)
+	assert.Zero(t, f.LatencyVariance)
+	assert.Zero(t, f.BackspaceRatio)
+
+	// Randomized delays of a script are uniform in a narrow range, while human timings
+	// are log-normal with a long tail.
+	rnd := rand.New(rand.NewPCG(1, 2))
+	uniform := make([]Keystroke, 200)
+	human := make([]Keystroke, 200)
+	for i := range uniform {
+		uniform[i] = Keystroke{Dwell: 50 + rnd.Float64()*50, Flight: 100 + rnd.Float64()*100}
+		human[i] = Keystroke{Dwell: 90 * math.Exp(0.3*rnd.NormFloat64()), Flight: 120 * math.Exp(0.7*rnd.NormFloat64())}
+	}
+	scripted, typed := Extract(uniform), Extract(human)
+	require.Greater(t, scripted.FlightEntropy, 3.0, "randomized delays are not constant")
+	assert.Less(t, scripted.FlightCv, 0.35)
+	assert.Greater(t, typed.FlightCv, 0.6)
+	assert.Greater(t, typed.LatencyVariance, scripted.LatencyVariance)
+}
+
+func TestFeatures_Finite(t *testing.T) {
+	assert.True(t, Fields.Finite(Extract([]Keystroke{{Dwell: 50}, {Dwell: 60, Flight: 100}})))
+	assert.False(t, Fields.Finite(Features{FlightCv: math.NaN()}))
+	assert.False(t, Fields.Finite(Features{LatencyVariance: math.Inf(1)}))
+}
+
+func TestFeatures_Set(t *testing.T) {
+	fields := map[string]any{}
+	Fields.Set(Extract([]Keystroke{{Dwell: 50}}), fields)
+
+	require.Len(t, fields, len(Fields))
+	assert.Equal(t, int64(1), fields["keystrokeCount"])
+	assert.Equal(t, float64(50), fields["dwellMean"])
+}
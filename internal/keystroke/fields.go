@@ -0,0 +1,20 @@
+package keystroke
+
+import (
+	"bean/internal/feature"
+
+	"github.com/google/cel-go/cel"
+)
+
+// Fields are the trace fields of the features.
+var Fields = feature.Fields[Features]{
+	{Name: "keystrokeCount", Type: cel.IntType, Value: func(f Features) any { return int64(f.Keystrokes) }},
+	{Name: "dwellMean", Type: cel.DoubleType, Value: func(f Features) any { return f.DwellMean }},
+	{Name: "dwellCv", Type: cel.DoubleType, Value: func(f Features) any { return f.DwellCv }},
+	{Name: "dwellEntropy", Type: cel.DoubleType, Value: func(f Features) any { return f.DwellEntropy }},
+	{Name: "flightMean", Type: cel.DoubleType, Value: func(f Features) any { return f.FlightMean }},
+	{Name: "flightCv", Type: cel.DoubleType, Value: func(f Features) any { return f.FlightCv }},
+	{Name: "flightEntropy", Type: cel.DoubleType, Value: func(f Features) any { return f.FlightEntropy }},
+	{Name: "digraphLatencyVariance", Type: cel.DoubleType, Value: func(f Features) any { return f.LatencyVariance }},
+	{Name: "backspaceRatio", Type: cel.DoubleType, Value: func(f Features) any { return f.BackspaceRatio }},
+}
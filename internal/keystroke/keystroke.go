@@ -0,0 +1,61 @@
+package keystroke
+
+import (
+	"errors"
+	"math"
+)
+
+// maxTime is the maximum absolute dwell and flight time, in milliseconds (a day).
+// Larger times are forged; they are rejected before the features overflow.
+const maxTime = 24 * 3600 * 1e3
+
+// stride is the number of values of an encoded keystroke: [dwell, flight, backspace].
+const stride = 3
+
+// ErrInvalidKeystrokes is returned for keystrokes not encoded by the collector.
+var ErrInvalidKeystrokes = errors.New("invalid keystrokes")
+
+// Keystroke is a press and release of a key. Key values are not collected.
+type Keystroke struct {
+	// Dwell — time the key was held, in milliseconds.
+	Dwell float64
+	// Flight — time from the release of the previous key to the press, in milliseconds;
+	// negative if the keys overlap. Zero for the first keystroke of a report.
+	Flight float64
+	// Backspace — the key is Backspace or Delete.
+	Backspace bool
+}
+
+// Decode decodes the keystrokes of a trace sent by the collector, in the order of presses:
+//
+//	[dwell, flight, backspace, …]
+//
+// Keystrokes are limited to maxKeystrokes, the rest is dropped; zero means no limit.
+// Returns ErrInvalidKeystrokes if the keystrokes are malformed or the times are out of bounds.
+func Decode(raw any, maxKeystrokes int) ([]Keystroke, error) {
+	array, ok := raw.([]any)
+	if !ok || len(array)%stride != 0 {
+		return nil, ErrInvalidKeystrokes
+	}
+	if maxKeystrokes > 0 && len(array) > maxKeystrokes*stride {
+		array = array[:maxKeystrokes*stride]
+	}
+
+	keystrokes := make([]Keystroke, 0, len(array)/stride)
+	for i := 0; i < len(array); i += stride {
+		dwell, ok1 := number(array[i])
+		flight, ok2 := number(array[i+1])
+		backspace, ok3 := number(array[i+2])
+		if !ok1 || !ok2 || !ok3 || dwell < 0 || dwell > maxTime || math.Abs(flight) > maxTime || (backspace != 0 && backspace != 1) {
+			return nil, ErrInvalidKeystrokes
+		}
+		keystrokes = append(keystrokes, Keystroke{Dwell: dwell, Flight: flight, Backspace: backspace == 1})
+	}
+	return keystrokes, nil
+}
+
+// number returns the finite JSON number of the value.
+func number(value any) (float64, bool) {
+	n, ok := value.(float64)
+	return n, ok && !math.IsNaN(n) && !math.IsInf(n, 0)
+}
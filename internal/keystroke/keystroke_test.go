@@ -0,0 +1,50 @@
+package keystroke
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parse decodes the JSON keystrokes as the trace handler receives them.
+func parse(t *testing.T, payload string) any {
+	var raw any
+	require.NoError(t, json.Unmarshal([]byte(payload), &raw))
+	return raw
+}
+
+func TestDecode(t *testing.T) {
+	keystrokes, err := Decode(parse(t, `[100, 0, 0, 80, -20, 0, 50, 100, 1]`), 0)
+	require.NoError(t, err)
+	assert.Equal(t, []Keystroke{
+		{Dwell: 100, Flight: 0},
+		{Dwell: 80, Flight: -20},
+		{Dwell: 50, Flight: 100, Backspace: true},
+	}, keystrokes)
+
+	keystrokes, err = Decode(parse(t, `[100, 0, 0, 80, -20, 0, 50, 100, 1]`), 2)
+	require.NoError(t, err)
+	assert.Len(t, keystrokes, 2, "keystrokes above the limit should be dropped")
+
+	keystrokes, err = Decode(parse(t, `[]`), 0)
+	require.NoError(t, err)
+	assert.Empty(t, keystrokes)
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	for _, payload := range []string{
+		`{"dwell": [100]}`,
+		`[100, 0]`,
+		`[-1, 0, 0]`,
+		`[100, 0, 2]`,
+		`[100, "0", 0]`,
+		`[1e308, 0, 0, 1e308, 0, 0, 1e308, 0, 0]`,
+		`[100, 0, 0, 100, -1e308, 0, 100, -1e308, 0]`,
+		`[100, 0, 0, 100, 1e9, 0]`,
+	} {
+		_, err := Decode(parse(t, payload), 0)
+		assert.ErrorIs(t, err, ErrInvalidKeystrokes, payload)
+	}
+}
@@ -0,0 +1,52 @@
+package metrics
+
+// Default is the registry of the bean metrics exposed at /metrics.
+var Default = NewRegistry()
+
+// LatencyBuckets are the histogram buckets of request and scorer latencies in seconds.
+var LatencyBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// ScoreBuckets are the histogram buckets of score values.
+var ScoreBuckets = []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1}
+
+// Trace ingestion.
+var (
+	// TracesIngested counts accepted traces.
+	TracesIngested = Default.NewCounter("bean_traces_ingested_total", "Number of accepted traces.")
+	// TracesRejected counts rejected traces by reason.
+	TracesRejected = Default.NewCounter("bean_traces_rejected_total", "Number of rejected traces by reason.", "reason")
+)
+
+// Authentication.
+var (
+	// AuthFailures counts requests to the protected routes rejected without a valid API key.
+	AuthFailures = Default.NewCounter("bean_auth_failures_total", "Number of unauthorized requests to the protected routes.")
+)
+
+// Sessions.
+var (
+	// SessionsActive reports the number of sessions stored in the traces repository.
+	SessionsActive = Default.NewGaugeFunc("bean_sessions_active", "Number of sessions stored in memory.")
+	// SessionsEvicted counts sessions removed as outdated.
+	SessionsEvicted = Default.NewCounter("bean_sessions_evicted_total", "Number of sessions removed after the TTL.")
+	// SessionsIssued counts signed session tokens issued by the sessions endpoint.
+	SessionsIssued = Default.NewCounter("bean_sessions_issued_total", "Number of issued signed session tokens.")
+	// Challenges counts proof-of-work challenges by outcome: issued, solved, solved_optional, invalid or expired.
+	Challenges = Default.NewCounter("bean_challenges_total", "Number of proof-of-work challenges by outcome.", "outcome")
+)
+
+// Scoring.
+var (
+	// ScoreRequestDuration observes score API request latencies by HTTP status code.
+	ScoreRequestDuration = Default.NewHistogram("bean_score_request_duration_seconds", "Latency of score requests by HTTP status code.", LatencyBuckets, "code")
+	// ScorerDuration observes nested scorer latencies by scorer name.
+	ScorerDuration = Default.NewHistogram("bean_scorer_duration_seconds", "Latency of scorers by scorer name.", LatencyBuckets, "scorer")
+	// ScorerErrors counts nested scorer failures by scorer name and reason: error or timeout.
+	ScorerErrors = Default.NewCounter("bean_scorer_errors_total", "Number of scorer failures by scorer name and reason.", "scorer", "reason")
+	// ScoreValues observes calculated session scores by score key.
+	ScoreValues = Default.NewHistogram("bean_score_value", "Distribution of calculated session scores by key.", ScoreBuckets, "key")
+	// RuleFires counts fired rules by rule id.
+	RuleFires = Default.NewCounter("bean_rule_fires_total", "Number of rule evaluations that fired by rule id.", "rule")
+	// MLRequests counts requests to ML services by model and outcome.
+	MLRequests = Default.NewCounter("bean_ml_requests_total", "Number of requests to ML services by model and outcome.", "model", "outcome")
+)
@@ -0,0 +1,302 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// collector is a metric family written in the Prometheus text exposition format.
+type collector interface {
+	// write writes the HELP and TYPE lines and all samples of the family.
+	write(w *bufio.Writer)
+}
+
+// Registry is a set of metric families exposed together.
+//
+// Registry is thread-safe.
+type Registry struct {
+	collectors []collector // registered families in registration order
+	mu         sync.Mutex  // mutex to protect access to collectors
+}
+
+// register adds the family to the registry.
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.collectors = append(r.collectors, c)
+}
+
+// Handler returns an HTTP handler that writes all registered families
+// in the Prometheus text exposition format (version 0.0.4).
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		r.mu.Lock()
+		collectors := slices.Clone(r.collectors)
+		r.mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		bw := bufio.NewWriter(w)
+		for _, c := range collectors {
+			c.write(bw)
+		}
+		bw.Flush()
+	})
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// desc describes a metric family.
+type desc struct {
+	name   string   // metric name
+	help   string   // help text
+	labels []string // label names
+}
+
+// writeHeader writes the HELP and TYPE lines of the family.
+func (d *desc) writeHeader(w *bufio.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, kind)
+}
+
+// key joins label values into a map key. Panics if the number of values does not match the labels.
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s: expected %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelPairs formats the label pairs of a sample, with an optional extra pair (e.g., le).
+func (d *desc) labelPairs(key string, extra ...string) string {
+	if len(d.labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+
+	var values []string
+	if len(d.labels) > 0 {
+		values = strings.Split(key, "\xff")
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, label := range d.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(label)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extra[i])
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(extra[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// escapeLabel escapes a label value according to the text exposition format.
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(value)
+}
+
+// formatFloat formats a sample value.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// sortedKeys returns the keys of the series in lexical order, so the output is stable.
+func sortedKeys(series *sync.Map) []string {
+	var keys []string
+	series.Range(func(k, _ any) bool {
+		keys = append(keys, k.(string))
+		return true
+	})
+	slices.Sort(keys)
+	return keys
+}
+
+// Counter is a family of monotonically increasing values partitioned by labels.
+type Counter struct {
+	desc
+	series sync.Map // *atomic.Uint64 with float64 bits by label key
+}
+
+// Inc increments the counter with the label values by 1.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v to the counter with the label values. Negative values are ignored.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+
+	value, found := c.series.Load(c.key(labelValues))
+	if !found {
+		value, _ = c.series.LoadOrStore(c.key(labelValues), &atomic.Uint64{})
+	}
+	bits := value.(*atomic.Uint64)
+	for {
+		old := bits.Load()
+		if bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// Value returns the current value of the counter with the label values.
+func (c *Counter) Value(labelValues ...string) float64 {
+	value, found := c.series.Load(c.key(labelValues))
+	if !found {
+		return 0
+	}
+	return math.Float64frombits(value.(*atomic.Uint64).Load())
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.writeHeader(w, "counter")
+	if len(c.labels) == 0 {
+		fmt.Fprintf(w, "%s %s\n", c.name, formatFloat(c.Value()))
+		return
+	}
+
+	for _, key := range sortedKeys(&c.series) {
+		value, _ := c.series.Load(key)
+		v := math.Float64frombits(value.(*atomic.Uint64).Load())
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(key), formatFloat(v))
+	}
+}
+
+// NewCounter creates a counter family and registers it in the registry.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name: name, help: help, labels: labels}}
+	r.register(c)
+	return c
+}
+
+// GaugeFunc is a gauge whose value is provided by a function at collection time.
+type GaugeFunc struct {
+	desc
+	fn atomic.Pointer[func() float64] // value provider, nil until bound
+}
+
+// Bind sets the function providing the gauge value. The gauge reports 0 until bound.
+func (g *GaugeFunc) Bind(fn func() float64) {
+	g.fn.Store(&fn)
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	var v float64
+	if fn := g.fn.Load(); fn != nil {
+		v = (*fn)()
+	}
+
+	g.writeHeader(w, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(v))
+}
+
+// NewGaugeFunc creates an unbound gauge and registers it in the registry.
+func (r *Registry) NewGaugeFunc(name, help string) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{name: name, help: help}}
+	r.register(g)
+	return g
+}
+
+// histogramSeries is a single histogram partition.
+type histogramSeries struct {
+	counts []uint64 // observations by bucket, not cumulative; the last one is +Inf
+	sum    float64  // sum of observations
+	count  uint64   // number of observations
+	mu     sync.Mutex
+}
+
+// Histogram is a family of value distributions over fixed buckets partitioned by labels.
+type Histogram struct {
+	desc
+	buckets []float64 // upper bounds of the buckets in ascending order
+	series  sync.Map  // *histogramSeries by label key
+}
+
+// Observe adds an observation to the histogram with the label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	value, found := h.series.Load(h.key(labelValues))
+	if !found {
+		value, _ = h.series.LoadOrStore(h.key(labelValues), &histogramSeries{counts: make([]uint64, len(h.buckets)+1)})
+	}
+	s := value.(*histogramSeries)
+
+	i, _ := slices.BinarySearch(h.buckets, v)
+	s.mu.Lock()
+	s.counts[i]++
+	s.sum += v
+	s.count++
+	s.mu.Unlock()
+}
+
+// Count returns the number of observations of the histogram with the label values.
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	value, found := h.series.Load(h.key(labelValues))
+	if !found {
+		return 0
+	}
+	s := value.(*histogramSeries)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.writeHeader(w, "histogram")
+	for _, key := range sortedKeys(&h.series) {
+		value, _ := h.series.Load(key)
+		s := value.(*histogramSeries)
+
+		s.mu.Lock()
+		counts := slices.Clone(s.counts)
+		sum, count := s.sum, s.count
+		s.mu.Unlock()
+
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(key, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(key, "le", "+Inf"), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(key), formatFloat(sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(key), count)
+	}
+}
+
+// NewHistogram creates a histogram family with the bucket upper bounds and registers it in the registry.
+// Buckets must be sorted in ascending order; the +Inf bucket is added automatically.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{desc: desc{name: name, help: help, labels: labels}, buckets: buckets}
+	r.register(h)
+	return h
+}
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scrape returns the exposition of the registry.
+func scrape(t *testing.T, r *Registry) string {
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, 200, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain; version=0.0.4")
+	body, _ := io.ReadAll(rec.Body)
+	return string(body)
+}
+
+func TestCounter(t *testing.T) {
+	r := NewRegistry()
+	plain := r.NewCounter("test_total", "Plain counter.")
+	labeled := r.NewCounter("test_labeled_total", "Labeled counter.", "reason")
+
+	plain.Inc()
+	plain.Add(2)
+	plain.Add(-1)
+	labeled.Inc("b")
+	labeled.Inc("a")
+	labeled.Inc(`quote"d`)
+
+	assert.Equal(t, float64(3), plain.Value())
+	assert.Equal(t, `# HELP test_total Plain counter.
+# TYPE test_total counter
+test_total 3
+# HELP test_labeled_total Labeled counter.
+# TYPE test_labeled_total counter
+test_labeled_total{reason="a"} 1
+test_labeled_total{reason="b"} 1
+test_labeled_total{reason="quote\"d"} 1
+`, scrape(t, r))
+}
+
+func TestCounter_LabelMismatch(t *testing.T) {
+	c := NewRegistry().NewCounter("test_total", "Counter.", "reason")
+	assert.Panics(t, func() { c.Inc() })
+}
+
+func TestCounter_Concurrent(t *testing.T) {
+	c := NewRegistry().NewCounter("test_total", "Counter.", "reason")
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				c.Inc("a")
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, float64(10000), c.Value("a"))
+}
+
+func TestGaugeFunc(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGaugeFunc("test_active", "Gauge.")
+	assert.Contains(t, scrape(t, r), "test_active 0\n", "unbound gauge reports 0")
+
+	g.Bind(func() float64 { return 42 })
+	assert.Contains(t, scrape(t, r), "test_active 42\n")
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("test_seconds", "Histogram.", []float64{0.1, 1}, "code")
+
+	h.Observe(0.05, "200")
+	h.Observe(0.1, "200")
+	h.Observe(0.5, "200")
+	h.Observe(5, "200")
+
+	assert.Equal(t, uint64(4), h.Count("200"))
+	assert.Equal(t, `# HELP test_seconds Histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{code="200",le="0.1"} 2
+test_seconds_bucket{code="200",le="1"} 3
+test_seconds_bucket{code="200",le="+Inf"} 4
+test_seconds_sum{code="200"} 5.65
+test_seconds_count{code="200"} 4
+`, scrape(t, r))
+}
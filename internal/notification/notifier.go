@@ -0,0 +1,234 @@
+package notification
+
+import (
+	"bean/internal/score"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// evaluationTimeout limits the score calculation of a single session.
+const evaluationTimeout = 10 * time.Second
+
+// evaluationWorkers is the number of sessions evaluated concurrently by Serve,
+// so a slow score calculation doesn't delay the notifications of other sessions.
+const evaluationWorkers = 8
+
+// ScoreFunc calculates the score of the session.
+type ScoreFunc func(ctx context.Context, token string) (score.Result, error)
+
+// session is the last notified state of a session.
+type session struct {
+	verdict string // last verdict
+	matched []bool // whether the score matched the condition of each target
+}
+
+// Notifier watches sessions with ingested traces and notifies targets when the verdict
+// of a session changes. A target is notified if the new score matches its condition and
+// either the verdict changed or the score started to match the condition.
+// Scores are calculated in the background by Serve with a small pool of workers; pending
+// sessions are coalesced, so a session with several traces ingested in a row is evaluated once.
+// A session is never evaluated by two workers at the same time, so its notifications keep the order.
+//
+// Notifier is thread-safe.
+type Notifier struct {
+	score    ScoreFunc           // session score calculation
+	targets  []Target            // notified targets
+	sessions map[string]*session // last notified state by session token
+	pending  map[string]struct{} // sessions waiting for evaluation
+	running  map[string]bool     // sessions being evaluated, true if forgotten during the evaluation
+	signal   chan struct{}       // signaled when a session may be taken from pending
+	done     chan struct{}       // closed to stop Serve
+	stopOnce sync.Once           // guards closing of done
+	mu       sync.Mutex          // mutex to protect access to sessions, pending and running
+}
+
+// Watch schedules the evaluation of the session. Never blocks.
+func (n *Notifier) Watch(token string) {
+	n.mu.Lock()
+	n.pending[token] = struct{}{}
+	n.mu.Unlock()
+
+	n.wake()
+}
+
+// Forget removes the state of an expired session.
+func (n *Notifier) Forget(token string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	delete(n.sessions, token)
+	delete(n.pending, token)
+	if _, found := n.running[token]; found {
+		n.running[token] = true
+	}
+}
+
+// Erase forgets the session and removes its undelivered events from the targets.
+// Returns the number of removed events.
+func (n *Notifier) Erase(token string) (int, error) {
+	n.Forget(token)
+
+	var erased int
+	var errs []error
+	for _, target := range n.targets {
+		count, err := target.Erase(token)
+		erased += count
+		errs = append(errs, err)
+	}
+
+	return erased, errors.Join(errs...)
+}
+
+// Serve evaluates watched sessions until Stop is called.
+// The method blocks execution and should be called in a separate goroutine.
+func (n *Notifier) Serve() {
+	var wg sync.WaitGroup
+	for range evaluationWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.work()
+		}()
+	}
+	wg.Wait()
+}
+
+// work evaluates pending sessions one by one until Stop is called.
+func (n *Notifier) work() {
+	for {
+		select {
+		case <-n.done:
+			return
+		default:
+		}
+
+		token, found := n.take()
+		if !found {
+			select {
+			case <-n.done:
+				return
+			case <-n.signal:
+			}
+			continue
+		}
+
+		n.evaluate(token)
+
+		n.mu.Lock()
+		delete(n.running, token)
+		_, watched := n.pending[token]
+		n.mu.Unlock()
+		if watched {
+			// The session was watched again during the evaluation
+			n.wake()
+		}
+	}
+}
+
+// take removes a pending session that is not being evaluated and marks it as running.
+// Wakes another worker if more sessions are pending.
+// Returns false if there is no such session.
+func (n *Notifier) take() (string, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for token := range n.pending {
+		if _, found := n.running[token]; found {
+			continue
+		}
+		delete(n.pending, token)
+		n.running[token] = false
+		if len(n.pending) > 0 {
+			n.wake()
+		}
+		return token, true
+	}
+	return "", false
+}
+
+// wake signals a waiting worker. Never blocks.
+func (n *Notifier) wake() {
+	select {
+	case n.signal <- struct{}{}:
+	default:
+	}
+}
+
+// Stop stops Serve. Pending sessions are not evaluated.
+func (n *Notifier) Stop() {
+	n.stopOnce.Do(func() { close(n.done) })
+}
+
+// evaluate calculates the score of the session and notifies the matching targets.
+// The result is dropped if the session is forgotten during the calculation.
+func (n *Notifier) evaluate(token string) {
+	ctx, cancel := context.WithTimeout(context.Background(), evaluationTimeout)
+	defer cancel()
+
+	result, err := n.score(ctx, token)
+	if err != nil {
+		slog.Warn("Notification score calculation failed", "id", token, "error", err)
+		return
+	}
+
+	n.mu.Lock()
+	if n.running[token] {
+		n.mu.Unlock()
+		return
+	}
+	state, found := n.sessions[token]
+	if !found {
+		state = &session{matched: make([]bool, len(n.targets))}
+		n.sessions[token] = state
+	}
+	previous := state.verdict
+	state.verdict = result.Verdict
+
+	var notified []Target
+	for i, target := range n.targets {
+		condition := target.Condition()
+		matched := condition.Match(result)
+		if matched && (!state.matched[i] || previous != result.Verdict) {
+			notified = append(notified, target)
+		}
+		state.matched[i] = matched
+	}
+	n.mu.Unlock()
+
+	if len(notified) == 0 {
+		return
+	}
+
+	event := Event{
+		Token:           token,
+		Verdict:         result.Verdict,
+		PreviousVerdict: previous,
+		Score:           result.Score,
+		Time:            time.Now(),
+	}
+	for _, target := range notified {
+		target.Enqueue(event)
+	}
+}
+
+// NewNotifier creates a new instance of Notifier.
+// Parameters:
+// - score: function calculating the session score
+// - targets: notified targets
+//
+// Returns a pointer to the initialized notifier.
+// To evaluate watched sessions, call Serve in a separate goroutine.
+func NewNotifier(score ScoreFunc, targets []Target) *Notifier {
+	return &Notifier{
+		score:    score,
+		targets:  targets,
+		sessions: make(map[string]*session),
+		pending:  make(map[string]struct{}),
+		running:  make(map[string]bool),
+		signal:   make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+}
@@ -0,0 +1,215 @@
+package notification
+
+import (
+	"bean/internal/score"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingTarget records enqueued events.
+type recordingTarget struct {
+	condition Condition
+	events    []Event
+	mu        sync.Mutex
+}
+
+func (rt *recordingTarget) Condition() Condition {
+	return rt.condition
+}
+
+func (rt *recordingTarget) Enqueue(event Event) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.events = append(rt.events, event)
+}
+
+func (rt *recordingTarget) Erase(token string) (int, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	kept := rt.events[:0]
+	for _, event := range rt.events {
+		if event.Token != token {
+			kept = append(kept, event)
+		}
+	}
+	erased := len(rt.events) - len(kept)
+	rt.events = kept
+	return erased, nil
+}
+
+func (rt *recordingTarget) verdicts() []string {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	var verdicts []string
+	for _, event := range rt.events {
+		verdicts = append(verdicts, event.PreviousVerdict+">"+event.Verdict)
+	}
+	return verdicts
+}
+
+func TestCondition_Match(t *testing.T) {
+	result := score.Result{Score: score.Score{"automation": 0.7}, Verdict: "suspicious"}
+
+	tests := []struct {
+		name      string
+		condition Condition
+		expected  bool
+	}{
+		{"empty", Condition{}, true},
+		{"verdict", Condition{Verdicts: []string{"bot", "suspicious"}}, true},
+		{"other verdict", Condition{Verdicts: []string{"bot"}}, false},
+		{"score reached", Condition{MinScore: score.Score{"automation": 0.7}}, true},
+		{"score not reached", Condition{MinScore: score.Score{"automation": 0.8}}, false},
+		{"missing key", Condition{MinScore: score.Score{"fraud": 0.1}}, false},
+		{"verdict and score", Condition{Verdicts: []string{"suspicious"}, MinScore: score.Score{"automation": 0.8}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.condition.Match(result))
+		})
+	}
+}
+
+func TestNotifier_VerdictChanges(t *testing.T) {
+	var results []score.Result
+	var mu sync.Mutex
+	scoreFn := func(_ context.Context, token string) (score.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		result := results[0]
+		results = results[1:]
+		return result, nil
+	}
+
+	bots := &recordingTarget{condition: Condition{Verdicts: []string{"bot"}}}
+	all := &recordingTarget{}
+	highScore := &recordingTarget{condition: Condition{MinScore: score.Score{"automation": 0.9}}}
+	n := NewNotifier(scoreFn, []Target{bots, all, highScore})
+
+	// Evaluate synchronously to control the order
+	for _, r := range []score.Result{
+		{Score: score.Score{"automation": 0.1}, Verdict: "human"},
+		{Score: score.Score{"automation": 0.2}, Verdict: "human"},
+		{Score: score.Score{"automation": 0.8}, Verdict: "bot"},
+		{Score: score.Score{"automation": 0.95}, Verdict: "bot"},
+		{Score: score.Score{"automation": 0.3}, Verdict: "human"},
+		{Score: score.Score{"automation": 0.9}, Verdict: "bot"},
+	} {
+		results = append(results, r)
+		n.evaluate("abc")
+	}
+
+	assert.Equal(t, []string{"human>bot", "human>bot"}, bots.verdicts())
+	assert.Equal(t, []string{">human", "human>bot", "bot>human", "human>bot"}, all.verdicts())
+	assert.Equal(t, []string{"bot>bot", "human>bot"}, highScore.verdicts(), "score conditions notify when they start to match")
+}
+
+func TestNotifier_Serve(t *testing.T) {
+	scoreFn := func(_ context.Context, token string) (score.Result, error) {
+		if token == "missing" {
+			return score.Result{}, errors.New("session not found")
+		}
+		return score.Result{Score: score.Score{"automation": 1}, Verdict: "bot"}, nil
+	}
+
+	target := &recordingTarget{}
+	n := NewNotifier(scoreFn, []Target{target})
+	go n.Serve()
+	defer n.Stop()
+
+	n.Watch("missing")
+	n.Watch("abc")
+	n.Watch("abc")
+	require.Eventually(t, func() bool { return len(target.verdicts()) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, "abc", target.events[0].Token)
+
+	// A forgotten session is notified again
+	n.Forget("abc")
+	n.Watch("abc")
+	require.Eventually(t, func() bool { return len(target.verdicts()) == 2 }, time.Second, time.Millisecond)
+}
+
+func TestNotifier_ForgetDuringEvaluation(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	scoreFn := func(_ context.Context, token string) (score.Result, error) {
+		started <- struct{}{}
+		<-release
+		return score.Result{Score: score.Score{"automation": 1}, Verdict: "bot"}, nil
+	}
+
+	target := &recordingTarget{}
+	n := NewNotifier(scoreFn, []Target{target})
+	go n.Serve()
+	defer n.Stop()
+
+	n.Watch("abc")
+	<-started
+	n.Forget("abc")
+	release <- struct{}{}
+
+	// The session is not evaluated concurrently, so the first evaluation has finished
+	n.Watch("abc")
+	<-started
+	assert.Empty(t, target.verdicts(), "the result of the forgotten evaluation should be dropped")
+	release <- struct{}{}
+	require.Eventually(t, func() bool { return len(target.verdicts()) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{">bot"}, target.verdicts())
+}
+
+func TestNotifier_ConcurrentSessions(t *testing.T) {
+	const sessions = 4 * evaluationWorkers
+	delay := 100 * time.Millisecond
+
+	var mu sync.Mutex
+	running := make(map[string]bool)
+	var overlapped bool
+	scoreFn := func(_ context.Context, token string) (score.Result, error) {
+		mu.Lock()
+		overlapped = overlapped || running[token]
+		running[token] = true
+		mu.Unlock()
+
+		time.Sleep(delay)
+
+		mu.Lock()
+		running[token] = false
+		mu.Unlock()
+		return score.Result{Score: score.Score{"automation": 1}, Verdict: "bot"}, nil
+	}
+
+	target := &recordingTarget{}
+	n := NewNotifier(scoreFn, []Target{target})
+	go n.Serve()
+	defer n.Stop()
+
+	start := time.Now()
+	for i := range sessions {
+		n.Watch(fmt.Sprintf("session-%d", i))
+	}
+	// A session watched during its evaluation is evaluated again afterwards
+	time.Sleep(delay / 2)
+	n.Watch("session-0")
+
+	// Evaluated one by one, the sessions would take sessions * delay
+	require.Eventually(t, func() bool { return len(target.verdicts()) == sessions }, sessions*delay/2, time.Millisecond)
+	assert.Less(t, time.Since(start), sessions*delay/2)
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return !running["session-0"]
+	}, time.Second, time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.False(t, overlapped, "a session should not be evaluated concurrently")
+}
@@ -0,0 +1,201 @@
+package notification
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// spool is an append-only JSONL file of events that could not be delivered.
+// Events are redelivered in the order they were spooled.
+//
+// spool is thread-safe.
+type spool struct {
+	file    string     // path to the spool file
+	size    int        // number of spooled events
+	mu      sync.Mutex // mutex to protect access to the file
+	drainMu sync.Mutex // serializes drains, held during the delivery
+}
+
+// append writes the event to the end of the spool.
+func (s *spool) append(event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err = f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	s.size++
+
+	return nil
+}
+
+// len returns the number of spooled events.
+func (s *spool) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.size
+}
+
+// drain delivers the spooled events in order until a delivery fails.
+// Delivered events are removed from the spool, the rest are kept for the next drain.
+// Malformed lines are dropped. The spool is not locked during the delivery, so events
+// appended meanwhile are not blocked and are kept after the undelivered ones.
+func (s *spool) drain(deliver func(Event) error) error {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+
+	s.mu.Lock()
+	data, err := os.ReadFile(s.file)
+	if errors.Is(err, os.ErrNotExist) {
+		s.size = 0
+		s.mu.Unlock()
+		return nil
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var rest [][]byte
+	var deliverErr error
+	for _, line := range lines(data) {
+		if deliverErr == nil {
+			var event Event
+			if json.Unmarshal(line, &event) != nil {
+				continue
+			}
+			if deliverErr = deliver(event); deliverErr == nil {
+				continue
+			}
+		}
+		rest = append(rest, line)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Only append changes the file between drains, so the read part is still its prefix
+	current, err := os.ReadFile(s.file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if len(current) > len(data) {
+		rest = append(rest, lines(current[len(data):])...)
+	}
+
+	if err = s.rewrite(rest); err != nil {
+		return err
+	}
+
+	return deliverErr
+}
+
+// erase removes the events of the session from the spool and returns the number of removed events.
+// Waits for a drain in progress, so delivered events are not written back.
+func (s *spool) erase(token string) (int, error) {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	all := lines(data)
+	var rest [][]byte
+	for _, line := range all {
+		var event Event
+		if json.Unmarshal(line, &event) == nil && event.Token == token {
+			continue
+		}
+		rest = append(rest, line)
+	}
+
+	erased := len(all) - len(rest)
+	if erased == 0 {
+		return 0, nil
+	}
+	return erased, s.rewrite(rest)
+}
+
+// lines splits the spool content into non-empty lines.
+func lines(data []byte) [][]byte {
+	var result [][]byte
+	for line := range bytes.SplitSeq(data, []byte{'\n'}) {
+		if len(line) > 0 {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+// rewrite atomically replaces the spool content with the lines.
+func (s *spool) rewrite(lines [][]byte) error {
+	s.size = len(lines)
+	if len(lines) == 0 {
+		err := os.Remove(s.file)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.file), filepath.Base(s.file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	for _, line := range lines {
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+	if err = w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.file)
+}
+
+// newSpool opens the spool file and counts the events left from a previous run.
+func newSpool(file string) (*spool, error) {
+	s := &spool{file: file}
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.size = bytes.Count(data, []byte{'\n'})
+	return s, nil
+}
@@ -0,0 +1,70 @@
+package notification
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpool_AppendDuringDrain(t *testing.T) {
+	s, err := newSpool(filepath.Join(t.TempDir(), "test.jsonl"))
+	require.NoError(t, err)
+	require.NoError(t, s.append(Event{Token: "a"}))
+	require.NoError(t, s.append(Event{Token: "b"}))
+
+	// The delivery of "a" is blocked until an append completes, then "b" fails
+	appended := make(chan struct{})
+	go func() {
+		require.NoError(t, s.append(Event{Token: "c"}))
+		close(appended)
+	}()
+	err = s.drain(func(event Event) error {
+		if event.Token == "a" {
+			select {
+			case <-appended:
+				return nil
+			case <-time.After(time.Second):
+				return errors.New("append blocked by the delivery")
+			}
+		}
+		return errors.New("endpoint is down")
+	})
+	assert.EqualError(t, err, "endpoint is down")
+	assert.Equal(t, 2, s.len())
+
+	var delivered []string
+	require.NoError(t, s.drain(func(event Event) error {
+		delivered = append(delivered, event.Token)
+		return nil
+	}))
+	assert.Equal(t, []string{"b", "c"}, delivered, "events appended during the drain should be kept after the undelivered ones")
+	assert.Equal(t, 0, s.len())
+}
+
+func TestSpool_Erase(t *testing.T) {
+	s, err := newSpool(filepath.Join(t.TempDir(), "test.jsonl"))
+	require.NoError(t, err)
+
+	erased, err := s.erase("a")
+	require.NoError(t, err)
+	assert.Equal(t, 0, erased)
+
+	for _, token := range []string{"a", "b", "a"} {
+		require.NoError(t, s.append(Event{Token: token}))
+	}
+	erased, err = s.erase("a")
+	require.NoError(t, err)
+	assert.Equal(t, 2, erased)
+	assert.Equal(t, 1, s.len())
+
+	var delivered []string
+	require.NoError(t, s.drain(func(event Event) error {
+		delivered = append(delivered, event.Token)
+		return nil
+	}))
+	assert.Equal(t, []string{"b"}, delivered)
+}
@@ -0,0 +1,55 @@
+package notification
+
+import (
+	"bean/internal/score"
+	"slices"
+	"time"
+)
+
+// Event is a notification about a session whose score matched the conditions of a target.
+type Event struct {
+	// Token — session token.
+	Token string `json:"token"`
+	// Verdict — current verdict of the session.
+	Verdict string `json:"verdict"`
+	// PreviousVerdict — verdict of the session before the change, empty for a new session.
+	PreviousVerdict string `json:"previous_verdict"`
+	// Score — current session score.
+	Score score.Score `json:"score"`
+	// Time — time the change was detected.
+	Time time.Time `json:"time"`
+}
+
+// Condition selects the session scores a target is notified about.
+// The zero value matches every score.
+type Condition struct {
+	// Verdicts — verdicts that match; any verdict matches if empty.
+	Verdicts []string
+	// MinScore — minimal values of score keys; all of them must be reached.
+	MinScore score.Score
+}
+
+// Match reports whether the result satisfies the condition.
+func (c *Condition) Match(result score.Result) bool {
+	if len(c.Verdicts) > 0 && !slices.Contains(c.Verdicts, result.Verdict) {
+		return false
+	}
+
+	for key, min := range c.MinScore {
+		if result.Score[key] < min {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Target receives notification events.
+type Target interface {
+	// Condition returns the condition of the events the target is notified about.
+	Condition() Condition
+	// Enqueue schedules the delivery of the event. Must not block.
+	Enqueue(event Event)
+	// Erase removes the stored undelivered events of the session and returns their number.
+	Erase(token string) (int, error)
+}
@@ -0,0 +1,306 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// SignatureHeader contains the HMAC-SHA256 signature of the request: "sha256=<hex>".
+	SignatureHeader = "X-Bean-Signature"
+	// TimestampHeader contains the Unix time of the request included in the signature.
+	TimestampHeader = "X-Bean-Timestamp"
+)
+
+// responseError is returned when the webhook target responds with a non-2xx status.
+type responseError struct {
+	code   int    // HTTP status code
+	status string // HTTP status line
+}
+
+func (e *responseError) Error() string {
+	return fmt.Sprintf("webhook response error code=%d status=%s", e.code, e.status)
+}
+
+// retryable reports whether the request may succeed if repeated.
+// Client errors (4xx) except 429 are not retried.
+func (e *responseError) retryable() bool {
+	return e.code < 400 || e.code >= 500 || e.code == http.StatusTooManyRequests
+}
+
+// Webhook delivers events to an HTTP endpoint as JSON POST requests.
+// Events are queued and delivered asynchronously by Serve. Failed requests are retried
+// with exponential backoff and jitter. Events that could not be delivered, as well as
+// events that do not fit into the queue, are written to the spool file (if configured)
+// and redelivered once the endpoint is available again.
+//
+// If a secret is set, each request is signed: the TimestampHeader contains the Unix time
+// and the SignatureHeader contains "sha256=" followed by the hex-encoded HMAC-SHA256
+// of "<timestamp>.<body>".
+type Webhook struct {
+	name       string             // webhook name used in logs
+	url        string             // URL of the endpoint
+	secret     []byte             // signing secret, no signature if empty
+	condition  Condition          // condition of the delivered events
+	client     *http.Client       // HTTP client with the request timeout
+	retries    int                // number of retries after a failed request
+	backoff    time.Duration      // base delay between retries, doubled on each retry
+	queue      chan Event         // events waiting for delivery
+	spool      *spool             // undelivered events, nil if disabled
+	spoolRetry time.Duration      // interval between redeliveries of the spool
+	done       chan struct{}      // closed to stop Serve
+	finished   chan struct{}      // closed when Serve returns
+	serving    atomic.Bool        // whether Serve has been started
+	stopOnce   sync.Once          // guards closing of done
+	ctx        context.Context    // canceled on stop to interrupt deliveries
+	cancel     context.CancelFunc // cancels ctx
+}
+
+// Condition returns the condition of the events delivered by the webhook.
+func (wh *Webhook) Condition() Condition {
+	return wh.condition
+}
+
+// Enqueue schedules the delivery of the event. Never blocks: if the queue is full,
+// the event is written to the spool or dropped if the spool is disabled.
+func (wh *Webhook) Enqueue(event Event) {
+	select {
+	case wh.queue <- event:
+	default:
+		slog.Warn("Webhook queue is full", "webhook", wh.name, "token", event.Token)
+		wh.spill(event)
+	}
+}
+
+// Serve delivers queued events until Stop is called.
+// The spool is redelivered periodically and after each successful delivery.
+// On stop, the events left in the queue are written to the spool.
+func (wh *Webhook) Serve() {
+	wh.serving.Store(true)
+	defer close(wh.finished)
+
+	ticker := time.NewTicker(wh.spoolRetry)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-wh.done:
+			for {
+				select {
+				case event := <-wh.queue:
+					wh.spill(event)
+				default:
+					return
+				}
+			}
+		case event := <-wh.queue:
+			if err := wh.deliverWithRetries(event); err != nil {
+				slog.Warn("Webhook delivery failed", "webhook", wh.name, "token", event.Token, "error", err)
+				if retryable(err) {
+					wh.spill(event)
+				}
+				continue
+			}
+			wh.redeliver()
+		case <-ticker.C:
+			wh.redeliver()
+		}
+	}
+}
+
+// Stop stops Serve and waits until the queue is written to the spool.
+// The method is safe to call even if Serve has not been started yet.
+func (wh *Webhook) Stop() {
+	wh.stopOnce.Do(func() {
+		wh.cancel()
+		close(wh.done)
+	})
+	if wh.serving.Load() {
+		<-wh.finished
+	}
+}
+
+// Erase removes the spooled events of the session and returns their number.
+// Events waiting in the queue are not affected.
+func (wh *Webhook) Erase(token string) (int, error) {
+	if wh.spool == nil {
+		return 0, nil
+	}
+
+	return wh.spool.erase(token)
+}
+
+// spill writes the event to the spool, or drops it if the spool is disabled.
+func (wh *Webhook) spill(event Event) {
+	if wh.spool == nil {
+		slog.Error("Webhook event dropped", "webhook", wh.name, "token", event.Token)
+		return
+	}
+
+	if err := wh.spool.append(event); err != nil {
+		slog.Error("Webhook spool write failed", "webhook", wh.name, "token", event.Token, "error", err)
+	}
+}
+
+// redeliver delivers the spooled events, stopping at the first failure.
+func (wh *Webhook) redeliver() {
+	if wh.spool == nil || wh.spool.len() == 0 {
+		return
+	}
+
+	err := wh.spool.drain(func(event Event) error {
+		err := wh.deliver(event)
+		var respErr *responseError
+		if errors.As(err, &respErr) && !respErr.retryable() {
+			slog.Warn("Webhook spooled event rejected", "webhook", wh.name, "token", event.Token, "error", err)
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		slog.Debug("Webhook redelivery failed", "webhook", wh.name, "error", err)
+	}
+}
+
+// deliverWithRetries delivers the event and repeats the request on retryable errors.
+// The delay before the n-th retry is a random value in [0, backoff * 2^n) (full jitter).
+func (wh *Webhook) deliverWithRetries(event Event) error {
+	for attempt := 0; ; attempt++ {
+		err := wh.deliver(event)
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= wh.retries || !retryable(err) {
+			return err
+		}
+
+		var delay time.Duration
+		if limit := wh.backoff << attempt; limit > 0 {
+			delay = rand.N(limit)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-wh.done:
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// deliver performs a single request to the endpoint.
+func (wh *Webhook) deliver(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, _ := http.NewRequestWithContext(wh.ctx, "POST", wh.url, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if len(wh.secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, "sha256="+Sign(wh.secret, timestamp, body))
+	}
+
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &responseError{code: resp.StatusCode, status: resp.Status}
+	}
+
+	return nil
+}
+
+// WithRetries enables retries of failed requests.
+// Parameters:
+//   - retries: number of retries after the first failed request
+//   - backoff: base delay between retries, doubled on each retry and randomized (jitter)
+//
+// Returns the webhook itself for chaining.
+func (wh *Webhook) WithRetries(retries int, backoff time.Duration) *Webhook {
+	wh.retries = retries
+	wh.backoff = backoff
+	return wh
+}
+
+// WithSpool enables spooling of undelivered events to the file.
+// Events left in the file by a previous run are redelivered.
+// Parameters:
+//   - file: path to the spool file
+//   - retry: interval between redeliveries of the spool
+//
+// Returns the webhook itself for chaining, or an error if the file cannot be read.
+func (wh *Webhook) WithSpool(file string, retry time.Duration) (*Webhook, error) {
+	s, err := newSpool(file)
+	if err != nil {
+		return nil, err
+	}
+
+	wh.spool = s
+	wh.spoolRetry = retry
+	return wh, nil
+}
+
+// Sign returns the hex-encoded HMAC-SHA256 of "<timestamp>.<body>" with the secret.
+// Receivers compute the same value to verify the SignatureHeader.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryable reports whether a failed delivery may succeed if repeated.
+func retryable(err error) bool {
+	var respErr *responseError
+	return !errors.As(err, &respErr) || respErr.retryable()
+}
+
+// NewWebhook creates a new instance of Webhook.
+// Parameters:
+// - name: webhook name used in logs
+// - url: address of the endpoint receiving events
+// - secret: signing secret, requests are not signed if empty
+// - condition: condition of the delivered events
+// - timeout: timeout for a single HTTP request
+// - queueSize: maximum number of events waiting for delivery
+//
+// Returns a pointer to the initialized webhook without retries and spool.
+// Serve must be started to deliver events.
+func NewWebhook(name, url, secret string, condition Condition, timeout time.Duration, queueSize int) *Webhook {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Webhook{
+		name:       name,
+		url:        url,
+		secret:     []byte(secret),
+		condition:  condition,
+		client:     &http.Client{Timeout: timeout},
+		queue:      make(chan Event, queueSize),
+		spoolRetry: time.Minute,
+		done:       make(chan struct{}),
+		finished:   make(chan struct{}),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
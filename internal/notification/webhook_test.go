@@ -0,0 +1,180 @@
+package notification
+
+import (
+	"bean/internal/score"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver is a test webhook endpoint that records delivered events.
+// It responds with status while status is non-zero.
+type receiver struct {
+	secret string
+	status atomic.Int32
+	calls  atomic.Int32
+	events []Event
+	mu     sync.Mutex
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.calls.Add(1)
+	if status := rc.status.Load(); status != 0 {
+		w.WriteHeader(int(status))
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	if rc.secret != "" {
+		expected := "sha256=" + Sign([]byte(rc.secret), r.Header.Get(TimestampHeader), body)
+		if r.Header.Get(SignatureHeader) != expected {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var event Event
+	if json.Unmarshal(body, &event) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	rc.mu.Lock()
+	rc.events = append(rc.events, event)
+	rc.mu.Unlock()
+}
+
+func (rc *receiver) tokens() []string {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	var tokens []string
+	for _, event := range rc.events {
+		tokens = append(tokens, event.Token)
+	}
+	return tokens
+}
+
+// newReceiver starts a test webhook endpoint.
+func newReceiver(t *testing.T, secret string) (*receiver, *httptest.Server) {
+	rc := &receiver{secret: secret}
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+	return rc, srv
+}
+
+// serve starts the webhook and stops it at the end of the test.
+func serve(t *testing.T, wh *Webhook) {
+	go wh.Serve()
+	t.Cleanup(wh.Stop)
+}
+
+func TestWebhook_Signature(t *testing.T) {
+	rc, srv := newReceiver(t, "secret")
+	wh := NewWebhook("test", srv.URL, "secret", Condition{}, time.Second, 10)
+	serve(t, wh)
+
+	wh.Enqueue(Event{Token: "abc", Verdict: "bot", Score: score.Score{"automation": 0.9}})
+
+	require.Eventually(t, func() bool { return len(rc.tokens()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "bot", rc.events[0].Verdict)
+	assert.Equal(t, score.Score{"automation": 0.9}, rc.events[0].Score)
+}
+
+func TestWebhook_WrongSecret(t *testing.T) {
+	rc, srv := newReceiver(t, "secret")
+	wh := NewWebhook("test", srv.URL, "other", Condition{}, time.Second, 10).WithRetries(3, time.Millisecond)
+	serve(t, wh)
+
+	wh.Enqueue(Event{Token: "abc"})
+
+	require.Eventually(t, func() bool { return rc.calls.Load() == 1 }, time.Second, 5*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(1), rc.calls.Load(), "rejected requests should not be retried")
+	assert.Empty(t, rc.tokens())
+}
+
+func TestWebhook_Retries(t *testing.T) {
+	rc, srv := newReceiver(t, "")
+	rc.status.Store(http.StatusServiceUnavailable)
+	wh := NewWebhook("test", srv.URL, "", Condition{}, time.Second, 10).WithRetries(5, time.Millisecond)
+	serve(t, wh)
+
+	wh.Enqueue(Event{Token: "abc"})
+
+	require.Eventually(t, func() bool { return rc.calls.Load() >= 2 }, time.Second, time.Millisecond)
+	rc.status.Store(0)
+
+	require.Eventually(t, func() bool { return len(rc.tokens()) == 1 }, time.Second, 5*time.Millisecond)
+}
+
+func TestWebhook_Spool(t *testing.T) {
+	rc, srv := newReceiver(t, "")
+	rc.status.Store(http.StatusServiceUnavailable)
+	wh, err := NewWebhook("test", srv.URL, "", Condition{}, time.Second, 10).
+		WithSpool(filepath.Join(t.TempDir(), "test.jsonl"), 10*time.Millisecond)
+	require.NoError(t, err)
+	serve(t, wh)
+
+	// The endpoint is down — events are spooled in order
+	wh.Enqueue(Event{Token: "a"})
+	wh.Enqueue(Event{Token: "b"})
+	require.Eventually(t, func() bool { return wh.spool.len() == 2 }, time.Second, time.Millisecond)
+	assert.Empty(t, rc.tokens())
+
+	// The endpoint is back — spooled events are redelivered
+	rc.status.Store(0)
+	require.Eventually(t, func() bool { return len(rc.tokens()) == 2 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"a", "b"}, rc.tokens())
+	// A delivered event is removed from the spool after the response
+	require.Eventually(t, func() bool { return wh.spool.len() == 0 }, time.Second, time.Millisecond)
+}
+
+func TestWebhook_QueueOverflow(t *testing.T) {
+	rc, srv := newReceiver(t, "")
+	file := filepath.Join(t.TempDir(), "test.jsonl")
+	wh, err := NewWebhook("test", srv.URL, "", Condition{}, time.Second, 1).WithSpool(file, time.Hour)
+	require.NoError(t, err)
+
+	// Serve is not running — the second event does not fit into the queue
+	wh.Enqueue(Event{Token: "a"})
+	wh.Enqueue(Event{Token: "b"})
+	assert.Equal(t, 1, wh.spool.len())
+
+	// Serve delivers the queued event and then the spooled one
+	serve(t, wh)
+	require.Eventually(t, func() bool { return len(rc.tokens()) == 2 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"a", "b"}, rc.tokens())
+}
+
+func TestWebhook_SpoolSurvivesRestart(t *testing.T) {
+	rc, srv := newReceiver(t, "")
+	rc.status.Store(http.StatusServiceUnavailable)
+	file := filepath.Join(t.TempDir(), "test.jsonl")
+
+	wh, err := NewWebhook("test", srv.URL, "", Condition{}, time.Second, 10).WithSpool(file, time.Hour)
+	require.NoError(t, err)
+	go wh.Serve()
+	wh.Enqueue(Event{Token: "a"})
+	require.Eventually(t, func() bool { return wh.spool.len() == 1 }, time.Second, time.Millisecond)
+	wh.Stop()
+
+	rc.status.Store(0)
+	restarted, err := NewWebhook("test", srv.URL, "", Condition{}, time.Second, 10).WithSpool(file, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, restarted.spool.len())
+
+	serve(t, restarted)
+	restarted.Enqueue(Event{Token: "b"})
+	require.Eventually(t, func() bool { return len(rc.tokens()) == 2 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"b", "a"}, rc.tokens())
+}
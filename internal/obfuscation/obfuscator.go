@@ -0,0 +1,285 @@
+package obfuscation
+
+import (
+	"bean/internal/trace"
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
+	"math/rand/v2"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MappingField is the payload field with the ID of the field mapping of the collector variant.
+const MappingField = "_v"
+
+// Statuses of the field mapping of a decoded trace.
+const (
+	// StatusCurrent — the trace uses the current mapping or the one replaced by the last rotation.
+	StatusCurrent = "current"
+	// StatusStale — the trace uses an older mapping of the recent rotations.
+	StatusStale = "stale"
+	// StatusUnknown — the mapping of the trace is unknown: expired, forged or generated by another instance.
+	StatusUnknown = "unknown"
+	// StatusMissing — the trace has no mapping and uses the canonical field names.
+	StatusMissing = "missing"
+)
+
+// historySize is the number of the recent variants whose mappings are decoded.
+const historySize = 8
+
+// identifierPattern matches the identifiers and the words of the collector script.
+var identifierPattern = regexp.MustCompile(`[A-Za-z_$][\w$]*`)
+
+// variant is a generated collector script with its field mapping.
+type variant struct {
+	id     string            // mapping ID sent in the payload
+	period int64             // rotation period the variant was generated for
+	fields map[string]string // canonical field names by obfuscated key
+	script []byte            // obfuscated collector script
+}
+
+// Obfuscator generates variants of the collector script with randomized payload keys and
+// minified method names, and decodes the received traces back to the canonical field names.
+// The variant is replaced every rotation period; the mappings of the recent variants are
+// still decoded, so pages loaded before a rotation keep working and are reported as stale.
+//
+// Variants are either derived from a secret and the rotation period, so instances sharing
+// the secret generate the same variants and keep them on restart, or generated randomly
+// per instance.
+//
+// Obfuscator is thread-safe.
+type Obfuscator struct {
+	source    string                // minified collector script
+	fields    []string              // canonical payload fields of the script
+	canonical map[string]bool       // canonical payload fields, for lookups
+	methods   []string              // renamed methods of the script
+	functions []string              // renamed top-level functions of the script
+	words     map[string]bool       // identifiers and words of the script, not used as new names
+	secret    []byte                // secret deriving the variants, random variants if empty
+	rotate    time.Duration         // rotation period, zero to generate a single variant
+	variants  []*variant            // recent variants, the current one first
+	handlers  []func(script []byte) // handlers of rotated scripts
+	now       func() time.Time      // clock, replaced in tests
+	mu        sync.RWMutex          // mutex to protect access to variants
+	ticker    *time.Ticker          // ticker of the rotation checks
+}
+
+// Script returns the current variant of the collector script.
+func (o *Obfuscator) Script() []byte {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return o.variants[0].script
+}
+
+// MappingID returns the ID of the current field mapping.
+func (o *Obfuscator) MappingID() string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return o.variants[0].id
+}
+
+// Retention returns the time the mapping of a variant is still decoded after it is replaced,
+// so a cached variant of the script must not be used longer. Zero if the rotation is disabled.
+func (o *Obfuscator) Retention() time.Duration {
+	return o.rotate * (historySize - 1)
+}
+
+// OnRotate registers a handler called with the new variant of the script after every rotation.
+func (o *Obfuscator) OnRotate(handler func(script []byte)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.handlers = append(o.handlers, handler)
+}
+
+// Decode renames the obfuscated keys of the trace to the canonical field names using the mapping
+// referenced by the mapping field, and returns the status of the mapping.
+// Canonical field names sent together with a mapping are dropped, since the collector never sends them.
+// Traces without a mapping or with an unknown one are left unchanged, except the mapping field is removed.
+func (o *Obfuscator) Decode(t trace.Trace) string {
+	value, found := t[MappingField]
+	if !found {
+		return StatusMissing
+	}
+	delete(t, MappingField)
+
+	id, _ := value.(string)
+	o.mu.RLock()
+	index := slices.IndexFunc(o.variants, func(v *variant) bool { return v.id == id })
+	var mapping *variant
+	if index >= 0 {
+		mapping = o.variants[index]
+	}
+	o.mu.RUnlock()
+	if mapping == nil {
+		return StatusUnknown
+	}
+
+	decoded := make(trace.Trace, len(mapping.fields))
+	for key, value := range t {
+		if canonical, found := mapping.fields[key]; found {
+			decoded[canonical] = value
+			delete(t, key)
+		} else if o.canonical[key] {
+			delete(t, key)
+		}
+	}
+	maps.Copy(t, decoded)
+
+	if index <= 1 {
+		return StatusCurrent
+	}
+	return StatusStale
+}
+
+// period returns the rotation period of the time.
+func (o *Obfuscator) period(now time.Time) int64 {
+	if o.rotate == 0 {
+		return 0
+	}
+	return now.UnixNano() / int64(o.rotate)
+}
+
+// seed returns the seed of the variant of the period: derived from the secret, or random.
+func (o *Obfuscator) seed(period int64) [32]byte {
+	var seed [32]byte
+	if len(o.secret) == 0 {
+		crand.Read(seed[:])
+		return seed
+	}
+
+	mac := hmac.New(sha256.New, o.secret)
+	mac.Write([]byte("collector:" + strconv.FormatInt(period, 10)))
+	copy(seed[:], mac.Sum(nil))
+	return seed
+}
+
+// generate creates the variant of the period.
+func (o *Obfuscator) generate(period int64) *variant {
+	seed := o.seed(period)
+	rnd := rand.New(rand.NewChaCha8(seed))
+	used := maps.Clone(o.words)
+
+	id := sha256.Sum256(seed[:])
+	v := &variant{
+		id:     hex.EncodeToString(id[:4]),
+		period: period,
+		fields: make(map[string]string, len(o.fields)),
+	}
+	keys := make(map[string]string, len(o.fields))
+	for _, field := range o.fields {
+		key := identifier(rnd, used)
+		keys[field] = key
+		v.fields[key] = field
+	}
+	methods := make(map[string]string, len(o.methods))
+	for _, method := range o.methods {
+		methods[method] = identifier(rnd, used)
+	}
+	functions := make(map[string]string, len(o.functions))
+	for _, function := range o.functions {
+		functions[function] = identifier(rnd, used)
+	}
+
+	script := renamePayload(o.source, keys, v.id)
+	script = renameMethods(script, methods)
+	script = renameFunctions(script, functions)
+	v.script = []byte(script)
+	return v
+}
+
+// rotateVariant generates the variant of the current period if the period has changed,
+// and notifies the handlers about the new script.
+func (o *Obfuscator) rotateVariant() {
+	period := o.period(o.now())
+	o.mu.RLock()
+	changed := o.variants[0].period != period
+	o.mu.RUnlock()
+	if !changed {
+		return
+	}
+
+	v := o.generate(period)
+	o.mu.Lock()
+	o.variants = append([]*variant{v}, o.variants[:min(len(o.variants), historySize-1)]...)
+	handlers := slices.Clone(o.handlers)
+	o.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(v.script)
+	}
+}
+
+// Serve starts a background process that replaces the variant when its rotation period ends.
+// Does nothing if the rotation is disabled. The method blocks execution and should be called
+// in a separate goroutine. Use the Stop method to stop.
+func (o *Obfuscator) Serve() {
+	if o.rotate == 0 {
+		return
+	}
+	o.ticker = time.NewTicker(min(o.rotate, time.Minute))
+	for range o.ticker.C {
+		o.rotateVariant()
+	}
+}
+
+// Stop stops the rotation.
+// The method is safe to call even if Serve has not been started yet.
+func (o *Obfuscator) Stop() {
+	if o.ticker != nil {
+		o.ticker.Stop()
+	}
+}
+
+// NewObfuscator creates a new instance of Obfuscator.
+//
+// Parameters:
+//   - script: collector script with the payload literal "const payload = {…};"
+//   - secret: secret deriving the variants from the rotation period; if empty, variants are random
+//   - rotate: rotation period of the variant, zero to generate a single variant at startup
+//
+// Returns a pointer to the Obfuscator, or ErrNoPayload if the script has no payload literal.
+// With a secret, the variants of the recent periods are generated too, so their traces are decoded
+// after a restart. To rotate the variant, call Serve in a separate goroutine.
+func NewObfuscator(script []byte, secret string, rotate time.Duration) (*Obfuscator, error) {
+	source := minify(string(script))
+	fields, err := payloadFields(source)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Obfuscator{
+		source:    source,
+		fields:    fields,
+		canonical: make(map[string]bool, len(fields)),
+		methods:   declaredNames(string(script), methodDeclaration),
+		functions: declaredNames(string(script), functionDeclaration),
+		words:     map[string]bool{MappingField: true},
+		secret:    []byte(secret),
+		rotate:    rotate,
+		now:       time.Now,
+	}
+	for _, field := range fields {
+		o.canonical[field] = true
+	}
+	for _, word := range identifierPattern.FindAllString(source, -1) {
+		o.words[word] = true
+	}
+
+	period := o.period(o.now())
+	o.variants = []*variant{o.generate(period)}
+	if len(o.secret) > 0 && rotate > 0 {
+		for i := int64(1); i < historySize; i++ {
+			o.variants = append(o.variants, o.generate(period-i))
+		}
+	}
+	return o, nil
+}
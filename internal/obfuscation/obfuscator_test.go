@@ -0,0 +1,158 @@
+package obfuscation
+
+import (
+	"bean/internal/trace"
+	"bean/public"
+	"maps"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keyOf returns the obfuscated key of the canonical field in the current variant.
+func keyOf(o *Obfuscator, field string) string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	for key, canonical := range o.variants[0].fields {
+		if canonical == field {
+			return key
+		}
+	}
+	return ""
+}
+
+func TestObfuscator_Script(t *testing.T) {
+	o, err := NewObfuscator(public.Collector, "", 0)
+	require.NoError(t, err)
+	script := string(o.Script())
+
+	assert.Contains(t, o.fields, "mouseMoves")
+	assert.Contains(t, o.fields, "seq")
+	assert.Contains(t, script, MappingField+`: "`+o.MappingID()+`",`)
+	assert.Contains(t, script, keyOf(o, "mouseMoves")+": metrics.mouseMoves,")
+	assert.NotContains(t, script, "mouseMoves: metrics.mouseMoves")
+
+	for _, name := range []string{"sendToServer", "getCalculatedMetrics", "challengeSolver", "this.log("} {
+		assert.NotContains(t, script, name, "name should be minified")
+	}
+	for _, name := range []string{"class BehavioralMetricsCollector", "\nstart() {", "\nsolveChallenge() {", "console.log("} {
+		assert.Contains(t, script, name, "public name should be kept")
+	}
+	assert.NotContains(t, script, "/**", "comments should be removed")
+
+	other, err := NewObfuscator(public.Collector, "", 0)
+	require.NoError(t, err)
+	assert.NotEqual(t, o.MappingID(), other.MappingID(), "variants without a secret should be random")
+
+	_, err = NewObfuscator([]byte("console.log('no payload');"), "", 0)
+	assert.ErrorIs(t, err, ErrNoPayload)
+}
+
+func TestObfuscator_Decode(t *testing.T) {
+	o, err := NewObfuscator(public.Collector, "", 0)
+	require.NoError(t, err)
+
+	tr := trace.Trace{
+		MappingField:           o.MappingID(),
+		keyOf(o, "mouseMoves"): float64(3),
+		keyOf(o, "seq"):        float64(1),
+		"clicks":               float64(100),
+		"custom":               "kept",
+	}
+	assert.Equal(t, StatusCurrent, o.Decode(tr))
+	assert.Equal(t, trace.Trace{"mouseMoves": float64(3), "seq": float64(1), "custom": "kept"}, tr,
+		"canonical fields sent with a mapping should be dropped")
+
+	plain := trace.Trace{"mouseMoves": float64(3)}
+	assert.Equal(t, StatusMissing, o.Decode(plain))
+	assert.Equal(t, trace.Trace{"mouseMoves": float64(3)}, plain)
+
+	forged := trace.Trace{MappingField: "00000000", "mouseMoves": float64(3)}
+	assert.Equal(t, StatusUnknown, o.Decode(forged))
+	assert.Equal(t, trace.Trace{"mouseMoves": float64(3)}, forged)
+}
+
+func TestObfuscator_Rotate(t *testing.T) {
+	o, err := NewObfuscator(public.Collector, "", time.Hour)
+	require.NoError(t, err)
+	now := time.Now()
+	o.now = func() time.Time { return now }
+
+	var rotated []string
+	o.OnRotate(func(script []byte) { rotated = append(rotated, string(script)) })
+
+	first := trace.Trace{MappingField: o.MappingID(), keyOf(o, "clicks"): float64(1)}
+	replay := func() trace.Trace { return maps.Clone(first) }
+
+	o.rotateVariant()
+	assert.Empty(t, rotated, "variant should not change within the period")
+
+	now = now.Add(time.Hour)
+	o.rotateVariant()
+	require.Len(t, rotated, 1)
+	assert.Equal(t, string(o.Script()), rotated[0])
+	assert.NotEqual(t, first[MappingField], o.MappingID())
+
+	tr := replay()
+	assert.Equal(t, StatusCurrent, o.Decode(tr), "mapping replaced by the last rotation should be current")
+	assert.Equal(t, float64(1), tr["clicks"])
+
+	now = now.Add(time.Hour)
+	o.rotateVariant()
+	tr = replay()
+	assert.Equal(t, StatusStale, o.Decode(tr))
+	assert.Equal(t, float64(1), tr["clicks"], "stale mapping should be decoded")
+
+	for range historySize {
+		now = now.Add(time.Hour)
+		o.rotateVariant()
+	}
+	assert.Equal(t, StatusUnknown, o.Decode(replay()), "expired mapping should be unknown")
+	assert.Len(t, o.variants, historySize)
+}
+
+func TestObfuscator_Retention(t *testing.T) {
+	o, err := NewObfuscator(public.Collector, "", time.Hour)
+	require.NoError(t, err)
+	now := time.Now()
+	o.now = func() time.Time { return now }
+	first := trace.Trace{MappingField: o.MappingID()}
+
+	// A script cached until its replacement is decoded for the whole retention
+	for elapsed := time.Duration(0); elapsed < o.Retention(); elapsed += time.Hour {
+		now = now.Add(time.Hour)
+		o.rotateVariant()
+	}
+	assert.Equal(t, StatusStale, o.Decode(maps.Clone(first)))
+
+	now = now.Add(time.Hour)
+	o.rotateVariant()
+	assert.Equal(t, StatusUnknown, o.Decode(maps.Clone(first)))
+
+	static, err := NewObfuscator(public.Collector, "", 0)
+	require.NoError(t, err)
+	assert.Zero(t, static.Retention(), "variant without rotation is never replaced")
+}
+
+func TestObfuscator_Secret(t *testing.T) {
+	secret := strings.Repeat("s", 32)
+	a, err := NewObfuscator(public.Collector, secret, time.Hour)
+	require.NoError(t, err)
+	b, err := NewObfuscator(public.Collector, secret, time.Hour)
+	require.NoError(t, err)
+
+	assert.Equal(t, a.MappingID(), b.MappingID(), "instances sharing the secret should generate the same variant")
+	assert.Equal(t, a.Script(), b.Script())
+	assert.Len(t, a.variants, historySize, "variants of the recent periods should be restored")
+
+	previous := a.variants[2]
+	assert.Equal(t, StatusStale, b.Decode(trace.Trace{MappingField: previous.id}))
+
+	other, err := NewObfuscator(public.Collector, strings.Repeat("o", 32), time.Hour)
+	require.NoError(t, err)
+	assert.NotEqual(t, a.MappingID(), other.MappingID())
+}
@@ -0,0 +1,259 @@
+package obfuscation
+
+import (
+	"errors"
+	"math/rand/v2"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// payloadStart opens the object literal of the report payload in the collector script.
+const payloadStart = "const payload = {"
+
+// publicMethods are the collector methods callable by pages, which keep their names.
+var publicMethods = map[string]bool{
+	"constructor":    true,
+	"start":          true,
+	"stop":           true,
+	"getMetrics":     true,
+	"report":         true,
+	"reset":          true,
+	"solveChallenge": true,
+}
+
+var (
+	// methodDeclaration matches a method declaration of the collector class in the source.
+	methodDeclaration = regexp.MustCompile(`(?m)^  ([A-Za-z_$][\w$]*)\([^)]*\) \{$`)
+	// functionDeclaration matches a top-level function declaration.
+	functionDeclaration = regexp.MustCompile(`(?m)^function ([A-Za-z_$][\w$]*)\(`)
+	// payloadKey matches a key of the payload literal in the minified script.
+	payloadKey = regexp.MustCompile(`^([A-Za-z_$][\w$]*):`)
+)
+
+// ErrNoPayload is returned for a collector script without the payload literal.
+var ErrNoPayload = errors.New("collector script has no payload literal: " + payloadStart)
+
+// payloadBounds returns the start and the end of the payload literal body of the minified script.
+func payloadBounds(src string) (int, int, error) {
+	start := strings.Index(src, payloadStart)
+	if start < 0 {
+		return 0, 0, ErrNoPayload
+	}
+	start += len(payloadStart)
+	end := strings.Index(src[start:], "\n};")
+	if end < 0 {
+		return 0, 0, ErrNoPayload
+	}
+	return start, start + end, nil
+}
+
+// payloadFields returns the keys of the payload literal of the minified script.
+func payloadFields(src string) ([]string, error) {
+	start, end, err := payloadBounds(src)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []string{}
+	for _, line := range strings.Split(src[start:end], "\n") {
+		if match := payloadKey.FindStringSubmatch(line); match != nil {
+			fields = append(fields, match[1])
+		}
+	}
+	if len(fields) == 0 {
+		return nil, ErrNoPayload
+	}
+	return fields, nil
+}
+
+// declaredNames returns the names declared by the matches of the declaration expression,
+// except the public methods.
+func declaredNames(src string, declaration *regexp.Regexp) []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, match := range declaration.FindAllStringSubmatch(src, -1) {
+		if name := match[1]; !publicMethods[name] && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// stripComments removes the line and block comments of the script.
+// String, template and regular expression literals are kept as is.
+func stripComments(src string) string {
+	var out strings.Builder
+	out.Grow(len(src))
+
+	// last is the last significant character of the code, used to tell a regular
+	// expression literal from a division
+	last := byte('\n')
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			if i < len(src) {
+				out.WriteByte('\n')
+			}
+			last = '\n'
+			continue
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return out.String()
+			}
+			i += end + 3
+			continue
+		case c == '\'' || c == '"' || c == '`':
+			end := literalEnd(src, i, c)
+			out.WriteString(src[i:end])
+			i = end - 1
+		case c == '/' && strings.IndexByte("(,=:[!&|?{};\n", last) >= 0:
+			end := regexpEnd(src, i)
+			out.WriteString(src[i:end])
+			i = end - 1
+		default:
+			out.WriteByte(c)
+		}
+		if c != ' ' && c != '\t' && c != '\r' {
+			last = src[i]
+		}
+	}
+	return out.String()
+}
+
+// literalEnd returns the index after the string or template literal starting at i.
+func literalEnd(src string, i int, quote byte) int {
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		}
+	}
+	return len(src)
+}
+
+// regexpEnd returns the index after the regular expression literal starting at i, including its flags.
+func regexpEnd(src string, i int) int {
+	class := false
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '[':
+			class = true
+		case ']':
+			class = false
+		case '\n':
+			return j
+		case '/':
+			if class {
+				continue
+			}
+			j++
+			for j < len(src) && (src[j] >= 'a' && src[j] <= 'z') {
+				j++
+			}
+			return j
+		}
+	}
+	return len(src)
+}
+
+// minify removes the comments, the indentation and the empty lines of the script.
+// Line breaks are kept, so automatic semicolon insertion works as in the source.
+func minify(src string) string {
+	lines := strings.Split(stripComments(src), "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n") + "\n"
+}
+
+// renamePayload replaces the keys of the payload literal of the minified script
+// and adds the mapping field with the mapping ID as the first key.
+func renamePayload(src string, keys map[string]string, id string) string {
+	start, end, err := payloadBounds(src)
+	if err != nil {
+		return src
+	}
+
+	lines := strings.Split(src[start:end], "\n")
+	for i, line := range lines {
+		if match := payloadKey.FindStringSubmatch(line); match != nil {
+			if key, found := keys[match[1]]; found {
+				lines[i] = key + line[len(match[1]):]
+			}
+		}
+	}
+	return src[:start] + "\n" + MappingField + `: "` + id + `",` + strings.Join(lines, "\n") + src[end:]
+}
+
+// renameMethods replaces the declarations and the references of the methods of the minified script.
+// Methods are declared at the line start and referenced through this.
+func renameMethods(src string, names map[string]string) string {
+	return rename(src, `(?m)(^|this\.)`, names)
+}
+
+// renameFunctions replaces the declarations and the references of the top-level functions.
+// Functions are referenced by their name, not as a property.
+func renameFunctions(src string, names map[string]string) string {
+	return rename(src, `(^|[^\w$.])`, names)
+}
+
+// rename replaces the names following the prefix pattern in a single pass
+// of one regular expression matching all names.
+func rename(src, prefix string, names map[string]string) string {
+	if len(names) == 0 {
+		return src
+	}
+
+	quoted := make([]string, 0, len(names))
+	for name := range names {
+		quoted = append(quoted, regexp.QuoteMeta(name))
+	}
+	slices.Sort(quoted)
+	re := regexp.MustCompile(prefix + `(` + strings.Join(quoted, "|") + `)\b`)
+
+	var b strings.Builder
+	last := 0
+	for _, match := range re.FindAllStringSubmatchIndex(src, -1) {
+		// match[4]:match[5] is the name, the prefix before it is kept
+		b.WriteString(src[last:match[4]])
+		b.WriteString(names[src[match[4]:match[5]]])
+		last = match[5]
+	}
+	b.WriteString(src[last:])
+	return b.String()
+}
+
+// identifier returns a random identifier of 3–5 characters that is not used yet.
+// Identifiers contain a digit, so they never match a keyword or a canonical field name.
+func identifier(rnd *rand.Rand, used map[string]bool) string {
+	const letters = "abcdefghijklmnopqrstuvwxyz"
+	const alphanumeric = letters + "0123456789"
+	for {
+		length := 3 + rnd.IntN(3)
+		b := make([]byte, length)
+		b[0] = letters[rnd.IntN(len(letters))]
+		for i := 1; i < length; i++ {
+			b[i] = alphanumeric[rnd.IntN(len(alphanumeric))]
+		}
+		b[1+rnd.IntN(length-1)] = byte('0' + rnd.IntN(10))
+
+		if name := string(b); !used[name] {
+			used[name] = true
+			return name
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package obfuscation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{"line", "a = 1; // comment\nb = 2;", "a = 1; \nb = 2;"},
+		{"block", "a = /* comment */ 1;", "a =  1;"},
+		{"doc", "/**\n * Doc\n */\nclass A {}", "\nclass A {}"},
+		{"string", `s = "http://example.com"; t = '/* not a comment */';`, `s = "http://example.com"; t = '/* not a comment */';`},
+		{"escaped quote", `s = "a\"//b";`, `s = "a\"//b";`},
+		{"template", "s = `${a} // b`;", "s = `${a} // b`;"},
+		{"regexp", `p = /https?:\/\//; // url`, `p = /https?:\/\//; `},
+		{"regexp class", `p = /[/]+/g;`, `p = /[/]+/g;`},
+		{"division", `a = b / c / d; // ratio`, `a = b / c / d; `},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, stripComments(tt.src))
+		})
+	}
+}
+
+func TestMinify(t *testing.T) {
+	src := "class A {\n  // Start\n  start() {\n    return 1;\n  }\n\n}\n"
+	assert.Equal(t, "class A {\nstart() {\nreturn 1;\n}\n}\n", minify(src))
+}
+
+func TestRenameMethods(t *testing.T) {
+	src := "log(message) {\nconsole.log(message);\n}\nrun() {\nthis.log('log');\nthis.logger = 1;\n}\n"
+	expected := "a1b(message) {\nconsole.log(message);\n}\nrun() {\nthis.a1b('log');\nthis.logger = 1;\n}\n"
+	assert.Equal(t, expected, renameMethods(src, map[string]string{"log": "a1b"}))
+}
@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// idleTimeout is the time after which the bucket of an inactive key is removed.
+const idleTimeout = 10 * time.Minute
+
+// bucket is the token bucket of a single key.
+type bucket struct {
+	tokens   float64   // available tokens
+	last     time.Time // time of the last refill
+	rejected int       // events rejected since the last Rejected call
+}
+
+// Limiter limits the rate of events by key (e.g., client IP or session token) with token buckets.
+// Each key has a bucket of burst tokens refilled at rate tokens per second; an event takes
+// one token and is rejected when the bucket is empty. Rejected events are counted per key.
+// Buckets of keys inactive for 10 minutes are removed by a background process.
+//
+// A nil Limiter allows all events.
+//
+// Limiter is thread-safe.
+type Limiter struct {
+	rate    float64            // tokens added per second
+	burst   float64            // bucket capacity
+	buckets map[string]*bucket // buckets by key
+	now     func() time.Time   // clock, replaced in tests
+	mu      sync.Mutex         // mutex to protect access to buckets
+	ticker  *time.Ticker       // ticker of the cleanup of inactive buckets
+}
+
+// Allow takes a token from the bucket of the key.
+// Returns false and counts the event as rejected if the bucket is empty.
+func (l *Limiter) Allow(key string) bool {
+	if l == nil {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, found := l.buckets[key]
+	if !found {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		b.rejected++
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// Rejected returns the number of events of the key rejected since the previous call
+// and resets the counter.
+func (l *Limiter) Rejected(key string) int {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, found := l.buckets[key]
+	if !found {
+		return 0
+	}
+	rejected := b.rejected
+	b.rejected = 0
+	return rejected
+}
+
+// Len returns the number of tracked keys.
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.buckets)
+}
+
+// cleanup removes the buckets of keys inactive for longer than idleTimeout.
+func (l *Limiter) cleanup() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > idleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Serve starts a background process that periodically (once a minute) removes
+// the buckets of inactive keys. The method blocks execution and should be called
+// in a separate goroutine. Use the Stop method to stop.
+func (l *Limiter) Serve() {
+	l.ticker = time.NewTicker(time.Minute)
+	for range l.ticker.C {
+		l.cleanup()
+	}
+}
+
+// Stop stops the background cleanup.
+// The method is safe to call even if Serve has not been started yet.
+func (l *Limiter) Stop() {
+	if l.ticker != nil {
+		l.ticker.Stop()
+	}
+}
+
+// NewLimiter creates a new instance of Limiter.
+//
+// Parameters:
+//   - rate: sustained rate of events per second for each key
+//   - burst: maximum number of events of a key allowed at once
+//
+// Returns a pointer to the Limiter. To remove inactive keys, call Serve in a separate goroutine.
+func NewLimiter(rate float64, burst int) *Limiter {
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
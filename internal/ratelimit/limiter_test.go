@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is a manually advanced clock.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+// newTestLimiter creates a limiter using the fake clock.
+func newTestLimiter(rate float64, burst int) (*Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	l := NewLimiter(rate, burst)
+	l.now = clock.Now
+	return l, clock
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	l, clock := newTestLimiter(2, 3)
+
+	for range 3 {
+		assert.True(t, l.Allow("a"), "burst should be allowed")
+	}
+	assert.False(t, l.Allow("a"))
+	assert.False(t, l.Allow("a"))
+	assert.True(t, l.Allow("b"), "keys should have separate buckets")
+
+	clock.now = clock.now.Add(500 * time.Millisecond)
+	assert.True(t, l.Allow("a"), "one token should be refilled")
+	assert.False(t, l.Allow("a"))
+
+	clock.now = clock.now.Add(time.Hour)
+	for range 3 {
+		assert.True(t, l.Allow("a"), "refill should be limited by burst")
+	}
+	assert.False(t, l.Allow("a"))
+}
+
+func TestLimiter_Rejected(t *testing.T) {
+	l, _ := newTestLimiter(1, 1)
+
+	l.Allow("a")
+	l.Allow("a")
+	l.Allow("a")
+
+	assert.Equal(t, 2, l.Rejected("a"))
+	assert.Equal(t, 0, l.Rejected("a"), "counter should be reset")
+	assert.Equal(t, 0, l.Rejected("unknown"))
+}
+
+func TestLimiter_Nil(t *testing.T) {
+	var l *Limiter
+	assert.True(t, l.Allow("a"))
+	assert.Equal(t, 0, l.Rejected("a"))
+}
+
+func TestLimiter_Cleanup(t *testing.T) {
+	l, clock := newTestLimiter(1, 1)
+	l.Allow("a")
+	clock.now = clock.now.Add(idleTimeout / 2)
+	l.Allow("b")
+
+	clock.now = clock.now.Add(idleTimeout/2 + time.Second)
+	l.cleanup()
+	assert.Equal(t, 1, l.Len(), "only the inactive key should be removed")
+}
+
+func TestLimiter_Concurrent(t *testing.T) {
+	l := NewLimiter(0, 100)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				if l.Allow("a") {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 100, allowed)
+	assert.Equal(t, 400, l.Rejected("a"))
+}
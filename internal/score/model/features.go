@@ -0,0 +1,55 @@
+package model
+
+import "bean/internal/trace"
+
+// TracesFeature is the name of the feature holding the number of traces in the session.
+const TracesFeature = "traces"
+
+// SessionFeatures derives model features from the session traces.
+// Each numeric trace field becomes a feature equal to the mean of its values
+// over the traces that contain it; booleans are counted as 0 and 1.
+// Non-numeric fields are ignored. The number of traces is stored as TracesFeature.
+func SessionFeatures(traces []trace.Trace) map[string]float64 {
+	features := make(map[string]float64)
+	counts := make(map[string]int)
+
+	for _, t := range traces {
+		for name, value := range t {
+			if v, ok := numeric(value); ok {
+				features[name] += v
+				counts[name]++
+			}
+		}
+	}
+
+	for name, n := range counts {
+		features[name] /= float64(n)
+	}
+	features[TracesFeature] = float64(len(traces))
+
+	return features
+}
+
+// numeric converts a trace value to float64.
+// Returns false if the value is not a number or a boolean.
+func numeric(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
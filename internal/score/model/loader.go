@@ -0,0 +1,108 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// DefaultKey is the score key used when the model file does not specify one.
+const DefaultKey = "automation"
+
+// Definition is the model file format. It wraps a model exported from training
+// together with the parameters required to evaluate it.
+//
+// Example:
+//
+//	{
+//	  "format": "xgboost",
+//	  "key": "automation",
+//	  "features": ["mouseMoves", "clicks"],
+//	  "objective": "logistic",
+//	  "base_score": 0.5,
+//	  "model": [ ...output of Booster.dump_model(dump_format="json")... ]
+//	}
+type Definition struct {
+	// Format — model format: logistic, xgboost or lightgbm.
+	Format string `json:"format"`
+	// Key — score key the model output is written to.
+	Key string `json:"key"`
+	// Features — feature names by index. Resolves XGBoost default names (f0, f1, ...)
+	// and overrides LightGBM feature names.
+	Features []string `json:"features"`
+	// Objective — output transformation of tree ensembles: logistic or raw.
+	Objective string `json:"objective"`
+	// BaseScore — global bias of XGBoost models (0.5 if not specified).
+	BaseScore *float64 `json:"base_score"`
+	// Model — exported model in the native format.
+	Model json.RawMessage `json:"model"`
+}
+
+// Build creates the model described by the definition.
+func (d *Definition) Build() (Model, error) {
+	if len(d.Model) == 0 {
+		return nil, errors.New("model must be specified")
+	}
+
+	switch d.Objective {
+	case "", ObjectiveLogistic, ObjectiveRaw:
+	default:
+		return nil, fmt.Errorf("unsupported objective '%s'", d.Objective)
+	}
+
+	switch d.Format {
+	case FormatLogistic:
+		var lr LogisticRegression
+		if err := json.Unmarshal(d.Model, &lr); err != nil {
+			return nil, fmt.Errorf("logistic: %w", err)
+		}
+		return &lr, nil
+	case FormatXGBoost:
+		objective := d.Objective
+		if objective == "" {
+			objective = ObjectiveLogistic
+		}
+		baseScore := 0.5
+		if d.BaseScore != nil {
+			baseScore = *d.BaseScore
+		}
+		return newXGBoostEnsemble(d.Model, d.Features, objective, baseScore)
+	case FormatLightGBM:
+		return newLightGBMEnsemble(d.Model, d.Features, d.Objective)
+	default:
+		return nil, fmt.Errorf("unsupported model format '%s'", d.Format)
+	}
+}
+
+// LoadFromFile loads a model from the JSON file.
+// Parameters:
+//   - file: path to the model file
+//
+// Returns:
+//   - Initialized model
+//   - Score key the model output is written to
+//   - Error if the file was not read or the model is invalid
+func LoadFromFile(file string) (Model, string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var definition Definition
+	if err = json.Unmarshal(content, &definition); err != nil {
+		return nil, "", err
+	}
+
+	model, err := definition.Build()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := definition.Key
+	if len(key) == 0 {
+		key = DefaultKey
+	}
+
+	return model, key, nil
+}
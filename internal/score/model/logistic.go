@@ -0,0 +1,21 @@
+package model
+
+// LogisticRegression is a binary logistic regression model.
+// The prediction is sigmoid(Intercept + sum(Coefficients[f] * features[f])).
+// Features missing from the input contribute zero.
+type LogisticRegression struct {
+	// Intercept — bias term of the linear model.
+	Intercept float64 `json:"intercept"`
+	// Coefficients — weight of each feature by feature name.
+	Coefficients map[string]float64 `json:"coefficients"`
+}
+
+// Predict returns the probability of the positive class.
+func (lr *LogisticRegression) Predict(features map[string]float64) float64 {
+	z := lr.Intercept
+	for name, c := range lr.Coefficients {
+		z += c * features[name]
+	}
+
+	return sigmoid(z)
+}
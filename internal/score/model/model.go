@@ -0,0 +1,37 @@
+package model
+
+import "math"
+
+const (
+	FormatLogistic = "logistic"
+	FormatXGBoost  = "xgboost"
+	FormatLightGBM = "lightgbm"
+)
+
+const (
+	// ObjectiveLogistic applies the logistic function to the raw model output.
+	ObjectiveLogistic = "logistic"
+	// ObjectiveRaw returns the raw model output (margin) as is.
+	ObjectiveRaw = "raw"
+)
+
+// Model is a trained model evaluated in-process over session features.
+// Implementations must be safe for concurrent use.
+type Model interface {
+	// Predict returns the model output for the given features.
+	// Features missing from the map are treated as missing values.
+	Predict(features map[string]float64) float64
+}
+
+// sigmoid is the logistic function 1 / (1 + e^-z).
+func sigmoid(z float64) float64 {
+	return 1.0 / (1.0 + math.Exp(-z))
+}
+
+// logit is the inverse of sigmoid. The argument is clamped to (0, 1)
+// to keep the result finite.
+func logit(p float64) float64 {
+	const eps = 1e-15
+	p = math.Min(math.Max(p, eps), 1-eps)
+	return math.Log(p / (1 - p))
+}
@@ -0,0 +1,150 @@
+package model
+
+import (
+	"bean/internal/trace"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// xgbDump is a two-tree XGBoost dump with default feature names.
+const xgbDump = `[
+  {"nodeid": 0, "depth": 0, "split": "f0", "split_condition": 10, "yes": 1, "no": 2, "missing": 2, "children": [
+    {"nodeid": 1, "leaf": 0.8},
+    {"nodeid": 2, "leaf": -0.4}
+  ]},
+  {"nodeid": 0, "depth": 0, "split": "f1", "split_condition": 3, "yes": 1, "no": 2, "missing": 1, "children": [
+    {"nodeid": 1, "leaf": 0.2},
+    {"nodeid": 2, "leaf": -0.1}
+  ]}
+]`
+
+// lgbDump is a single-tree LightGBM dump.
+const lgbDump = `{
+  "objective": "binary sigmoid:1",
+  "feature_names": ["mouseMoves", "clicks"],
+  "tree_info": [{"tree_structure": {
+    "split_feature": 0, "threshold": 20, "decision_type": "<=", "default_left": true, "missing_type": "NaN",
+    "left_child": {"leaf_value": 1.5},
+    "right_child": {
+      "split_feature": 1, "threshold": 5, "decision_type": "<=", "default_left": false, "missing_type": "None",
+      "left_child": {"leaf_value": -0.5},
+      "right_child": {"leaf_value": -2}
+    }
+  }}]
+}`
+
+func TestLogisticRegression_Predict(t *testing.T) {
+	lr := LogisticRegression{
+		Intercept:    -1,
+		Coefficients: map[string]float64{"mouseMoves": -0.1, "clicks": 0.5},
+	}
+
+	p := lr.Predict(map[string]float64{"mouseMoves": 10, "clicks": 4})
+	assert.InDelta(t, sigmoid(-1-1+2), p, 1e-9)
+
+	// Missing features contribute zero
+	p = lr.Predict(map[string]float64{})
+	assert.InDelta(t, sigmoid(-1), p, 1e-9)
+}
+
+func TestXGBoostEnsemble_Predict(t *testing.T) {
+	m, err := newXGBoostEnsemble(json.RawMessage(xgbDump), []string{"mouseMoves", "clicks"}, ObjectiveLogistic, 0.5)
+	require.NoError(t, err)
+
+	// mouseMoves < 10 goes left, clicks >= 3 goes right
+	p := m.Predict(map[string]float64{"mouseMoves": 5, "clicks": 3})
+	assert.InDelta(t, sigmoid(0.8-0.1), p, 1e-9)
+
+	// Missing values follow the "missing" branch
+	p = m.Predict(map[string]float64{})
+	assert.InDelta(t, sigmoid(-0.4+0.2), p, 1e-9)
+}
+
+func TestXGBoostEnsemble_RawObjective(t *testing.T) {
+	m, err := newXGBoostEnsemble(json.RawMessage(xgbDump), nil, ObjectiveRaw, 0.1)
+	require.NoError(t, err)
+
+	// Without the features list the split names are used as is
+	p := m.Predict(map[string]float64{"f0": 20, "f1": 1})
+	assert.InDelta(t, 0.1-0.4+0.2, p, 1e-9)
+}
+
+func TestXGBoostEnsemble_FeatureOutOfRange(t *testing.T) {
+	_, err := newXGBoostEnsemble(json.RawMessage(xgbDump), []string{"mouseMoves"}, ObjectiveLogistic, 0.5)
+	assert.Error(t, err)
+}
+
+func TestLightGBMEnsemble_Predict(t *testing.T) {
+	m, err := newLightGBMEnsemble(json.RawMessage(lgbDump), nil, "")
+	require.NoError(t, err)
+
+	// mouseMoves <= 20 goes left (inclusive threshold)
+	assert.InDelta(t, sigmoid(1.5), m.Predict(map[string]float64{"mouseMoves": 20}), 1e-9)
+
+	// Missing clicks with missing_type None are compared as zero
+	assert.InDelta(t, sigmoid(-0.5), m.Predict(map[string]float64{"mouseMoves": 30}), 1e-9)
+	assert.InDelta(t, sigmoid(-2), m.Predict(map[string]float64{"mouseMoves": 30, "clicks": 6}), 1e-9)
+
+	// Missing mouseMoves with missing_type NaN follows default_left
+	assert.InDelta(t, sigmoid(1.5), m.Predict(map[string]float64{"mouseMoves": math.NaN()}), 1e-9)
+}
+
+func TestLightGBMEnsemble_Categorical(t *testing.T) {
+	dump := `{"tree_info": [{"tree_structure": {
+	  "split_feature": 0, "threshold": "1||2", "decision_type": "==",
+	  "left_child": {"leaf_value": 1}, "right_child": {"leaf_value": 0}
+	}}], "feature_names": ["platform"]}`
+
+	_, err := newLightGBMEnsemble(json.RawMessage(dump), nil, "")
+	assert.Error(t, err, "categorical splits are not supported")
+}
+
+func TestSessionFeatures(t *testing.T) {
+	traces := []trace.Trace{
+		{"mouseMoves": float64(10), "cookiesEnabled": true, "browserName": "Chrome"},
+		{"mouseMoves": int32(20), "cookiesEnabled": false, "clicks": 4},
+	}
+
+	features := SessionFeatures(traces)
+
+	assert.Equal(t, map[string]float64{
+		"mouseMoves":     15,
+		"cookiesEnabled": 0.5,
+		"clicks":         4,
+		TracesFeature:    2,
+	}, features)
+}
+
+func TestLoadFromFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "model.json")
+	content := `{"format": "logistic", "key": "bot", "model": {"intercept": 0, "coefficients": {"clicks": 1}}}`
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+
+	m, key, err := LoadFromFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "bot", key)
+	assert.InDelta(t, sigmoid(2), m.Predict(map[string]float64{"clicks": 2}), 1e-9)
+}
+
+func TestLoadFromFile_DefaultKey(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "model.json")
+	content := `{"format": "xgboost", "model": [{"nodeid": 0, "leaf": 0.3}]}`
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+
+	m, key, err := LoadFromFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultKey, key)
+	assert.InDelta(t, sigmoid(0.3), m.Predict(nil), 1e-9)
+}
+
+func TestDefinition_Build_UnknownFormat(t *testing.T) {
+	d := Definition{Format: "onnx", Model: json.RawMessage(`{}`)}
+	_, err := d.Build()
+	assert.Error(t, err)
+}
@@ -0,0 +1,255 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// treeNode is a node of a binary decision tree.
+// A node without children is a leaf and holds Value.
+type treeNode struct {
+	feature       string    // feature name used by the split
+	threshold     float64   // split threshold
+	inclusive     bool      // go left when value <= threshold (otherwise value < threshold)
+	defaultLeft   bool      // direction of missing values
+	missingAsZero bool      // missing values are compared as zero instead of following defaultLeft
+	zeroAsMissing bool      // zero values follow defaultLeft as if they were missing
+	left, right   *treeNode // children, nil for leaves
+	value         float64   // leaf value
+}
+
+// eval walks the tree from the node down to a leaf and returns the leaf value.
+func (n *treeNode) eval(features map[string]float64) float64 {
+	for n.left != nil {
+		x, found := features[n.feature]
+		missing := !found || math.IsNaN(x)
+		if missing && n.missingAsZero {
+			x, missing = 0, false
+		}
+		if !missing && n.zeroAsMissing && x == 0 {
+			missing = true
+		}
+
+		var goLeft bool
+		switch {
+		case missing:
+			goLeft = n.defaultLeft
+		case n.inclusive:
+			goLeft = x <= n.threshold
+		default:
+			goLeft = x < n.threshold
+		}
+
+		if goLeft {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+
+	return n.value
+}
+
+// TreeEnsemble is an additive ensemble of decision trees (gradient boosted trees).
+// The raw output is the sum of the leaf values of all trees plus the base margin.
+type TreeEnsemble struct {
+	trees     []*treeNode // ensemble trees
+	base      float64     // base margin added to the sum of leaves
+	objective string      // ObjectiveLogistic or ObjectiveRaw
+}
+
+// Predict returns the ensemble output. For the logistic objective the output
+// is a probability, otherwise it is the raw margin.
+func (te *TreeEnsemble) Predict(features map[string]float64) float64 {
+	sum := te.base
+	for _, t := range te.trees {
+		sum += t.eval(features)
+	}
+
+	if te.objective == ObjectiveLogistic {
+		return sigmoid(sum)
+	}
+
+	return sum
+}
+
+// xgbNode is a node of the XGBoost JSON dump (Booster.dump_model with dump_format="json").
+type xgbNode struct {
+	NodeId         int       `json:"nodeid"`
+	Split          string    `json:"split"`
+	SplitCondition float64   `json:"split_condition"`
+	Yes            int       `json:"yes"`
+	No             int       `json:"no"`
+	Missing        int       `json:"missing"`
+	Leaf           *float64  `json:"leaf"`
+	Children       []xgbNode `json:"children"`
+}
+
+// xgbFeatureIndex matches the default XGBoost feature names (f0, f1, ...).
+var xgbFeatureIndex = regexp.MustCompile(`^f(\d+)$`)
+
+// convert builds a treeNode from the XGBoost node.
+// Default feature names (fN) are resolved by index in the features list if it is provided.
+func (n *xgbNode) convert(features []string) (*treeNode, error) {
+	if n.Leaf != nil {
+		return &treeNode{value: *n.Leaf}, nil
+	}
+
+	node := &treeNode{
+		feature:     n.Split,
+		threshold:   n.SplitCondition,
+		defaultLeft: n.Missing == n.Yes,
+	}
+	if m := xgbFeatureIndex.FindStringSubmatch(n.Split); m != nil && len(features) > 0 {
+		i, _ := strconv.Atoi(m[1])
+		if i >= len(features) {
+			return nil, fmt.Errorf("xgboost: feature index %d is out of range", i)
+		}
+		node.feature = features[i]
+	}
+
+	for i := range n.Children {
+		child, err := n.Children[i].convert(features)
+		if err != nil {
+			return nil, err
+		}
+		switch n.Children[i].NodeId {
+		case n.Yes:
+			node.left = child
+		case n.No:
+			node.right = child
+		}
+	}
+
+	if node.left == nil || node.right == nil {
+		return nil, fmt.Errorf("xgboost: node %d has incomplete children", n.NodeId)
+	}
+
+	return node, nil
+}
+
+// newXGBoostEnsemble creates an ensemble from the XGBoost JSON dump (array of trees).
+// baseScore is the global bias of the booster in the objective scale.
+func newXGBoostEnsemble(raw json.RawMessage, features []string, objective string, baseScore float64) (*TreeEnsemble, error) {
+	var dump []xgbNode
+	if err := json.Unmarshal(raw, &dump); err != nil {
+		return nil, fmt.Errorf("xgboost: %w", err)
+	}
+
+	ensemble := &TreeEnsemble{objective: objective, base: baseScore}
+	if objective == ObjectiveLogistic {
+		ensemble.base = logit(baseScore)
+	}
+
+	for i := range dump {
+		tree, err := dump[i].convert(features)
+		if err != nil {
+			return nil, err
+		}
+		ensemble.trees = append(ensemble.trees, tree)
+	}
+
+	return ensemble, nil
+}
+
+// lgbNode is a node of the LightGBM JSON dump (Booster.dump_model).
+type lgbNode struct {
+	SplitFeature *int     `json:"split_feature"`
+	Threshold    any      `json:"threshold"`
+	DecisionType string   `json:"decision_type"`
+	DefaultLeft  bool     `json:"default_left"`
+	MissingType  string   `json:"missing_type"`
+	LeftChild    *lgbNode `json:"left_child"`
+	RightChild   *lgbNode `json:"right_child"`
+	LeafValue    float64  `json:"leaf_value"`
+}
+
+// lgbModel is the top level object of the LightGBM JSON dump.
+type lgbModel struct {
+	Objective    string   `json:"objective"`
+	FeatureNames []string `json:"feature_names"`
+	TreeInfo     []struct {
+		TreeStructure lgbNode `json:"tree_structure"`
+	} `json:"tree_info"`
+}
+
+// convert builds a treeNode from the LightGBM node.
+// Only numerical splits are supported.
+func (n *lgbNode) convert(features []string) (*treeNode, error) {
+	if n.SplitFeature == nil {
+		return &treeNode{value: n.LeafValue}, nil
+	}
+
+	if n.DecisionType != "<=" {
+		return nil, fmt.Errorf("lightgbm: unsupported decision type '%s'", n.DecisionType)
+	}
+
+	threshold, ok := n.Threshold.(float64)
+	if !ok {
+		return nil, errors.New("lightgbm: threshold must be numeric")
+	}
+
+	if *n.SplitFeature < 0 || *n.SplitFeature >= len(features) {
+		return nil, fmt.Errorf("lightgbm: feature index %d is out of range", *n.SplitFeature)
+	}
+
+	if n.LeftChild == nil || n.RightChild == nil {
+		return nil, errors.New("lightgbm: split node has incomplete children")
+	}
+
+	node := &treeNode{
+		feature:       features[*n.SplitFeature],
+		threshold:     threshold,
+		inclusive:     true,
+		defaultLeft:   n.DefaultLeft,
+		missingAsZero: n.MissingType == "None" || n.MissingType == "",
+		zeroAsMissing: n.MissingType == "Zero",
+	}
+
+	var err error
+	if node.left, err = n.LeftChild.convert(features); err != nil {
+		return nil, err
+	}
+	if node.right, err = n.RightChild.convert(features); err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
+
+// newLightGBMEnsemble creates an ensemble from the LightGBM JSON dump.
+// If objective is empty, it is derived from the dump: binary models use the logistic objective.
+// The features list overrides the feature names stored in the dump.
+func newLightGBMEnsemble(raw json.RawMessage, features []string, objective string) (*TreeEnsemble, error) {
+	var dump lgbModel
+	if err := json.Unmarshal(raw, &dump); err != nil {
+		return nil, fmt.Errorf("lightgbm: %w", err)
+	}
+
+	if len(features) == 0 {
+		features = dump.FeatureNames
+	}
+
+	if objective == "" {
+		objective = ObjectiveRaw
+		if strings.HasPrefix(dump.Objective, "binary") {
+			objective = ObjectiveLogistic
+		}
+	}
+
+	ensemble := &TreeEnsemble{objective: objective}
+	for i := range dump.TreeInfo {
+		tree, err := dump.TreeInfo[i].TreeStructure.convert(features)
+		if err != nil {
+			return nil, err
+		}
+		ensemble.trees = append(ensemble.trees, tree)
+	}
+
+	return ensemble, nil
+}
@@ -2,12 +2,14 @@ package rule
 
 import (
 	"os"
+	"strconv"
 
 	"github.com/google/cel-go/cel"
 	"github.com/stretchr/testify/assert/yaml"
 )
 
 // Loads rules from YAML-file and initializes them using CEL environment.
+// Rules without an id get the index of the rule in the file as the id.
 // Parameters:
 //   - file: path to the YAML-file
 //   - envProvider: function that provides the CEL environment
@@ -28,6 +30,10 @@ func LoadFromFile(file string, envProvider func() (*cel.Env, error)) ([]Rule, er
 	}
 
 	for i := range rules {
+		if rules[i].Id == "" {
+			rules[i].Id = strconv.Itoa(i)
+		}
+
 		env, err := envProvider()
 		if err != nil {
 			return nil, err
@@ -12,6 +12,8 @@ import (
 // The Then field contains a Score that will be applied if the condition is true.
 // The CEL program is compiled when Init is called and used during trace evaluation.
 type Rule struct {
+	// Id — rule identifier used in metrics; the index of the rule in the file by default.
+	Id string `yaml:"id"`
 	// When — CEL expression defining the rule trigger condition.
 	// Must return a boolean value.
 	When string `yaml:"when"`
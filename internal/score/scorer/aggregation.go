@@ -0,0 +1,104 @@
+package scorer
+
+import (
+	"math"
+)
+
+// Aggregation is a strategy of combining the values of a score key produced by several scorers.
+// Each value is accompanied by the weight of the scorer that produced it.
+type Aggregation string
+
+const (
+	// AggregationSum is the weighted sum of the values in the order of the scorers,
+	// clamped to [0.0, 1.0] after each value.
+	AggregationSum Aggregation = "sum"
+	// AggregationMean is the weighted mean of the values clamped to [0.0, 1.0].
+	AggregationMean Aggregation = "mean"
+	// AggregationMax is the maximum of the weighted values clamped to [0.0, 1.0].
+	// Values of scorers with zero weight are ignored.
+	AggregationMax Aggregation = "max"
+	// AggregationNoisyOr treats the weighted values as probabilities of independent
+	// evidence: 1 - (1 - p1) * (1 - p2) * ...
+	AggregationNoisyOr Aggregation = "noisy_or"
+	// AggregationLogOdds sums the weighted log-odds of the values and converts
+	// the sum back to a probability. A value of 0.5 is neutral.
+	AggregationLogOdds Aggregation = "log_odds"
+)
+
+// logOddsEpsilon keeps log-odds of the values 0.0 and 1.0 finite and bounded (about ±6.9),
+// so a single extreme value can't outweigh all other evidence.
+const logOddsEpsilon = 1e-3
+
+// weightedValue is a score value together with the weight of its scorer.
+type weightedValue struct {
+	value  float32
+	weight float32
+}
+
+// Aggregator selects the aggregation strategy for each score key.
+// The zero value sums all keys.
+type Aggregator struct {
+	// Default — strategy for keys without an explicit strategy. Empty value means AggregationSum.
+	Default Aggregation
+	// Keys — strategies by score key.
+	Keys map[string]Aggregation
+}
+
+// aggregate combines the values of the key using the strategy configured for the key.
+func (a *Aggregator) aggregate(key string, values []weightedValue) float32 {
+	strategy, found := a.Keys[key]
+	if !found {
+		strategy = a.Default
+	}
+
+	return strategy.aggregate(values)
+}
+
+// aggregate combines the weighted values in the order of the scorers.
+// The result is always within [0.0, 1.0].
+func (a Aggregation) aggregate(values []weightedValue) float32 {
+	var result float64
+	switch a {
+	case AggregationMean:
+		var sum, weights float64
+		for _, v := range values {
+			sum += float64(v.weight * v.value)
+			weights += float64(v.weight)
+		}
+		if weights > 0 {
+			result = sum / weights
+		}
+	case AggregationMax:
+		result = math.Inf(-1)
+		for _, v := range values {
+			if v.weight == 0 {
+				continue
+			}
+			result = math.Max(result, float64(v.weight*v.value))
+		}
+	case AggregationNoisyOr:
+		complement := 1.0
+		for _, v := range values {
+			complement *= 1 - clamp(float64(v.weight*v.value), 0, 1)
+		}
+		result = 1 - complement
+	case AggregationLogOdds:
+		var logOdds float64
+		for _, v := range values {
+			p := clamp(float64(v.value), logOddsEpsilon, 1-logOddsEpsilon)
+			logOdds += float64(v.weight) * math.Log(p/(1-p))
+		}
+		result = 1 / (1 + math.Exp(-logOdds))
+	default:
+		for _, v := range values {
+			result = clamp(result+float64(v.weight*v.value), 0, 1)
+		}
+	}
+
+	return float32(clamp(result, 0, 1))
+}
+
+// clamp limits the value to the range [min, max].
+func clamp(value, min, max float64) float64 {
+	return math.Min(math.Max(value, min), max)
+}
@@ -0,0 +1,95 @@
+package scorer
+
+import (
+	"bean/internal/score"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregation_Aggregate(t *testing.T) {
+	values := []weightedValue{{value: 0.6, weight: 1}, {value: 0.5, weight: 1}}
+
+	tests := []struct {
+		aggregation Aggregation
+		expected    float32
+	}{
+		{AggregationSum, 1.0},
+		{AggregationMean, 0.55},
+		{AggregationMax, 0.6},
+		{AggregationNoisyOr, 0.8},
+		{AggregationLogOdds, 0.6},
+		{"", 1.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.aggregation), func(t *testing.T) {
+			assert.InDelta(t, tt.expected, tt.aggregation.aggregate(values), 1e-6)
+		})
+	}
+}
+
+func TestAggregation_Weights(t *testing.T) {
+	values := []weightedValue{{value: 0.8, weight: 3}, {value: 0.2, weight: 1}}
+
+	assert.InDelta(t, 0.65, AggregationMean.aggregate(values), 1e-6)
+	assert.InDelta(t, 1.0, AggregationMax.aggregate(values), 1e-6, "weighted values should be clamped")
+
+	// Log-odds: 3 * logit(0.8) + logit(0.2) = 2 * logit(0.8) = logit(16/17)
+	assert.InDelta(t, 16.0/17.0, AggregationLogOdds.aggregate(values), 1e-5)
+}
+
+func TestAggregation_MaxZeroWeight(t *testing.T) {
+	values := []weightedValue{{value: 0.9, weight: 0}, {value: 0.3, weight: 1}}
+	assert.InDelta(t, 0.3, AggregationMax.aggregate(values), 1e-6)
+	assert.InDelta(t, 0.0, AggregationMax.aggregate([]weightedValue{{value: 0.8, weight: 0}}), 1e-6)
+}
+
+func TestAggregation_LogOddsClamp(t *testing.T) {
+	// 2 * logit(0.99) - logit(0.999) ≈ 2.28: a single certain value doesn't veto the others
+	values := []weightedValue{{value: 0, weight: 1}, {value: 0.99, weight: 1}, {value: 0.99, weight: 1}}
+	assert.InDelta(t, 0.907, AggregationLogOdds.aggregate(values), 1e-3)
+}
+
+func TestAggregation_NegativeValues(t *testing.T) {
+	values := []weightedValue{{value: -0.3, weight: 1}, {value: 0.5, weight: 1}}
+
+	// The sum is clamped after each value, as the scores were accumulated before the strategies
+	assert.InDelta(t, 0.5, AggregationSum.aggregate(values), 1e-6, "a negative value should not cancel a later one")
+	assert.InDelta(t, 0.2, AggregationSum.aggregate([]weightedValue{values[1], values[0]}), 1e-6)
+	assert.InDelta(t, 0.3, AggregationSum.aggregate([]weightedValue{{value: 0.9, weight: 1}, {value: 0.4, weight: 1}, {value: -0.7, weight: 1}}), 1e-6)
+	assert.InDelta(t, 0.5, AggregationNoisyOr.aggregate(values), 1e-6, "negative evidence should be ignored by noisy-OR")
+	assert.InDelta(t, 0.0, AggregationSum.aggregate([]weightedValue{{value: -0.3, weight: 1}}), 1e-6)
+}
+
+func TestCompositeScorer_Aggregation(t *testing.T) {
+	weight := float32(2)
+	cs := NewCompositeScorer([]Member{
+		{Name: "ml", Scorer: &staticScorer{score: score.Score{"automation": 0.6, "device": 0.4}}, Weight: &weight},
+		{Name: "rules", Scorer: &staticScorer{score: score.Score{"automation": 0.5, "device": 0.4}}},
+	}, newRepo(), 0, Aggregator{
+		Default: AggregationMax,
+		Keys:    map[string]Aggregation{"automation": AggregationNoisyOr},
+	}, score.Verdicts{})
+
+	result, err := cs.Score(context.Background(), "user1")
+	require.NoError(t, err)
+	// noisy-OR: 1 - (1 - min(2 * 0.6, 1)) * (1 - 0.5)
+	assert.InDelta(t, 1.0, result.Score["automation"], 1e-6)
+	// max: max(2 * 0.4, 0.4)
+	assert.InDelta(t, 0.8, result.Score["device"], 1e-6)
+}
+
+func TestCompositeScorer_ZeroWeight(t *testing.T) {
+	var disabled float32
+	cs := NewCompositeScorer([]Member{
+		{Name: "ml", Scorer: &staticScorer{score: score.Score{"automation": 0.9}}, Weight: &disabled},
+		{Name: "rules", Scorer: &staticScorer{score: score.Score{"automation": 0.2}}},
+	}, newRepo(), 0, Aggregator{Default: AggregationMean}, score.Verdicts{})
+
+	result, err := cs.Score(context.Background(), "user1")
+	require.NoError(t, err)
+	assert.InDelta(t, 0.2, result.Score["automation"], 1e-6, "zero weight should disable the scorer values")
+}
@@ -0,0 +1,86 @@
+package scorer
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when a call is rejected by an open circuit breaker.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitBreaker protects a remote service from calls while it is unhealthy.
+// After the specified number of consecutive failures the breaker opens and rejects
+// calls for the cool-down period. Then a single trial call is allowed (half-open state):
+// on success the breaker closes, on failure it opens for another cool-down period.
+//
+// CircuitBreaker is thread-safe.
+type CircuitBreaker struct {
+	failures    int           // number of consecutive failures that opens the breaker
+	cooldown    time.Duration // time the breaker stays open before allowing a trial call
+	consecutive int           // current number of consecutive failures
+	openedAt    time.Time     // time the breaker was opened, zero if closed
+	trial       bool          // a trial call is in progress
+	mu          sync.Mutex
+}
+
+// Allow reports whether a call may be performed.
+// Each allowed call must be followed by Success, Failure or Ignore.
+func (cb *CircuitBreaker) Allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.openedAt.IsZero() {
+		return true
+	}
+
+	if cb.trial || time.Since(cb.openedAt) < cb.cooldown {
+		return false
+	}
+
+	cb.trial = true
+	return true
+}
+
+// Success records a successful call and closes the breaker.
+func (cb *CircuitBreaker) Success() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.consecutive = 0
+	cb.openedAt = time.Time{}
+	cb.trial = false
+}
+
+// Failure records a failed call. Opens the breaker when the number of consecutive
+// failures reaches the threshold or when the trial call fails.
+func (cb *CircuitBreaker) Failure() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.consecutive++
+	if cb.trial || cb.consecutive >= cb.failures {
+		cb.openedAt = time.Now()
+	}
+	cb.trial = false
+}
+
+// Ignore records a call whose outcome says nothing about the health of the service,
+// e.g. a call canceled by the caller. The state of the breaker is not changed,
+// except that a trial call is released.
+func (cb *CircuitBreaker) Ignore() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.trial = false
+}
+
+// NewCircuitBreaker creates a new circuit breaker.
+// Parameters:
+//   - failures: number of consecutive failures that opens the breaker
+//   - cooldown: time the breaker stays open before a trial call is allowed
+//
+// Returns a pointer to the closed breaker.
+func NewCircuitBreaker(failures int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{failures: failures, cooldown: cooldown}
+}
@@ -1,24 +1,49 @@
 package scorer
 
 import (
+	"bean/internal/metrics"
 	"bean/internal/score"
 	"bean/internal/trace"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand/v2"
+	"net"
 	"net/http"
 	"time"
 )
 
+// responseError is returned when the ML service responds with a non-200 status.
+type responseError struct {
+	code   int    // HTTP status code
+	status string // HTTP status line
+}
+
+func (e *responseError) Error() string {
+	return fmt.Sprintf("ML response error code=%d status=%s", e.code, e.status)
+}
+
+// retryable reports whether the request may succeed if repeated.
+// Client errors (4xx) are not retried.
+func (e *responseError) retryable() bool {
+	return e.code < 400 || e.code >= 500
+}
+
 // ClientInputScorer is an implementation of a scorer that sends behavioral traces
 // to an external ML service for analysis and returns a score.
 // Uses HTTP requests with context and timeout.
+// Failed requests can be retried with exponential backoff and jitter,
+// and an optional circuit breaker skips the service while it is unhealthy.
 type ClientInputScorer struct {
-	url    string       // URL of the external service for sending traces
-	client *http.Client // HTTP client configured with timeout and context cancellation support
-	model  string       // model name for prediction
+	url     string          // URL of the external service for sending traces
+	client  *http.Client    // HTTP client configured with timeout and context cancellation support
+	model   string          // model name for prediction
+	retries int             // number of retries after a failed request
+	backoff time.Duration   // base delay between retries, doubled on each retry
+	breaker *CircuitBreaker // circuit breaker, nil if disabled
 }
 
 // Score sends the provided traces to an external ML service and returns the received score.
@@ -26,6 +51,10 @@ type ClientInputScorer struct {
 // Request format: JSON with a "batch" field containing an array of traces.
 // The server is expected to return a JSON object with numeric values interpreted as scores.
 //
+// Failed requests are retried up to the configured number of times. If the circuit breaker
+// is open, the request is not sent and ErrCircuitOpen is returned. Only the failures
+// indicating an unhealthy service are counted by the breaker; requests canceled by the caller
+// and 4xx responses other than 429 are not.
 // In case of network error, invalid status (not 200), or incorrect JSON - returns an error.
 func (cis *ClientInputScorer) Score(ctx context.Context, traces []trace.Trace) (score.Score, error) {
 	requestData := map[string]any{
@@ -37,6 +66,101 @@ func (cis *ClientInputScorer) Score(ctx context.Context, traces []trace.Trace) (
 		return nil, err
 	}
 
+	if cis.breaker != nil && !cis.breaker.Allow() {
+		metrics.MLRequests.Inc(cis.model, "circuit_open")
+		return nil, ErrCircuitOpen
+	}
+
+	result, err := cis.scoreWithRetries(ctx, requestBody)
+	if cis.breaker != nil {
+		switch {
+		case err == nil:
+			cis.breaker.Success()
+		case ctx.Err() == nil && unhealthy(err):
+			cis.breaker.Failure()
+		default:
+			cis.breaker.Ignore()
+		}
+	}
+
+	return result, err
+}
+
+// unhealthy reports whether the error of a request not canceled by the caller indicates
+// that the ML service is unhealthy: a network error, a timeout of the request, or
+// a 5xx or 429 response. Other client errors (4xx) are caused by the request itself.
+func unhealthy(err error) bool {
+	var respErr *responseError
+	if errors.As(err, &respErr) {
+		return respErr.code >= 500 || respErr.code == http.StatusTooManyRequests
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// scoreWithRetries sends the request and repeats it on retryable errors.
+// The delay before the n-th retry is a random value in [0, backoff * 2^n) (full jitter).
+func (cis *ClientInputScorer) scoreWithRetries(ctx context.Context, requestBody []byte) (score.Score, error) {
+	for attempt := 0; ; attempt++ {
+		result, err := cis.score(ctx, requestBody)
+		if err == nil {
+			return result, nil
+		}
+
+		if attempt >= cis.retries || ctx.Err() != nil {
+			return nil, err
+		}
+		var respErr *responseError
+		if errors.As(err, &respErr) && !respErr.retryable() {
+			return nil, err
+		}
+
+		var delay time.Duration
+		if limit := cis.backoff << attempt; limit > 0 {
+			delay = rand.N(limit)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// score performs a single request to the ML service and counts its outcome
+// in the ML requests metric.
+func (cis *ClientInputScorer) score(ctx context.Context, requestBody []byte) (score.Score, error) {
+	result, err := cis.request(ctx, requestBody)
+	metrics.MLRequests.Inc(cis.model, outcomeOf(err))
+	return result, err
+}
+
+// outcomeOf classifies the result of a request to the ML service for metrics.
+func outcomeOf(err error) string {
+	var respErr *responseError
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.As(err, &respErr):
+		return "http_error"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &netErr):
+		return "network_error"
+	default:
+		return "invalid_response"
+	}
+}
+
+// request performs a single request to the ML service.
+func (cis *ClientInputScorer) request(ctx context.Context, requestBody []byte) (score.Score, error) {
 	req, _ := http.NewRequestWithContext(ctx, "POST", cis.url+"/batch", bytes.NewReader(requestBody))
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := cis.client.Do(req)
@@ -46,7 +170,7 @@ func (cis *ClientInputScorer) Score(ctx context.Context, traces []trace.Trace) (
 
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("ML response error code=%d status=%s", resp.StatusCode, resp.Status)
+		return nil, &responseError{code: resp.StatusCode, status: resp.Status}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -63,12 +187,49 @@ func (cis *ClientInputScorer) Score(ctx context.Context, traces []trace.Trace) (
 	return result, nil
 }
 
+// Ping checks that the ML service is available by requesting its health endpoint
+// (GET <url>/health). Any 2xx status is treated as healthy.
+// The circuit breaker and retries are not applied.
+func (cis *ClientInputScorer) Ping(ctx context.Context) error {
+	req, _ := http.NewRequestWithContext(ctx, "GET", cis.url+"/health", nil)
+	resp, err := cis.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &responseError{code: resp.StatusCode, status: resp.Status}
+	}
+
+	return nil
+}
+
+// WithRetries enables retries of failed requests.
+// Parameters:
+//   - retries: number of retries after the first failed request
+//   - backoff: base delay between retries, doubled on each retry and randomized (jitter)
+//
+// Returns the scorer itself for chaining.
+func (cis *ClientInputScorer) WithRetries(retries int, backoff time.Duration) *ClientInputScorer {
+	cis.retries = retries
+	cis.backoff = backoff
+	return cis
+}
+
+// WithCircuitBreaker enables the circuit breaker for requests to the ML service.
+// Returns the scorer itself for chaining.
+func (cis *ClientInputScorer) WithCircuitBreaker(breaker *CircuitBreaker) *ClientInputScorer {
+	cis.breaker = breaker
+	return cis
+}
+
 // NewClientInputScorer creates a new instance of ClientInputScorer.
 // Parameters:
 // - url: address of the external ML service (e.g., "http://ml-service:8080/score")
-// - timeout: timeout for the HTTP request
+// - timeout: timeout for a single HTTP request
 //
-// Returns a pointer to the initialized scorer.
+// Returns a pointer to the initialized scorer without retries and circuit breaker.
 // Internally uses *http.Client with the specified timeout to manage request duration.
 func NewClientInputScorer(url string, timeout time.Duration, model string) *ClientInputScorer {
 	client := http.Client{
@@ -0,0 +1,170 @@
+package scorer
+
+import (
+	"bean/internal/score"
+	"bean/internal/trace"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMLServer starts a test ML service that fails the first `failures` requests with the given status.
+func newMLServer(t *testing.T, failures int32, status int) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(`{"automation": 0.7}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestClientInputScorer_Score(t *testing.T) {
+	srv, calls := newMLServer(t, 0, 0)
+	s := NewClientInputScorer(srv.URL, time.Second, "default")
+
+	result, err := s.Score(context.Background(), []trace.Trace{{"mouseMoves": 1}})
+	require.NoError(t, err)
+	assert.Equal(t, score.Score{"automation": 0.7}, result)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestClientInputScorer_Retries(t *testing.T) {
+	srv, calls := newMLServer(t, 2, http.StatusServiceUnavailable)
+	s := NewClientInputScorer(srv.URL, time.Second, "default").WithRetries(2, time.Millisecond)
+
+	result, err := s.Score(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, score.Score{"automation": 0.7}, result)
+	assert.Equal(t, int32(3), calls.Load(), "two failed requests should be retried")
+}
+
+func TestClientInputScorer_NoRetryOnClientError(t *testing.T) {
+	srv, calls := newMLServer(t, 1, http.StatusBadRequest)
+	s := NewClientInputScorer(srv.URL, time.Second, "default").WithRetries(3, time.Millisecond)
+
+	_, err := s.Score(context.Background(), nil)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), calls.Load(), "client errors should not be retried")
+}
+
+func TestClientInputScorer_CircuitBreaker(t *testing.T) {
+	srv, calls := newMLServer(t, 2, http.StatusInternalServerError)
+	s := NewClientInputScorer(srv.URL, time.Second, "default").
+		WithCircuitBreaker(NewCircuitBreaker(2, 50*time.Millisecond))
+
+	_, err := s.Score(context.Background(), nil)
+	assert.Error(t, err)
+	_, err = s.Score(context.Background(), nil)
+	assert.Error(t, err)
+
+	// Breaker is open — the service is not called
+	_, err = s.Score(context.Background(), nil)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(2), calls.Load())
+
+	// After the cool-down the trial request succeeds and closes the breaker
+	time.Sleep(60 * time.Millisecond)
+	_, err = s.Score(context.Background(), nil)
+	assert.NoError(t, err)
+	_, err = s.Score(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(4), calls.Load())
+}
+
+func TestClientInputScorer_CircuitBreakerIgnoresCallerErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			io.Copy(io.Discard, r.Body)
+			<-r.Context().Done()
+		case 2:
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.Write([]byte(`{"automation": 0.7}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	s := NewClientInputScorer(srv.URL, time.Second, "default").
+		WithCircuitBreaker(NewCircuitBreaker(1, time.Minute))
+
+	// The caller gives up, e.g. a stream client leaves or the score budget is exhausted
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := s.Score(ctx, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = s.Score(ctx, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// A client error is caused by the request, not by the service
+	_, err = s.Score(context.Background(), nil)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrCircuitOpen)
+
+	_, err = s.Score(context.Background(), nil)
+	assert.NoError(t, err, "breaker should stay closed")
+}
+
+func TestClientInputScorer_CircuitBreakerRequestTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	t.Cleanup(srv.Close)
+	s := NewClientInputScorer(srv.URL, 20*time.Millisecond, "default").
+		WithCircuitBreaker(NewCircuitBreaker(1, time.Minute))
+
+	_, err := s.Score(context.Background(), nil)
+	assert.Error(t, err)
+	_, err = s.Score(context.Background(), nil)
+	assert.ErrorIs(t, err, ErrCircuitOpen, "timeout of the request itself should open the breaker")
+}
+
+func TestClientInputScorer_Ping(t *testing.T) {
+	healthy := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" || !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	s := NewClientInputScorer(srv.URL, time.Second, "default")
+
+	assert.NoError(t, s.Ping(context.Background()))
+	healthy = false
+	assert.Error(t, s.Ping(context.Background()))
+	srv.Close()
+	assert.Error(t, s.Ping(context.Background()))
+}
+
+func TestCircuitBreaker_TrialFailure(t *testing.T) {
+	cb := NewCircuitBreaker(1, 20*time.Millisecond)
+
+	assert.True(t, cb.Allow())
+	cb.Failure()
+	assert.False(t, cb.Allow(), "breaker should open after the failure")
+
+	time.Sleep(30 * time.Millisecond)
+	assert.True(t, cb.Allow(), "trial call should be allowed after the cool-down")
+	assert.False(t, cb.Allow(), "only one trial call should be allowed")
+
+	cb.Failure()
+	assert.False(t, cb.Allow(), "failed trial should open the breaker again")
+}
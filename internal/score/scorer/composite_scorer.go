@@ -1,73 +1,459 @@
 package scorer
 
 import (
+	"bean/internal/metrics"
 	"bean/internal/score"
 	"bean/internal/trace"
 	"context"
 	"errors"
+	"fmt"
+	"sync"
+	"time"
 )
 
+// ErrSessionNotFound is returned when there are no traces for the requested session.
+var ErrSessionNotFound = errors.New("trace id not found")
+
+// ErrUnknownVerdict is returned when an override verdict is neither allow, deny nor a configured band.
+var ErrUnknownVerdict = errors.New("unknown verdict")
+
+// ErrorPolicy defines how CompositeScorer handles a failure of a nested scorer.
+type ErrorPolicy string
+
+const (
+	// ErrorPolicyFail aborts the scoring and returns the error.
+	ErrorPolicyFail ErrorPolicy = "fail"
+	// ErrorPolicySkip ignores the failed scorer.
+	ErrorPolicySkip ErrorPolicy = "skip"
+	// ErrorPolicyFallback uses the static fallback score instead of the failed scorer result.
+	ErrorPolicyFallback ErrorPolicy = "fallback"
+)
+
+// Member is a nested scorer of CompositeScorer together with its error handling settings.
+type Member struct {
+	// Name — scorer name reported in the score result.
+	Name string
+	// Scorer — nested scorer.
+	Scorer score.TracesScorer
+	// OnError — failure handling policy. Empty value is treated as ErrorPolicyFail.
+	OnError ErrorPolicy
+	// Fallback — score used instead of the scorer result with ErrorPolicyFallback.
+	Fallback score.Score
+	// Deadline — maximum time the scorer may run. Zero means no per-scorer deadline.
+	Deadline time.Duration
+	// Weight — weight of the scorer values in the aggregation. Nil is treated as 1,
+	// zero disables the scorer values.
+	Weight *float32
+	// Incremental — evaluate each trace once at ingest and fold the stored results.
+	// Has effect only if Scorer implements score.IncrementalScorer.
+	Incremental bool
+}
+
+// outcome is the result of a single nested scorer run.
+type outcome struct {
+	score    score.Score // scorer result
+	err      error       // scorer error
+	timedOut bool        // the scorer did not finish before its deadline
+}
+
 // CompositeScorer is a composite scorer implementation that aggregates scores
 // from multiple nested scorers. To compute the final score, it retrieves
 // behavioral traces by session ID from the repository and passes them
-// to each scorer. The resulting scores are summed by key and normalized
-// to the range [0.0, 1.0].
+// to each scorer. Nested scorers run concurrently, each one limited by its own
+// deadline and all of them by the overall budget. The values of each score key are
+// combined by the aggregation strategy configured for the key, taking the scorer
+// weights into account. Only the default sum strategy depends on the order of the scorers,
+// as it clamps the running sum after each scorer. The result is normalized to the range [0.0, 1.0].
+//
+// Complete results (without scorer errors and timeouts) are cached per session
+// and reused until a new trace is appended to the session.
+//
+// Incremental members are registered as annotators of the trace repository:
+// each trace is evaluated once when it is appended, and the session score
+// is folded from the stored per-trace results. Other members implementing
+// score.ObservingScorer observe each trace once when it is appended.
+//
+// An operator may override the verdict of a session: the score is still calculated,
+// but the verdict of the result is replaced by the override until it is cleared
+// or the session is removed from the repository.
+//
+// A session may have a score adjustment (e.g., for a solved challenge) added to the
+// aggregated score before the verdict is assigned, until the session is removed.
 //
 // CompositeScorer is thread-safe, provided that all nested scorers and
 // the trace repository (tracesRepo) are also thread-safe.
 type CompositeScorer struct {
-	scorers    []score.TracesScorer    // list of scorers whose scores will be combined
-	tracesRepo *trace.TracesRepository // repository for retrieving trace data by ID
-	ctx        context.Context         // context passed to scorers during evaluation
+	members     []Member                // list of scorers whose scores will be combined
+	tracesRepo  *trace.TracesRepository // repository for retrieving trace data by ID
+	budget      time.Duration           // overall time limit of the scoring, zero means no limit
+	aggregator  Aggregator              // aggregation strategies by score key
+	cache       *scoreCache             // last complete result of each session by session version
+	verdicts    score.Verdicts          // verdict bands assigned to the aggregated score
+	overrides   map[string]string       // verdicts set by an operator by session ID
+	adjustments map[string]score.Score  // score adjustments by session ID
+	overrideMu  sync.RWMutex            // mutex to protect access to overrides and adjustments
 }
 
 // Score calculates the final score for the given session ID.
 // Algorithm:
-//  1. Retrieves the list of traces from the repository by the given ID.
-//  2. If no traces are found, returns an error.
-//  3. Invokes the Score method on each scorer with the context and traces.
-//  4. Aggregates all resulting scores by key (summation).
-//  5. Clamps each score component to the range [0.0, 1.0].
+//  1. Returns the cached result if the session has not changed since it was computed.
+//  2. Retrieves the list of traces from the repository by the given ID.
+//     If no traces are found, returns ErrSessionNotFound.
+//  3. Invokes the Score method on all scorers concurrently with the context and traces.
+//  4. Waits until all scorers finish, their deadlines pass or the budget is exhausted.
+//  5. Aggregates the finished scores by key using the configured strategies and scorer weights.
+//  6. Each score component is within the range [0.0, 1.0].
+//  7. Assigns the verdict of the aggregated score, or the override verdict of the session.
+//     The adjustment of the session, if any, is added to the score before the verdict is assigned.
 //
-// If any scorer returns an error, execution stops immediately and the error is returned.
+// If a scorer returns an error, its error policy is applied: fail stops execution
+// and returns the error, skip ignores the scorer, fallback uses the static
+// fallback score. Skipped and replaced scorers are listed in the result errors.
+// Scorers that did not finish in time are listed in the result timeouts; their fallback
+// score is used with the fallback policy, otherwise they are ignored.
 //
 // Parameters:
+//   - ctx: request context; if it is canceled, the scoring stops and the context error is returned.
 //   - id: the session ID used to retrieve traces.
 //
 // Returns:
-//   - score.Score: the final aggregated score.
-//   - error: an error if the session is not found or any scorer fails.
-func (cs *CompositeScorer) Score(id string) (score.Score, error) {
-	result := make(score.Score)
-	traces, exists := cs.tracesRepo.Get(id)
+//   - score.Result: the final aggregated score, the handled scorer errors and timeouts.
+//   - error: an error if the session is not found, the context is canceled
+//     or a scorer with fail policy fails.
+func (cs *CompositeScorer) Score(ctx context.Context, id string) (score.Result, error) {
+	if version, exists := cs.tracesRepo.Version(id); exists {
+		if cached, found := cs.cache.get(id, version); found {
+			cs.applyAdjustment(id, &cached)
+			cs.applyOverride(id, &cached)
+			return cached, nil
+		}
+	}
+
+	result := score.Result{Score: make(score.Score)}
+	// Read before the traces, so a result of a session evicted meanwhile is not cached
+	generation := cs.cache.currentGeneration()
+	entries, version, exists := cs.tracesRepo.GetEntries(id)
 	if !exists {
-		return result, errors.New("trace id not found: " + id)
+		return result, fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	}
+
+	traces := make([]trace.Trace, len(entries))
+	for i := range entries {
+		traces[i] = entries[i].Trace
+	}
+
+	if cs.budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cs.budget)
+		defer cancel()
+	}
+
+	type indexedOutcome struct {
+		index int
+		outcome
 	}
-	for _, s := range cs.scorers {
-		score, err := s.Score(cs.ctx, traces)
-		if err != nil {
-			return result, err
+
+	// Buffered channel allows late scorers to finish without blocking
+	finished := make(chan indexedOutcome, len(cs.members))
+	for i := range cs.members {
+		go func(i int) {
+			finished <- indexedOutcome{i, cs.members[i].run(ctx, traces, entries)}
+		}(i)
+	}
+
+	outcomes := make([]*outcome, len(cs.members))
+collect:
+	for range cs.members {
+		select {
+		case o := <-finished:
+			outcomes[o.index] = &o.outcome
+		case <-ctx.Done():
+			break collect
 		}
-		for k, v := range score {
-			result[k] += v
-			if result[k] > 1.0 {
-				result[k] = 1.0
-			} else if result[k] < 0.0 {
-				result[k] = 0.0
+	}
+
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return result, ctx.Err()
+	}
+
+	values := make(map[string][]weightedValue)
+	for i := range cs.members {
+		m := &cs.members[i]
+		o := outcomes[i]
+
+		var score score.Score
+		switch {
+		case o == nil || o.timedOut:
+			metrics.ScorerErrors.Inc(m.Name, "timeout")
+			result.Timeouts = append(result.Timeouts, m.Name)
+			if m.OnError == ErrorPolicyFallback {
+				score = m.Fallback
 			}
+		case o.err != nil:
+			metrics.ScorerErrors.Inc(m.Name, "error")
+			var err error
+			score, err = m.handleError(o.err, &result)
+			if err != nil {
+				return result, err
+			}
+		default:
+			score = o.score
+		}
+
+		for k, v := range score {
+			values[k] = append(values[k], weightedValue{value: v, weight: m.weight()})
 		}
 	}
+
+	for k, v := range values {
+		result.Score[k] = cs.aggregator.aggregate(k, v)
+		metrics.ScoreValues.Observe(float64(result.Score[k]), k)
+	}
+	result.Verdict = cs.verdicts.Classify(result.Score)
+
+	if len(result.Errors) == 0 && len(result.Timeouts) == 0 {
+		cs.cache.put(id, generation, version, result)
+	}
+	cs.applyAdjustment(id, &result)
+	cs.applyOverride(id, &result)
 	return result, nil
 }
 
+// Last returns the last complete result computed for the session, even if traces were appended
+// since, with the adjustment and the override of the session applied. Unlike Score, it never runs
+// the scorers, so it is cheap enough for public and bulk requests.
+// Returns false if no result of the session is cached.
+func (cs *CompositeScorer) Last(id string) (score.Result, bool) {
+	result, found := cs.cache.last(id)
+	if !found {
+		return result, false
+	}
+	cs.applyAdjustment(id, &result)
+	cs.applyOverride(id, &result)
+	return result, true
+}
+
+// SetOverride pins the verdict of the session. The verdict must be allow, deny
+// or the name of a configured band. Returns ErrSessionNotFound if the session
+// has no traces and ErrUnknownVerdict if the verdict is not valid.
+func (cs *CompositeScorer) SetOverride(id string, verdict string) error {
+	if verdict != score.VerdictAllow && verdict != score.VerdictDeny && !cs.verdicts.Has(verdict) {
+		return fmt.Errorf("%w: %s", ErrUnknownVerdict, verdict)
+	}
+	if _, exists := cs.tracesRepo.Version(id); !exists {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	}
+
+	cs.overrideMu.Lock()
+	defer cs.overrideMu.Unlock()
+
+	cs.overrides[id] = verdict
+	return nil
+}
+
+// Override returns the verdict pinned for the session, if any.
+func (cs *CompositeScorer) Override(id string) (string, bool) {
+	cs.overrideMu.RLock()
+	defer cs.overrideMu.RUnlock()
+
+	verdict, found := cs.overrides[id]
+	return verdict, found
+}
+
+// ClearOverride removes the verdict pinned for the session.
+// Returns false if the session has no override.
+func (cs *CompositeScorer) ClearOverride(id string) bool {
+	cs.overrideMu.Lock()
+	defer cs.overrideMu.Unlock()
+
+	_, found := cs.overrides[id]
+	delete(cs.overrides, id)
+	return found
+}
+
+// SetAdjustment sets the adjustment added to the aggregated score of the session;
+// the adjusted values are clamped to [0.0, 1.0]. Replaces the previous adjustment.
+// Returns ErrSessionNotFound if the session has no traces.
+func (cs *CompositeScorer) SetAdjustment(id string, adjustment score.Score) error {
+	if _, exists := cs.tracesRepo.Version(id); !exists {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	}
+
+	cs.overrideMu.Lock()
+	defer cs.overrideMu.Unlock()
+
+	cs.adjustments[id] = adjustment
+	return nil
+}
+
+// applyAdjustment adds the adjustment of the session, if any, to the score of the result
+// and classifies the adjusted score. The score is copied, so cached results are not changed.
+func (cs *CompositeScorer) applyAdjustment(id string, result *score.Result) {
+	cs.overrideMu.RLock()
+	adjustment, found := cs.adjustments[id]
+	cs.overrideMu.RUnlock()
+	if !found {
+		return
+	}
+
+	adjusted := make(score.Score, len(result.Score))
+	for k, v := range result.Score {
+		adjusted[k] = v
+	}
+	for k, v := range adjustment {
+		adjusted[k] = float32(clamp(float64(adjusted[k]+v), 0, 1))
+	}
+	result.Score = adjusted
+	result.Verdict = cs.verdicts.Classify(adjusted)
+	result.Adjusted = true
+}
+
+// ResetCache removes all cached results, e.g. after the rules of a scorer are replaced.
+func (cs *CompositeScorer) ResetCache() {
+	cs.cache.reset()
+}
+
+// applyOverride replaces the verdict of the result with the override of the session, if any.
+func (cs *CompositeScorer) applyOverride(id string, result *score.Result) {
+	if verdict, found := cs.Override(id); found {
+		result.Verdict = verdict
+		result.Override = true
+	}
+}
+
+// forget removes the cached result, the override and the adjustment of a session removed from the repository.
+func (cs *CompositeScorer) forget(id string) {
+	cs.cache.delete(id)
+	cs.ClearOverride(id)
+
+	cs.overrideMu.Lock()
+	delete(cs.adjustments, id)
+	cs.overrideMu.Unlock()
+}
+
+// run invokes the scorer limited by the member deadline.
+// Incremental scorers fold the annotations stored in the entries instead of scoring the traces.
+// Returns as soon as the deadline passes even if the scorer ignores the context;
+// in that case the scorer keeps running in the background and its result is discarded.
+// The run time is observed in the scorer duration metric.
+func (m *Member) run(ctx context.Context, traces []trace.Trace, entries []trace.Entry) outcome {
+	start := time.Now()
+	defer func() {
+		metrics.ScorerDuration.Observe(time.Since(start).Seconds(), m.Name)
+	}()
+
+	if m.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.Deadline)
+		defer cancel()
+	}
+
+	done := make(chan outcome, 1)
+	go func() {
+		var s score.Score
+		var err error
+		if incremental, ok := m.incremental(); ok {
+			annotations := make([]any, len(entries))
+			for i := range entries {
+				annotations[i] = entries[i].Annotations[m.Name]
+			}
+			s, err = incremental.Fold(ctx, traces, annotations)
+		} else {
+			s, err = m.Scorer.Score(ctx, traces)
+		}
+		// Only the deadline or budget of the member is a timeout: a scorer may return
+		// context.DeadlineExceeded of its own, e.g. from an HTTP client timeout
+		done <- outcome{score: s, err: err, timedOut: err != nil && ctx.Err() != nil}
+	}()
+
+	select {
+	case o := <-done:
+		return o
+	case <-ctx.Done():
+		return outcome{err: ctx.Err(), timedOut: true}
+	}
+}
+
+// incremental returns the scorer as score.IncrementalScorer if the member is incremental.
+func (m *Member) incremental() (score.IncrementalScorer, bool) {
+	if !m.Incremental {
+		return nil, false
+	}
+	incremental, ok := m.Scorer.(score.IncrementalScorer)
+	return incremental, ok
+}
+
+// weight returns the member weight, treating nil as 1.
+func (m *Member) weight() float32 {
+	if m.Weight == nil {
+		return 1
+	}
+	return *m.Weight
+}
+
+// handleError applies the member error policy to the scorer error.
+// Returns the score to use instead of the scorer result, or the error if the scoring must fail.
+// Handled errors are appended to the result.
+func (m *Member) handleError(err error, result *score.Result) (score.Score, error) {
+	switch m.OnError {
+	case ErrorPolicySkip:
+		result.Errors = append(result.Errors, score.ScorerError{Scorer: m.Name, Policy: string(m.OnError), Error: err.Error()})
+		return nil, nil
+	case ErrorPolicyFallback:
+		result.Errors = append(result.Errors, score.ScorerError{Scorer: m.Name, Policy: string(m.OnError), Error: err.Error()})
+		return m.Fallback, nil
+	default:
+		return nil, fmt.Errorf("scorer %s: %w", m.Name, err)
+	}
+}
+
 // NewCompositeScorer creates a new instance of CompositeScorer.
 //
 // Parameters:
-//   - scorers: a list of scorers to be used for score calculation.
+//   - members: a list of scorers with their error policies and deadlines to be used for score calculation.
 //   - tracesRepo: the trace repository from which trace data will be loaded by session ID.
+//   - budget: overall time limit of a single scoring, zero means no limit.
+//   - aggregator: aggregation strategies by score key.
+//   - verdicts: verdict bands assigned to the aggregated score.
 //
 // Returns a pointer to the newly created CompositeScorer instance.
-// The context is initialized to context.Background() by default.
-func NewCompositeScorer(scorers []score.TracesScorer, tracesRepo *trace.TracesRepository) *CompositeScorer {
-	return &CompositeScorer{ctx: context.Background(), scorers: scorers, tracesRepo: tracesRepo}
+// Cached results, overrides and adjustments of sessions removed from the repository are removed.
+// Incremental members are registered as repository annotators under their names.
+func NewCompositeScorer(
+	members []Member,
+	tracesRepo *trace.TracesRepository,
+	budget time.Duration,
+	aggregator Aggregator,
+	verdicts score.Verdicts,
+) *CompositeScorer {
+	cs := &CompositeScorer{
+		members:     members,
+		tracesRepo:  tracesRepo,
+		budget:      budget,
+		aggregator:  aggregator,
+		cache:       newScoreCache(),
+		verdicts:    verdicts,
+		overrides:   make(map[string]string),
+		adjustments: make(map[string]score.Score),
+	}
+	tracesRepo.OnEvict(cs.forget)
+	for i := range members {
+		if incremental, ok := members[i].incremental(); ok {
+			tracesRepo.AddAnnotator(members[i].Name, incremental)
+		} else if observing, ok := members[i].Scorer.(score.ObservingScorer); ok {
+			tracesRepo.AddAnnotator(members[i].Name, observer{observing})
+		}
+	}
+	return cs
+}
+
+// observer registers an observing scorer as an annotator without annotations.
+type observer struct {
+	score.ObservingScorer
+}
+
+// Annotate passes the ingested trace to the scorer.
+func (o observer) Annotate(t trace.Trace) any {
+	o.Observe(t)
+	return nil
 }
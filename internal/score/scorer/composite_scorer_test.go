@@ -0,0 +1,358 @@
+package scorer
+
+import (
+	"bean/internal/score"
+	"bean/internal/trace"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticScorer returns the predefined score or error after the optional delay.
+// The delay ignores the context, like CPU-bound scorers do.
+type staticScorer struct {
+	score score.Score
+	err   error
+	delay time.Duration
+}
+
+func (s *staticScorer) Score(ctx context.Context, traces []trace.Trace) (score.Score, error) {
+	time.Sleep(s.delay)
+	return s.score, s.err
+}
+
+// blockingScorer waits for the context to be done.
+type blockingScorer struct{}
+
+func (s *blockingScorer) Score(ctx context.Context, traces []trace.Trace) (score.Score, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// newRepo creates a repository with a single trace for the "user1" session.
+func newRepo() *trace.TracesRepository {
+	repo := trace.NewTracesRepository(10, 0)
+	repo.Append("user1", trace.Trace{"mouseMoves": 1})
+	return repo
+}
+
+func TestCompositeScorer_Score(t *testing.T) {
+	cs := NewCompositeScorer([]Member{
+		{Name: "a", Scorer: &staticScorer{score: score.Score{"automation": 0.7}}},
+		{Name: "b", Scorer: &staticScorer{score: score.Score{"automation": 0.5, "device": -0.2}}},
+	}, newRepo(), 0, Aggregator{}, score.Verdicts{})
+
+	result, err := cs.Score(context.Background(), "user1")
+	require.NoError(t, err)
+	assert.Equal(t, score.Score{"automation": 1.0, "device": 0.0}, result.Score)
+	assert.Empty(t, result.Errors)
+}
+
+func TestCompositeScorer_SessionNotFound(t *testing.T) {
+	cs := NewCompositeScorer(nil, newRepo(), 0, Aggregator{}, score.Verdicts{})
+
+	_, err := cs.Score(context.Background(), "user2")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+}
+
+func TestCompositeScorer_ErrorPolicies(t *testing.T) {
+	failure := errors.New("unavailable")
+	cs := NewCompositeScorer([]Member{
+		{Name: "rules", Scorer: &staticScorer{score: score.Score{"automation": 0.2}}},
+		{Name: "skipped", Scorer: &staticScorer{err: failure}, OnError: ErrorPolicySkip},
+		{Name: "fallback", Scorer: &staticScorer{err: failure}, OnError: ErrorPolicyFallback, Fallback: score.Score{"automation": 0.3}},
+	}, newRepo(), 0, Aggregator{}, score.Verdicts{})
+
+	result, err := cs.Score(context.Background(), "user1")
+	require.NoError(t, err)
+	assert.InDelta(t, 0.5, result.Score["automation"], 1e-6)
+	assert.Equal(t, []score.ScorerError{
+		{Scorer: "skipped", Policy: "skip", Error: "unavailable"},
+		{Scorer: "fallback", Policy: "fallback", Error: "unavailable"},
+	}, result.Errors)
+}
+
+func TestCompositeScorer_FailPolicy(t *testing.T) {
+	failure := errors.New("unavailable")
+	cs := NewCompositeScorer([]Member{
+		{Name: "ml", Scorer: &staticScorer{err: failure}},
+	}, newRepo(), 0, Aggregator{}, score.Verdicts{})
+
+	_, err := cs.Score(context.Background(), "user1")
+	assert.ErrorIs(t, err, failure)
+	assert.NotErrorIs(t, err, ErrSessionNotFound)
+}
+
+func TestCompositeScorer_Parallel(t *testing.T) {
+	cs := NewCompositeScorer([]Member{
+		{Name: "a", Scorer: &staticScorer{score: score.Score{"automation": 0.1}, delay: 50 * time.Millisecond}},
+		{Name: "b", Scorer: &staticScorer{score: score.Score{"automation": 0.2}, delay: 50 * time.Millisecond}},
+		{Name: "c", Scorer: &staticScorer{score: score.Score{"automation": 0.3}, delay: 50 * time.Millisecond}},
+	}, newRepo(), 0, Aggregator{}, score.Verdicts{})
+
+	start := time.Now()
+	result, err := cs.Score(context.Background(), "user1")
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 140*time.Millisecond, "scorers should run concurrently")
+	assert.InDelta(t, 0.6, result.Score["automation"], 1e-6)
+}
+
+func TestCompositeScorer_Deadline(t *testing.T) {
+	cs := NewCompositeScorer([]Member{
+		{Name: "rules", Scorer: &staticScorer{score: score.Score{"automation": 0.2}}},
+		{Name: "slow", Scorer: &staticScorer{score: score.Score{"automation": 0.5}, delay: time.Second}, Deadline: 20 * time.Millisecond},
+		{Name: "ml", Scorer: &blockingScorer{}, Deadline: 20 * time.Millisecond, OnError: ErrorPolicyFallback, Fallback: score.Score{"automation": 0.1}},
+	}, newRepo(), 0, Aggregator{}, score.Verdicts{})
+
+	start := time.Now()
+	result, err := cs.Score(context.Background(), "user1")
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "deadline should not wait for the slow scorer")
+	assert.InDelta(t, 0.3, result.Score["automation"], 1e-6, "timed out fallback scorer should use fallback score")
+	assert.Equal(t, []string{"slow", "ml"}, result.Timeouts)
+	assert.Empty(t, result.Errors)
+}
+
+func TestCompositeScorer_OwnDeadlineExceeded(t *testing.T) {
+	// The scorer fails with its own deadline (e.g. an HTTP client timeout) before the budget
+	cs := NewCompositeScorer([]Member{
+		{Name: "rules", Scorer: &staticScorer{score: score.Score{"automation": 0.2}}},
+		{Name: "ml", Scorer: &staticScorer{err: context.DeadlineExceeded}, Deadline: time.Second, OnError: ErrorPolicySkip},
+	}, newRepo(), time.Second, Aggregator{}, score.Verdicts{})
+
+	result, err := cs.Score(context.Background(), "user1")
+	require.NoError(t, err)
+	assert.Empty(t, result.Timeouts)
+	assert.Equal(t, []score.ScorerError{{Scorer: "ml", Policy: "skip", Error: context.DeadlineExceeded.Error()}}, result.Errors)
+
+	cs = NewCompositeScorer([]Member{
+		{Name: "ml", Scorer: &staticScorer{err: context.DeadlineExceeded}},
+	}, newRepo(), time.Second, Aggregator{}, score.Verdicts{})
+	_, err = cs.Score(context.Background(), "user1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "fail policy should apply to the scorer error")
+}
+
+func TestCompositeScorer_Budget(t *testing.T) {
+	cs := NewCompositeScorer([]Member{
+		{Name: "fast", Scorer: &staticScorer{score: score.Score{"automation": 0.2}}},
+		{Name: "slow", Scorer: &blockingScorer{}},
+	}, newRepo(), 20*time.Millisecond, Aggregator{}, score.Verdicts{})
+
+	result, err := cs.Score(context.Background(), "user1")
+	require.NoError(t, err)
+	assert.InDelta(t, 0.2, result.Score["automation"], 1e-6)
+	assert.Equal(t, []string{"slow"}, result.Timeouts)
+}
+
+func TestCompositeScorer_Canceled(t *testing.T) {
+	cs := NewCompositeScorer([]Member{
+		{Name: "slow", Scorer: &blockingScorer{}},
+	}, newRepo(), 0, Aggregator{}, score.Verdicts{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := cs.Score(ctx, "user1")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// countingScorer counts its invocations.
+type countingScorer struct {
+	calls atomic.Int32
+	err   error
+}
+
+func (s *countingScorer) Score(ctx context.Context, traces []trace.Trace) (score.Score, error) {
+	s.calls.Add(1)
+	return score.Score{"automation": float32(len(traces)) / 10}, s.err
+}
+
+func TestCompositeScorer_Cache(t *testing.T) {
+	repo := newRepo()
+	counter := &countingScorer{}
+	cs := NewCompositeScorer([]Member{{Name: "rules", Scorer: counter}}, repo, 0, Aggregator{}, score.Verdicts{})
+
+	first, err := cs.Score(context.Background(), "user1")
+	require.NoError(t, err)
+	second, err := cs.Score(context.Background(), "user1")
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, int32(1), counter.calls.Load(), "repeated request should use the cached score")
+
+	// A new trace invalidates the cached score
+	repo.Append("user1", trace.Trace{"mouseMoves": 2})
+	third, err := cs.Score(context.Background(), "user1")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), counter.calls.Load())
+	assert.InDelta(t, 0.2, third.Score["automation"], 1e-6)
+}
+
+// gatedScorer scores sessions by the mouseMoves of the last trace, waiting for the gate first.
+type gatedScorer struct {
+	started chan struct{}
+	gate    chan struct{}
+}
+
+func (s *gatedScorer) Score(ctx context.Context, traces []trace.Trace) (score.Score, error) {
+	s.started <- struct{}{}
+	<-s.gate
+	moves, _ := traces[len(traces)-1]["mouseMoves"].(int)
+	return score.Score{"automation": float32(moves) / 10}, nil
+}
+
+func TestCompositeScorer_CacheEvictedSession(t *testing.T) {
+	repo := newRepo()
+	gated := &gatedScorer{started: make(chan struct{}, 2), gate: make(chan struct{}, 2)}
+	cs := NewCompositeScorer([]Member{{Name: "rules", Scorer: gated}}, repo, 0, Aggregator{}, score.Verdicts{})
+
+	done := make(chan score.Result)
+	go func() {
+		result, _ := cs.Score(context.Background(), "user1")
+		done <- result
+	}()
+
+	// The session is evicted and recreated with the same version while it is scored
+	<-gated.started
+	require.True(t, repo.Delete("user1"))
+	repo.Append("user1", trace.Trace{"mouseMoves": 5})
+	gated.gate <- struct{}{}
+	assert.InDelta(t, 0.1, (<-done).Score["automation"], 1e-6)
+
+	gated.gate <- struct{}{}
+	result, err := cs.Score(context.Background(), "user1")
+	require.NoError(t, err)
+	assert.InDelta(t, 0.5, result.Score["automation"], 1e-6, "result of the evicted session should not be cached")
+}
+
+func TestCompositeScorer_Last(t *testing.T) {
+	repo := newRepo()
+	counter := &countingScorer{}
+	cs := NewCompositeScorer([]Member{{Name: "rules", Scorer: counter}}, repo, 0, Aggregator{}, score.Verdicts{})
+
+	_, found := cs.Last("user1")
+	assert.False(t, found, "session without a computed score")
+
+	first, err := cs.Score(context.Background(), "user1")
+	require.NoError(t, err)
+	repo.Append("user1", trace.Trace{"mouseMoves": 2})
+	require.NoError(t, cs.SetAdjustment("user1", score.Score{"automation": 0.5}))
+
+	last, found := cs.Last("user1")
+	require.True(t, found)
+	assert.Equal(t, int32(1), counter.calls.Load(), "last score should not run the scorers")
+	assert.InDelta(t, first.Score["automation"]+0.5, last.Score["automation"], 1e-6, "last score should be adjusted")
+	assert.True(t, last.Adjusted)
+}
+
+func TestCompositeScorer_CachePartialResult(t *testing.T) {
+	counter := &countingScorer{err: errors.New("unavailable")}
+	cs := NewCompositeScorer([]Member{{Name: "ml", Scorer: counter, OnError: ErrorPolicySkip}}, newRepo(), 0, Aggregator{}, score.Verdicts{})
+
+	_, err := cs.Score(context.Background(), "user1")
+	require.NoError(t, err)
+	_, err = cs.Score(context.Background(), "user1")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), counter.calls.Load(), "results with scorer errors should not be cached")
+}
+
+func TestCompositeScorer_Verdict(t *testing.T) {
+	verdicts := score.Verdicts{
+		Key:   "automation",
+		Bands: []score.Band{{Name: "human", Min: 0}, {Name: "challenge", Min: 0.5}, {Name: "bot", Min: 0.8}},
+	}
+
+	tests := []struct {
+		automation float32
+		expected   string
+	}{
+		{0.1, "human"},
+		{0.5, "challenge"},
+		{0.79, "challenge"},
+		{0.9, "bot"},
+	}
+
+	for _, tt := range tests {
+		cs := NewCompositeScorer([]Member{
+			{Name: "rules", Scorer: &staticScorer{score: score.Score{"automation": tt.automation}}},
+		}, newRepo(), 0, Aggregator{}, verdicts)
+
+		result, err := cs.Score(context.Background(), "user1")
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, result.Verdict, "automation=%v", tt.automation)
+	}
+}
+
+func TestCompositeScorer_Override(t *testing.T) {
+	repo := newRepo()
+	cs := NewCompositeScorer([]Member{
+		{Name: "rules", Scorer: &staticScorer{score: score.Score{"automation": 0.9}}},
+	}, repo, 0, Aggregator{}, score.Verdicts{Key: "automation", Bands: []score.Band{{Name: "human", Min: 0}, {Name: "bot", Min: 0.8}}})
+
+	assert.ErrorIs(t, cs.SetOverride("user1", "unknown"), ErrUnknownVerdict)
+	assert.ErrorIs(t, cs.SetOverride("user2", score.VerdictAllow), ErrSessionNotFound)
+
+	// The override applies to cached results as well
+	_, err := cs.Score(context.Background(), "user1")
+	require.NoError(t, err)
+	require.NoError(t, cs.SetOverride("user1", score.VerdictAllow))
+	result, err := cs.Score(context.Background(), "user1")
+	require.NoError(t, err)
+	assert.Equal(t, score.VerdictAllow, result.Verdict)
+	assert.True(t, result.Override)
+	assert.Equal(t, float32(0.9), result.Score["automation"], "the score is not affected")
+
+	require.NoError(t, cs.SetOverride("user1", "human"))
+	result, _ = cs.Score(context.Background(), "user1")
+	assert.Equal(t, "human", result.Verdict)
+
+	assert.True(t, cs.ClearOverride("user1"))
+	assert.False(t, cs.ClearOverride("user1"))
+	result, _ = cs.Score(context.Background(), "user1")
+	assert.Equal(t, "bot", result.Verdict)
+	assert.False(t, result.Override)
+
+	// Overrides are removed together with the session
+	require.NoError(t, cs.SetOverride("user1", score.VerdictDeny))
+	repo.Delete("user1")
+	_, found := cs.Override("user1")
+	assert.False(t, found)
+}
+
+func TestCompositeScorer_Adjustment(t *testing.T) {
+	repo := newRepo()
+	cs := NewCompositeScorer([]Member{
+		{Name: "rules", Scorer: &staticScorer{score: score.Score{"automation": 0.9}}},
+	}, repo, 0, Aggregator{}, score.Verdicts{Key: "automation", Bands: []score.Band{{Name: "human", Min: 0}, {Name: "bot", Min: 0.8}}})
+
+	assert.ErrorIs(t, cs.SetAdjustment("user2", score.Score{"automation": -0.3}), ErrSessionNotFound)
+
+	_, err := cs.Score(context.Background(), "user1")
+	require.NoError(t, err)
+	require.NoError(t, cs.SetAdjustment("user1", score.Score{"automation": -0.3, "human": 2}))
+
+	// The adjustment applies to cached results without changing the cache
+	for range 2 {
+		result, err := cs.Score(context.Background(), "user1")
+		require.NoError(t, err)
+		assert.InDelta(t, 0.6, result.Score["automation"], 0.0001)
+		assert.Equal(t, float32(1), result.Score["human"], "adjusted values are clamped")
+		assert.Equal(t, "human", result.Verdict)
+		assert.True(t, result.Adjusted)
+	}
+
+	// Adjustments are removed together with the session
+	repo.Delete("user1")
+	repo.Append("user1", trace.Trace{})
+	result, err := cs.Score(context.Background(), "user1")
+	require.NoError(t, err)
+	assert.Equal(t, "bot", result.Verdict)
+	assert.False(t, result.Adjusted)
+}
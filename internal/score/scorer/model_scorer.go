@@ -0,0 +1,39 @@
+package scorer
+
+import (
+	"bean/internal/score"
+	"bean/internal/score/model"
+	"bean/internal/trace"
+	"context"
+)
+
+// ModelScorer is a scorer implementation that evaluates a trained model in-process.
+// Session features are derived from the traces (see model.SessionFeatures)
+// and the model output is written to the configured score key.
+type ModelScorer struct {
+	model model.Model // trained model evaluated over session features
+	key   string      // score key the model output is written to
+}
+
+// Score derives session features from the traces and evaluates the model.
+// Returns an empty score if there are no traces.
+// The context is passed for Scorer interface compatibility but is not used.
+func (ms *ModelScorer) Score(ctx context.Context, traces []trace.Trace) (score.Score, error) {
+	result := make(score.Score)
+	if len(traces) == 0 {
+		return result, nil
+	}
+
+	result[ms.key] = float32(ms.model.Predict(model.SessionFeatures(traces)))
+	return result, nil
+}
+
+// NewModelScorer creates a new instance of ModelScorer.
+// Parameters:
+//   - m: trained model
+//   - key: score key the model output is written to
+//
+// Returns a pointer to the initialized scorer.
+func NewModelScorer(m model.Model, key string) *ModelScorer {
+	return &ModelScorer{model: m, key: key}
+}
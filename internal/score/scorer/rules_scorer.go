@@ -1,20 +1,43 @@
 package scorer
 
 import (
+	"bean/internal/metrics"
 	"bean/internal/score"
 	"bean/internal/score/rule"
 	"bean/internal/trace"
 	"context"
 	"log/slog"
+	"sync/atomic"
 )
 
 // RulesScorer is a scorer implementation that calculates a score based on a set of rules.
 // Each rule evaluates an individual trace, and the resulting scores are accumulated
 // within the specified min and max boundaries.
+//
+// RulesScorer implements score.IncrementalScorer: the rules fired by a trace can be
+// computed once at ingest (Annotate) and folded into the session score later (Fold).
+// It also implements score.ObservingScorer, so the fired rules are counted once per trace at ingest
+// when the scorer is not incremental.
+//
+// The rules can be replaced at runtime with SetRules. Annotations computed with
+// the previous rules are ignored by Fold and the traces are evaluated again.
 type RulesScorer struct {
-	rules []rule.Rule // set of rules to be applied to traces
-	min   float32     // minimum allowed value for any score component
-	max   float32     // maximum allowed value for any score component
+	rules atomic.Pointer[ruleSet] // current set of rules to be applied to traces
+	min   float32                 // minimum allowed value for any score component
+	max   float32                 // maximum allowed value for any score component
+}
+
+// ruleSet is a set of rules together with its generation, incremented on every replacement.
+type ruleSet struct {
+	rules      []rule.Rule
+	generation uint64
+}
+
+// annotation is the result of Annotate: the scores of the fired rules
+// and the generation of the rules that produced them.
+type annotation struct {
+	deltas     []score.Score
+	generation uint64
 }
 
 // Score computes the final score by applying all rules to each of the provided traces.
@@ -29,30 +52,105 @@ type RulesScorer struct {
 //   - nil as error (rule errors do not halt execution).
 func (rs *RulesScorer) Score(ctx context.Context, traces []trace.Trace) (score.Score, error) {
 	score := make(score.Score)
+	set := rs.rules.Load()
 
 	for _, trace := range traces {
-		for _, rule := range rs.rules {
-			delta, err := rule.Eval(trace)
-			if err != nil {
-				slog.Error("rule eval", "error", err, "rule", rule, "trace", trace)
-				continue
-			}
+		for _, delta := range set.eval(trace, false) {
+			rs.add(score, delta)
+		}
+	}
+
+	return score, nil
+}
+
+// Annotate evaluates all rules on the trace and returns the scores of the fired rules
+// in the order of the rules, tagged with the generation of the rules.
+// The fired rules are counted in the rule fires metric.
+func (rs *RulesScorer) Annotate(t trace.Trace) any {
+	set := rs.rules.Load()
+	return annotation{deltas: set.eval(t, true), generation: set.generation}
+}
+
+// Observe evaluates all rules on the ingested trace and counts the fired rules
+// in the rule fires metric. Score and Fold don't count fires, since they evaluate
+// the same traces on every recomputation of the session score.
+func (rs *RulesScorer) Observe(t trace.Trace) {
+	rs.rules.Load().eval(t, true)
+}
+
+// Fold computes the final score from the fired rules stored for each trace.
+// Traces without an annotation or annotated with replaced rules are evaluated.
+// The result is the same as the result of Score for the same traces.
+// The context is passed for interface compatibility but is not used.
+func (rs *RulesScorer) Fold(ctx context.Context, traces []trace.Trace, annotations []any) (score.Score, error) {
+	result := make(score.Score)
+	set := rs.rules.Load()
+
+	for i, trace := range traces {
+		a, ok := annotations[i].(annotation)
+		deltas := a.deltas
+		if !ok || a.generation != set.generation {
+			deltas = set.eval(trace, false)
+		}
+		for _, delta := range deltas {
+			rs.add(result, delta)
+		}
+	}
+
+	return result, nil
+}
+
+// SetRules replaces the rules. Scores calculated after the call use the new rules.
+// The method is thread-safe.
+func (rs *RulesScorer) SetRules(rules []rule.Rule) {
+	for {
+		old := rs.rules.Load()
+		set := &ruleSet{rules: rules}
+		if old != nil {
+			set.generation = old.generation + 1
+		}
+		if rs.rules.CompareAndSwap(old, set) {
+			return
+		}
+	}
+}
 
-			for key, d := range delta {
-				newScore := score[key] + d
-				switch {
-				case newScore < rs.min:
-					score[key] = rs.min
-				case newScore > rs.max:
-					score[key] = rs.max
-				default:
-					score[key] = newScore
-				}
+// eval applies all rules to the trace and returns the scores of the fired rules.
+// If a rule evaluation fails, the error is logged and the rule is skipped.
+// If count is set, fired rules are counted in the rule fires metric.
+func (set *ruleSet) eval(t trace.Trace, count bool) []score.Score {
+	var deltas []score.Score
+	for _, rule := range set.rules {
+		delta, err := rule.Eval(t)
+		if err != nil {
+			slog.Error("rule eval", "error", err, "rule", rule, "trace", t)
+			continue
+		}
+
+		if len(delta) > 0 {
+			if count {
+				metrics.RuleFires.Inc(rule.Id)
 			}
+			deltas = append(deltas, delta)
 		}
 	}
 
-	return score, nil
+	return deltas
+}
+
+// add adds the delta to the score, clamping each component within min and max.
+func (rs *RulesScorer) add(s score.Score, delta score.Score) {
+	for key, d := range delta {
+		newScore := s[key] + d
+		switch {
+		case newScore < rs.min:
+			s[key] = rs.min
+		case newScore > rs.max:
+			s[key] = rs.max
+		default:
+			s[key] = newScore
+		}
+	}
 }
 
 // NewRulesScorer creates a new instance of RulesScorer.
@@ -66,9 +164,9 @@ func (rs *RulesScorer) Score(ctx context.Context, traces []trace.Trace) (score.S
 // Returns a pointer to the initialized scorer.
 func NewRulesScorer(rules []rule.Rule, min, max float32) *RulesScorer {
 	scorer := RulesScorer{
-		rules: rules,
-		min:   min,
-		max:   max,
+		min: min,
+		max: max,
 	}
+	scorer.SetRules(rules)
 	return &scorer
 }
@@ -0,0 +1,173 @@
+package scorer
+
+import (
+	"bean/internal/metrics"
+	"bean/internal/score"
+	"bean/internal/score/rule"
+	"bean/internal/trace"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRules creates n compiled rules with different thresholds.
+func newTestRules(tb testing.TB, n int) []rule.Rule {
+	rules := make([]rule.Rule, n)
+	for i := range rules {
+		rules[i] = rule.Rule{
+			When: fmt.Sprintf("mouseMoves > %d && clicks < %d", i*5, 10+i),
+			Then: score.Score{"automation": 0.05, "device": -0.02},
+		}
+		env, err := trace.NewMovementTraceEnv()
+		require.NoError(tb, err)
+		require.NoError(tb, rules[i].Init(env))
+	}
+	return rules
+}
+
+// newTestTraces creates n traces with varying metrics.
+func newTestTraces(n int) []trace.Trace {
+	traces := make([]trace.Trace, n)
+	for i := range traces {
+		traces[i] = trace.Trace{
+			"mouseMoves": int64(i * 7 % 100),
+			"clicks":     int64(i % 15),
+		}
+	}
+	return traces
+}
+
+func TestRulesScorer_FoldEqualsScore(t *testing.T) {
+	rs := NewRulesScorer(newTestRules(t, 20), -1.0, 1.0)
+	traces := newTestTraces(50)
+
+	annotations := make([]any, len(traces))
+	for i, tr := range traces {
+		// Leave some traces without annotation: they are evaluated during the fold
+		if i%3 != 0 {
+			annotations[i] = rs.Annotate(tr)
+		}
+	}
+
+	expected, err := rs.Score(context.Background(), traces)
+	require.NoError(t, err)
+	folded, err := rs.Fold(context.Background(), traces, annotations)
+	require.NoError(t, err)
+	assert.Equal(t, expected, folded)
+}
+
+func TestRulesScorer_FireMetric(t *testing.T) {
+	traces := []trace.Trace{
+		{"mouseMoves": int64(7), "clicks": int64(1)},
+		{"mouseMoves": int64(2), "clicks": int64(1)},
+	}
+
+	for _, incremental := range []bool{false, true} {
+		rules := newTestRules(t, 2)
+		rules[0].Id = fmt.Sprintf("test-fires-%t-0", incremental)
+		rules[1].Id = fmt.Sprintf("test-fires-%t-1", incremental)
+		before := []float64{metrics.RuleFires.Value(rules[0].Id), metrics.RuleFires.Value(rules[1].Id)}
+
+		repo := trace.NewTracesRepository(5, 0)
+		rs := NewRulesScorer(rules, -1, 1)
+		cs := NewCompositeScorer([]Member{{Name: "rules", Scorer: rs, Incremental: incremental}}, repo, 0, Aggregator{}, score.Verdicts{})
+
+		// Each trace is counted once at ingest, not on every score recomputation
+		for _, tr := range traces {
+			repo.Append("a", tr)
+			_, err := cs.Score(context.Background(), "a")
+			require.NoError(t, err)
+		}
+		_, err := rs.Score(context.Background(), traces)
+		require.NoError(t, err)
+
+		assert.Equal(t, float64(2), metrics.RuleFires.Value(rules[0].Id)-before[0], "incremental %t", incremental)
+		assert.Equal(t, float64(1), metrics.RuleFires.Value(rules[1].Id)-before[1], "incremental %t", incremental)
+	}
+}
+
+func TestCompositeScorer_Incremental(t *testing.T) {
+	repo := trace.NewTracesRepository(5, 0)
+	rs := NewRulesScorer(newTestRules(t, 20), -1.0, 1.0)
+	cs := NewCompositeScorer([]Member{{Name: "rules", Scorer: rs, Incremental: true}}, repo, 0, Aggregator{}, score.Verdicts{})
+
+	// More traces than the buffer holds: the fold must ignore evicted traces
+	traces := newTestTraces(12)
+	for _, tr := range traces {
+		repo.Append("user1", tr)
+	}
+
+	entries, _, _ := repo.GetEntries("user1")
+	for _, e := range entries {
+		assert.Contains(t, e.Annotations, "rules", "trace should be annotated at ingest")
+	}
+
+	expected, err := rs.Score(context.Background(), traces[len(traces)-5:])
+	require.NoError(t, err)
+	result, err := cs.Score(context.Background(), "user1")
+	require.NoError(t, err)
+	for k, v := range expected {
+		assert.InDelta(t, clamp(float64(v), 0, 1), result.Score[k], 1e-6)
+	}
+}
+
+func TestRulesScorer_SetRules(t *testing.T) {
+	repo := trace.NewTracesRepository(5, 0)
+	rs := NewRulesScorer(newTestRules(t, 1), -1.0, 1.0)
+	cs := NewCompositeScorer([]Member{{Name: "rules", Scorer: rs, Incremental: true}}, repo, 0, Aggregator{}, score.Verdicts{})
+	repo.Append("user1", trace.Trace{"mouseMoves": int64(7), "clicks": int64(1)})
+
+	result, err := cs.Score(context.Background(), "user1")
+	require.NoError(t, err)
+	assert.InDelta(t, 0.05, result.Score["automation"], 1e-6)
+
+	// Annotations made with the replaced rules must not be folded
+	rules := newTestRules(t, 1)
+	rules[0].Then = score.Score{"automation": 0.5}
+	rs.SetRules(rules)
+	cs.ResetCache()
+
+	result, err = cs.Score(context.Background(), "user1")
+	require.NoError(t, err)
+	assert.InDelta(t, 0.5, result.Score["automation"], 1e-6)
+}
+
+// benchmarkRulesScorer compares full recomputation with folding the annotations stored at ingest.
+func benchmarkRulesScorer(b *testing.B, rulesCount, tracesLength int, incremental bool) {
+	rs := NewRulesScorer(newTestRules(b, rulesCount), -1.0, 1.0)
+	traces := newTestTraces(tracesLength)
+	annotations := make([]any, len(traces))
+	for i, tr := range traces {
+		annotations[i] = rs.Annotate(tr)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if incremental {
+			rs.Fold(context.Background(), traces, annotations)
+		} else {
+			rs.Score(context.Background(), traces)
+		}
+	}
+}
+
+func BenchmarkRulesScorer_Full_10x10(b *testing.B)         { benchmarkRulesScorer(b, 10, 10, false) }
+func BenchmarkRulesScorer_Incremental_10x10(b *testing.B)  { benchmarkRulesScorer(b, 10, 10, true) }
+func BenchmarkRulesScorer_Full_50x100(b *testing.B)        { benchmarkRulesScorer(b, 50, 100, false) }
+func BenchmarkRulesScorer_Incremental_50x100(b *testing.B) { benchmarkRulesScorer(b, 50, 100, true) }
+
+// BenchmarkRulesScorer_Annotate measures the cost paid once per trace at ingest in incremental mode.
+func BenchmarkRulesScorer_Annotate(b *testing.B) {
+	rs := NewRulesScorer(newTestRules(b, 50), -1.0, 1.0)
+	t := newTestTraces(1)[0]
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		rs.Annotate(t)
+	}
+}
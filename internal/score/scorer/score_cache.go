@@ -0,0 +1,106 @@
+package scorer
+
+import (
+	"bean/internal/score"
+	"maps"
+	"sync"
+)
+
+// cachedResult is a score result computed for a specific version of the session traces.
+type cachedResult struct {
+	version uint64       // version of the session traces the result was computed for
+	result  score.Result // computed result
+}
+
+// scoreCache stores the last computed score result of each session.
+// A result is valid only for the session version it was computed for,
+// so appending a trace to the session invalidates it.
+// Every delete or reset starts a new cache generation; results computed in an older
+// generation are not stored, so a result of an evicted session can't be matched
+// by the version of a session recreated with the same ID.
+//
+// scoreCache is thread-safe.
+type scoreCache struct {
+	entries    map[string]cachedResult // cached results by session ID
+	generation uint64                  // incremented on every delete and reset
+	mu         sync.RWMutex            // mutex to protect access to entries and generation
+}
+
+// currentGeneration returns the generation to pass to put for a result computed from now on.
+func (c *scoreCache) currentGeneration() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.generation
+}
+
+// get returns a copy of the cached result of the session if it was computed for the given version.
+func (c *scoreCache) get(id string, version uint64) (score.Result, bool) {
+	c.mu.RLock()
+	entry, found := c.entries[id]
+	c.mu.RUnlock()
+
+	if !found || entry.version != version {
+		return score.Result{}, false
+	}
+
+	result := entry.result
+	result.Score = maps.Clone(entry.result.Score)
+	return result, true
+}
+
+// last returns a copy of the last cached result of the session, whatever version it was computed for.
+func (c *scoreCache) last(id string) (score.Result, bool) {
+	c.mu.RLock()
+	entry, found := c.entries[id]
+	c.mu.RUnlock()
+
+	if !found {
+		return score.Result{}, false
+	}
+
+	result := entry.result
+	result.Score = maps.Clone(entry.result.Score)
+	return result, true
+}
+
+// put stores the result computed for the given session version in the given cache generation.
+// A result for an older version does not replace a newer one, and a result of an older
+// generation is dropped.
+func (c *scoreCache) put(id string, generation, version uint64, result score.Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	if entry, found := c.entries[id]; found && entry.version > version {
+		return
+	}
+
+	result.Score = maps.Clone(result.Score)
+	c.entries[id] = cachedResult{version: version, result: result}
+}
+
+// delete removes the cached result of the session.
+func (c *scoreCache) delete(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, id)
+	c.generation++
+}
+
+// reset removes all cached results.
+func (c *scoreCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.entries)
+	c.generation++
+}
+
+// newScoreCache creates an empty score cache.
+func newScoreCache() *scoreCache {
+	return &scoreCache{entries: make(map[string]cachedResult)}
+}
@@ -10,3 +10,50 @@ type Score map[string]float32
 type TracesScorer interface {
 	Score(ctx context.Context, traces []trace.Trace) (Score, error)
 }
+
+// IncrementalScorer is a TracesScorer able to evaluate each trace once, when it is ingested.
+// The per-trace annotation is stored alongside the trace, and the session score
+// is a fold over the annotations of the stored traces.
+type IncrementalScorer interface {
+	TracesScorer
+	trace.Annotator
+	// Fold computes the session score from the traces and their annotations.
+	// annotations[i] belongs to traces[i] and is nil if the trace has not been annotated;
+	// such traces are evaluated during the fold.
+	// The result must be equal to the result of Score for the same traces.
+	Fold(ctx context.Context, traces []trace.Trace, annotations []any) (Score, error)
+}
+
+// ObservingScorer is a TracesScorer that observes each trace once, when it is ingested,
+// e.g. to count metrics that must not be repeated on every recomputation of the score.
+type ObservingScorer interface {
+	TracesScorer
+	// Observe is called once for each ingested trace.
+	Observe(t trace.Trace)
+}
+
+// Result is the outcome of the session scoring returned by the score API.
+type Result struct {
+	// Score — aggregated session score.
+	Score Score `json:"score"`
+	// Verdict — name of the verdict band of the score, empty if verdicts are not configured.
+	Verdict string `json:"verdict,omitempty"`
+	// Override — the verdict is set by an operator instead of being classified from the score.
+	Override bool `json:"override,omitempty"`
+	// Adjusted — the score includes the adjustment of the session (e.g., for a solved challenge).
+	Adjusted bool `json:"adjusted,omitempty"`
+	// Errors — failures of scorers that were skipped or replaced by a fallback score.
+	Errors []ScorerError `json:"errors,omitempty"`
+	// Timeouts — names of scorers that did not finish in time.
+	Timeouts []string `json:"timeouts,omitempty"`
+}
+
+// ScorerError describes a scorer failure that did not abort the scoring.
+type ScorerError struct {
+	// Scorer — name of the failed scorer.
+	Scorer string `json:"scorer"`
+	// Policy — applied error policy: skip or fallback.
+	Policy string `json:"policy"`
+	// Error — error message.
+	Error string `json:"error"`
+}
@@ -0,0 +1,51 @@
+package score
+
+// Verdicts set by an operator to allow or deny a session regardless of its score.
+const (
+	VerdictAllow = "allow"
+	VerdictDeny  = "deny"
+)
+
+// Band is a verdict range of a score key. The band applies to values
+// greater than or equal to Min and lower than Min of the next band.
+type Band struct {
+	// Name — verdict name (e.g., "human", "challenge", "bot").
+	Name string
+	// Min — lower bound of the band (inclusive).
+	Min float32
+}
+
+// Verdicts classifies scores into verdict bands by the value of a score key.
+// The zero value does not assign verdicts.
+type Verdicts struct {
+	// Key — score key used for classification.
+	Key string
+	// Bands — verdict bands sorted by Min in ascending order.
+	Bands []Band
+}
+
+// Classify returns the name of the band containing the value of the key.
+// A missing key is treated as 0. Returns an empty string if no bands are configured
+// or the value is below the first band.
+func (v *Verdicts) Classify(s Score) string {
+	value := s[v.Key]
+	verdict := ""
+	for _, band := range v.Bands {
+		if value < band.Min {
+			break
+		}
+		verdict = band.Name
+	}
+
+	return verdict
+}
+
+// Has reports whether a band with the name is configured.
+func (v *Verdicts) Has(name string) bool {
+	for _, band := range v.Bands {
+		if band.Name == name {
+			return true
+		}
+	}
+	return false
+}
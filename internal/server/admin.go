@@ -0,0 +1,97 @@
+package server
+
+import (
+	"net/http"
+	"time"
+)
+
+// AdminRouter serves the operational endpoints of the service.
+// The endpoints are mounted either on the API server or on a separate admin listener.
+type AdminRouter struct {
+	// metrics — handler writing the metrics in the Prometheus text format.
+	metrics http.Handler
+
+	// health — liveness, readiness and version of the service.
+	health *Health
+
+	// api — admin API for managing sessions, nil if disabled.
+	api *AdminApiRouter
+
+	// auth — authenticator of the metrics and version routes, nil if they are public.
+	auth *Authenticator
+}
+
+// Register adds the admin routes to the mux of a separate admin listener:
+// - GET /metrics — metrics in the Prometheus text format (authorized if auth is set)
+// - GET /healthz — liveness probe
+// - GET /readyz — readiness probe
+// - GET /version — build version, rule file checksums and configuration hash (authorized if auth is set)
+// - /admin/v1/... — admin API (if enabled, authorized by its own keys)
+//
+// The probes are always public, so orchestrators can check the service without a key.
+func (ad *AdminRouter) Register(mux *http.ServeMux) {
+	ad.register(mux, false)
+}
+
+// register adds the admin routes to the mux. On the public API listener the metrics
+// and version routes are added only if auth is set: per-rule fire counts and score
+// distributions would otherwise let bots tune themselves against the rules.
+func (ad *AdminRouter) register(mux *http.ServeMux, public bool) {
+	if ad.auth != nil || !public {
+		mux.HandleFunc("GET /metrics", ad.auth.Wrap(ad.metrics.ServeHTTP))
+		mux.HandleFunc("GET /version", ad.auth.Wrap(ad.health.versionHandler))
+	}
+	mux.HandleFunc("GET /healthz", ad.health.healthzHandler)
+	mux.HandleFunc("GET /readyz", ad.health.readyzHandler)
+	if ad.api != nil {
+		ad.api.Register(mux)
+	}
+}
+
+// WithAuth requires an API key for the metrics and version routes.
+// Returns the router itself for chaining.
+func (ad *AdminRouter) WithAuth(auth *Authenticator) *AdminRouter {
+	ad.auth = auth
+	return ad
+}
+
+// WithApi enables the admin API for managing sessions.
+// Returns the router itself for chaining.
+func (ad *AdminRouter) WithApi(api *AdminApiRouter) *AdminRouter {
+	ad.api = api
+	return ad
+}
+
+// NewAdminRouter creates a new admin router.
+//
+// Parameters:
+//   - metrics: handler writing the metrics
+//   - health: liveness, readiness and version of the service
+//
+// Returns a pointer to the configured AdminRouter instance.
+func NewAdminRouter(metrics http.Handler, health *Health) *AdminRouter {
+	return &AdminRouter{metrics: metrics, health: health}
+}
+
+// NewAdminServer creates a server listening on a separate address
+// and serving only the admin routes.
+//
+// Parameters:
+// - address: address and port to listen on (e.g., "127.0.0.1:9090").
+// - admin: admin routes.
+//
+// Returns pointer to a ready-to-run server.
+func NewAdminServer(address string, admin *AdminRouter) *Server {
+	mux := http.NewServeMux()
+	admin.Register(mux)
+
+	s := Server{server: &http.Server{
+		Addr:           address,
+		Handler:        mux,
+		ReadTimeout:    time.Second * 3,
+		WriteTimeout:   time.Second * 10,
+		MaxHeaderBytes: 1024 * 10,
+	}}
+
+	return &s
+}
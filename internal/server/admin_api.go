@@ -0,0 +1,320 @@
+package server
+
+import (
+	"bean/internal/dataset"
+	"bean/internal/notification"
+	"bean/internal/score"
+	"bean/internal/score/scorer"
+	"bean/internal/trace"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// defaultSessionsLimit is the page size of the sessions list if the limit is not requested.
+const defaultSessionsLimit = 50
+
+// AdminApiRouter serves the admin API for inspecting and managing sessions.
+// All endpoints require an admin API key.
+type AdminApiRouter struct {
+	// auth — authenticator of the operators, with keys separate from the score API keys.
+	auth *Authenticator
+
+	// tracesRepo — storage of the inspected sessions.
+	tracesRepo *trace.TracesRepository
+
+	// compositeScorer — scorer of the sessions holding the verdict overrides.
+	compositeScorer *scorer.CompositeScorer
+
+	// reload — reloads the rules and returns the checksums of the loaded rule files.
+	reload func() ([]FileChecksum, error)
+
+	// datasetRepo — dataset the deleted sessions are erased from, nil if disabled.
+	datasetRepo dataset.DatasetRepository
+
+	// notifier — notifier the undelivered events of deleted sessions are erased from, nil if disabled.
+	notifier *notification.Notifier
+}
+
+// sessionSummary is a session of the sessions list.
+type sessionSummary struct {
+	// Token — session token.
+	Token string `json:"token"`
+	// LastActivity — time the last trace was received.
+	LastActivity time.Time `json:"last_activity"`
+	// Traces — number of stored traces.
+	Traces int `json:"traces"`
+	// Score — session score, present only if the list is filtered by score or verdict.
+	Score *score.Result `json:"score,omitempty"`
+	// Override — verdict pinned by an operator, empty if none.
+	Override string `json:"override,omitempty"`
+}
+
+// sessionsFilter selects sessions of the sessions list.
+type sessionsFilter struct {
+	activeAfter  time.Time // sessions active after the time, zero means no bound
+	activeBefore time.Time // sessions active before the time, zero means no bound
+	scoreKey     string    // score key compared with the score bounds
+	minScore     *float32  // minimal score value (inclusive), nil means no bound
+	maxScore     *float32  // maximal score value (inclusive), nil means no bound
+	verdict      string    // verdict of the session, empty means any
+}
+
+// Register adds the admin API routes to the mux:
+// - GET /admin/v1/sessions — lists sessions by last activity, newest first
+// - GET /admin/v1/sessions/{token} — returns the raw traces of a session
+// - DELETE /admin/v1/sessions/{token} — erases a session
+// - PUT /admin/v1/sessions/{token}/override — pins the verdict of a session
+// - DELETE /admin/v1/sessions/{token}/override — removes the pinned verdict
+// - POST /admin/v1/rules/reload — reloads the rule files
+func (aa *AdminApiRouter) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /admin/v1/sessions", aa.auth.Wrap(aa.sessionsHandler))
+	mux.HandleFunc("GET /admin/v1/sessions/{token}", aa.auth.Wrap(aa.sessionHandler))
+	mux.HandleFunc("DELETE /admin/v1/sessions/{token}", aa.auth.Wrap(aa.deleteSessionHandler))
+	mux.HandleFunc("PUT /admin/v1/sessions/{token}/override", aa.auth.Wrap(aa.setOverrideHandler))
+	mux.HandleFunc("DELETE /admin/v1/sessions/{token}/override", aa.auth.Wrap(aa.clearOverrideHandler))
+	mux.HandleFunc("POST /admin/v1/rules/reload", aa.auth.Wrap(aa.reloadHandler))
+}
+
+// sessionsHandler lists sessions sorted by last activity, newest first.
+//
+// Query parameters:
+// - offset, limit — page of the list (default limit 50)
+// - active_after, active_before — bounds of the last activity (RFC 3339)
+// - min_score, max_score — bounds of the score key value (inclusive)
+// - score_key — score key compared with the score bounds (default automation)
+// - verdict — verdict of the session
+//
+// If the list is filtered by score or verdict, the last computed score of each session is
+// compared without running the scorers; sessions that were never scored don't match.
+// Returns 400 if a parameter is malformed.
+func (aa *AdminApiRouter) sessionsHandler(w http.ResponseWriter, r *http.Request) {
+	filter, offset, limit, err := parseSessionsQuery(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	sessions := aa.tracesRepo.Sessions()
+	slices.SortFunc(sessions, func(a, b trace.Session) int {
+		return b.LastUpdate.Compare(a.LastUpdate)
+	})
+
+	scored := filter.minScore != nil || filter.maxScore != nil || filter.verdict != ""
+	selected := []sessionSummary{}
+	for _, session := range sessions {
+		if !filter.activeAfter.IsZero() && !session.LastUpdate.After(filter.activeAfter) {
+			continue
+		}
+		if !filter.activeBefore.IsZero() && !session.LastUpdate.Before(filter.activeBefore) {
+			continue
+		}
+
+		summary := sessionSummary{Token: session.Id, LastActivity: session.LastUpdate, Traces: session.Traces}
+		summary.Override, _ = aa.compositeScorer.Override(session.Id)
+		if scored {
+			result, found := aa.compositeScorer.Last(session.Id)
+			if !found || !filter.match(result) {
+				continue
+			}
+			summary.Score = &result
+		}
+		selected = append(selected, summary)
+	}
+
+	total := len(selected)
+	selected = selected[min(offset, total):min(offset+limit, total)]
+	writeJSON(w, http.StatusOK, map[string]any{"total": total, "sessions": selected})
+}
+
+// sessionHandler returns the raw traces of the session from old to new.
+// Returns 404 if the session has no traces.
+func (aa *AdminApiRouter) sessionHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+	traces, found := aa.tracesRepo.Get(token)
+	if !found {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
+		return
+	}
+
+	override, _ := aa.compositeScorer.Override(token)
+	writeJSON(w, http.StatusOK, map[string]any{"token": token, "traces": traces, "override": override})
+}
+
+// deleteSessionHandler erases the session: removes its traces, cached score and override from memory,
+// its traces from the dataset files and its undelivered events from the notification spools.
+// Events already delivered to webhooks are out of reach.
+// Returns 204 on success, 404 if nothing of the session is stored and 500 if the erasure failed;
+// a failed erasure may be repeated.
+func (aa *AdminApiRouter) deleteSessionHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+	found := aa.tracesRepo.Delete(token)
+
+	var datasetTraces, events int
+	var errs []error
+	if aa.datasetRepo != nil {
+		var err error
+		datasetTraces, err = aa.datasetRepo.Erase(token)
+		errs = append(errs, err)
+	}
+	if aa.notifier != nil {
+		var err error
+		events, err = aa.notifier.Erase(token)
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		slog.Error("Unable to erase session", "token", token, "error", err, "client", r.RemoteAddr)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "erasure failed: " + err.Error()})
+		return
+	}
+
+	if !found && datasetTraces == 0 && events == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
+		return
+	}
+
+	slog.Info("Session erased", "token", token, "datasetTraces", datasetTraces, "events", events, "client", r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setOverrideHandler pins the verdict of the session.
+// Expects a JSON body {"verdict": "<name>"}, where the name is allow, deny or a configured band.
+// Returns 204 on success, 400 if the verdict is malformed or unknown and 404 if the session has no traces.
+func (aa *AdminApiRouter) setOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Verdict string `json:"verdict"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	token := r.PathValue("token")
+	err := aa.compositeScorer.SetOverride(token, request.Verdict)
+	if errors.Is(err, scorer.ErrUnknownVerdict) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, scorer.ErrSessionNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
+		return
+	}
+
+	slog.Info("Session verdict overridden", "token", token, "verdict", request.Verdict, "client", r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clearOverrideHandler removes the verdict pinned for the session.
+// Returns 204 on success and 404 if the session has no override.
+func (aa *AdminApiRouter) clearOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+	if !aa.compositeScorer.ClearOverride(token) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "override not found"})
+		return
+	}
+
+	slog.Info("Session verdict override cleared", "token", token, "client", r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reloadHandler reloads the rule files and returns their checksums.
+// If any file fails to load, the previous rules are kept and 500 is returned.
+func (aa *AdminApiRouter) reloadHandler(w http.ResponseWriter, r *http.Request) {
+	rules, err := aa.reload()
+	if err != nil {
+		slog.Error("Unable to reload rules", "error", err, "client", r.RemoteAddr)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	slog.Info("Rules reloaded", "rules", rules, "client", r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]any{"rules": rules})
+}
+
+// match reports whether the session score satisfies the score and verdict filters.
+func (f *sessionsFilter) match(result score.Result) bool {
+	value := result.Score[f.scoreKey]
+	if f.minScore != nil && value < *f.minScore {
+		return false
+	}
+	if f.maxScore != nil && value > *f.maxScore {
+		return false
+	}
+	return f.verdict == "" || f.verdict == result.Verdict
+}
+
+// parseSessionsQuery parses the filter and the page of the sessions list.
+func parseSessionsQuery(r *http.Request) (sessionsFilter, int, int, error) {
+	query := r.URL.Query()
+	filter := sessionsFilter{scoreKey: query.Get("score_key"), verdict: query.Get("verdict")}
+	if filter.scoreKey == "" {
+		filter.scoreKey = "automation"
+	}
+
+	offset, limit := 0, defaultSessionsLimit
+	var err error
+	if v := query.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return filter, 0, 0, errors.New("invalid offset")
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			return filter, 0, 0, errors.New("invalid limit")
+		}
+	}
+
+	for name, bound := range map[string]*time.Time{"active_after": &filter.activeAfter, "active_before": &filter.activeBefore} {
+		if v := query.Get(name); v != "" {
+			if *bound, err = time.Parse(time.RFC3339, v); err != nil {
+				return filter, 0, 0, errors.New("invalid " + name)
+			}
+		}
+	}
+
+	for name, bound := range map[string]**float32{"min_score": &filter.minScore, "max_score": &filter.maxScore} {
+		if v := query.Get(name); v != "" {
+			value, err := strconv.ParseFloat(v, 32)
+			if err != nil {
+				return filter, 0, 0, errors.New("invalid " + name)
+			}
+			*bound = new(float32)
+			**bound = float32(value)
+		}
+	}
+
+	return filter, offset, limit, nil
+}
+
+// NewAdminApiRouter creates a new admin API router.
+//
+// Parameters:
+//   - auth: authenticator of the operators, must not be nil
+//   - tracesRepo: storage of the sessions
+//   - compositeScorer: scorer of the sessions holding the verdict overrides
+//   - reload: reloads the rules and returns the checksums of the loaded rule files
+//   - datasetRepo: dataset the deleted sessions are erased from (can be nil)
+//   - notifier: notifier the undelivered events of deleted sessions are erased from (can be nil)
+//
+// Returns a pointer to the configured AdminApiRouter instance.
+func NewAdminApiRouter(
+	auth *Authenticator,
+	tracesRepo *trace.TracesRepository,
+	compositeScorer *scorer.CompositeScorer,
+	reload func() ([]FileChecksum, error),
+	datasetRepo dataset.DatasetRepository,
+	notifier *notification.Notifier,
+) *AdminApiRouter {
+	return &AdminApiRouter{
+		auth:            auth,
+		tracesRepo:      tracesRepo,
+		compositeScorer: compositeScorer,
+		reload:          reload,
+		datasetRepo:     datasetRepo,
+		notifier:        notifier,
+	}
+}
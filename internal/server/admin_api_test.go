@@ -0,0 +1,241 @@
+package server
+
+import (
+	"bean/internal/dataset"
+	"bean/internal/notification"
+	"bean/internal/score"
+	"bean/internal/score/scorer"
+	"bean/internal/trace"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// movesScorer scores each session by its mouseMoves value.
+type movesScorer struct{}
+
+func (movesScorer) Score(ctx context.Context, traces []trace.Trace) (score.Score, error) {
+	moves, _ := traces[len(traces)-1]["mouseMoves"].(float64)
+	return score.Score{"automation": float32(moves)}, nil
+}
+
+// newAdminApi creates the admin API with two scored sessions: "human" (score 0.1) and "bot" (score 0.9).
+func newAdminApi(t *testing.T, reload func() ([]FileChecksum, error)) (*http.ServeMux, *trace.TracesRepository) {
+	repo := trace.NewTracesRepository(5, 0)
+	repo.Append("human", trace.Trace{"mouseMoves": 0.1})
+	time.Sleep(time.Millisecond)
+	repo.Append("bot", trace.Trace{"mouseMoves": 0.9})
+	cs := scorer.NewCompositeScorer([]scorer.Member{{Name: "moves", Scorer: movesScorer{}}}, repo, 0, scorer.Aggregator{},
+		score.Verdicts{Key: "automation", Bands: []score.Band{{Name: "human", Min: 0}, {Name: "bot", Min: 0.8}}})
+	for _, id := range []string{"human", "bot"} {
+		_, err := cs.Score(context.Background(), id)
+		require.NoError(t, err)
+	}
+
+	auth, err := NewAuthenticator([]string{"secret"}, "", time.Minute)
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	NewAdminApiRouter(auth, repo, cs, reload, nil, nil).Register(mux)
+	return mux, repo
+}
+
+// call performs an authorized admin API request and decodes the JSON response, if any.
+func call(t *testing.T, mux *http.ServeMux, method, path, body string) (int, map[string]any) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	mux.ServeHTTP(rec, req)
+
+	content, _ := io.ReadAll(rec.Body)
+	var decoded map[string]any
+	if len(content) > 0 {
+		require.NoError(t, json.Unmarshal(content, &decoded))
+	}
+	return rec.Code, decoded
+}
+
+// tokens returns the tokens of the listed sessions.
+func tokens(body map[string]any) []string {
+	var tokens []string
+	for _, s := range body["sessions"].([]any) {
+		tokens = append(tokens, s.(map[string]any)["token"].(string))
+	}
+	return tokens
+}
+
+func TestAdminApi_Unauthorized(t *testing.T) {
+	mux, _ := newAdminApi(t, nil)
+
+	for _, header := range []string{"", "Bearer wrong", "secret"} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/admin/v1/sessions", nil)
+		req.Header.Set("Authorization", header)
+		mux.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, "header %q", header)
+	}
+}
+
+func TestAdminApi_Sessions(t *testing.T) {
+	mux, repo := newAdminApi(t, nil)
+
+	code, body := call(t, mux, "GET", "/admin/v1/sessions", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(2), body["total"])
+	assert.Equal(t, []string{"bot", "human"}, tokens(body), "newest sessions first")
+
+	_, body = call(t, mux, "GET", "/admin/v1/sessions?offset=1&limit=1", "")
+	assert.Equal(t, []string{"human"}, tokens(body))
+
+	_, body = call(t, mux, "GET", "/admin/v1/sessions?min_score=0.5", "")
+	assert.Equal(t, []string{"bot"}, tokens(body))
+	_, body = call(t, mux, "GET", "/admin/v1/sessions?verdict=human", "")
+	assert.Equal(t, []string{"human"}, tokens(body))
+
+	// Filters use the last computed score and never run the scorers
+	repo.Append("bot", trace.Trace{"mouseMoves": 0.2})
+	repo.Append("new", trace.Trace{"mouseMoves": 0.9})
+	_, body = call(t, mux, "GET", "/admin/v1/sessions?min_score=0.5", "")
+	assert.Equal(t, []string{"bot"}, tokens(body))
+
+	_, body = call(t, mux, "GET", "/admin/v1/sessions?active_after=2100-01-01T00:00:00Z", "")
+	assert.Equal(t, float64(0), body["total"])
+
+	code, _ = call(t, mux, "GET", "/admin/v1/sessions?limit=x", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestAdminApi_SessionAndDelete(t *testing.T) {
+	mux, repo := newAdminApi(t, nil)
+
+	code, body := call(t, mux, "GET", "/admin/v1/sessions/bot", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []any{map[string]any{"mouseMoves": 0.9}}, body["traces"])
+
+	code, _ = call(t, mux, "DELETE", "/admin/v1/sessions/bot", "")
+	assert.Equal(t, http.StatusNoContent, code)
+	_, found := repo.Get("bot")
+	assert.False(t, found)
+
+	code, _ = call(t, mux, "GET", "/admin/v1/sessions/bot", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = call(t, mux, "DELETE", "/admin/v1/sessions/bot", "")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestAdminApi_DeleteErasesStoredData(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "dataset.log")
+	datasetRepo := dataset.NewJsonDatasetRepository(file, 1, 3)
+	defer datasetRepo.Close()
+	datasetRepo.Append("bot", trace.Trace{"mouseMoves": 0.9})
+	datasetRepo.Append("human", trace.Trace{"mouseMoves": 0.1})
+	datasetRepo.Append("expired", trace.Trace{"mouseMoves": 0.5})
+
+	// A compressed backup rotated by lumberjack
+	backup := filepath.Join(dir, "dataset-2025-01-01T00-00-00.000.log.gz")
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte(`{"token":"bot","trace":{}}` + "\n" + `{"token":"human","trace":{}}` + "\n"))
+	require.NoError(t, zw.Close())
+	require.NoError(t, os.WriteFile(backup, compressed.Bytes(), 0o600))
+
+	// A webhook without queue spools every event
+	webhook, err := notification.NewWebhook("test", "http://127.0.0.1:0", "", notification.Condition{}, time.Second, 0).
+		WithSpool(filepath.Join(dir, "spool.jsonl"), time.Minute)
+	require.NoError(t, err)
+	webhook.Enqueue(notification.Event{Token: "bot", Verdict: "bot"})
+	webhook.Enqueue(notification.Event{Token: "human", Verdict: "human"})
+	notifier := notification.NewNotifier(nil, []notification.Target{webhook})
+
+	repo := trace.NewTracesRepository(5, 0)
+	repo.Append("bot", trace.Trace{"mouseMoves": 0.9})
+	cs := scorer.NewCompositeScorer([]scorer.Member{{Name: "moves", Scorer: movesScorer{}}}, repo, 0, scorer.Aggregator{}, score.Verdicts{})
+	auth, err := NewAuthenticator([]string{"secret"}, "", time.Minute)
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	NewAdminApiRouter(auth, repo, cs, nil, datasetRepo, notifier).Register(mux)
+
+	code, _ := call(t, mux, "DELETE", "/admin/v1/sessions/bot", "")
+	assert.Equal(t, http.StatusNoContent, code)
+	_, found := repo.Get("bot")
+	assert.False(t, found)
+
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), `"bot"`)
+	assert.Contains(t, string(content), `"human"`)
+
+	content, err = os.ReadFile(backup)
+	require.NoError(t, err)
+	zr, err := gzip.NewReader(bytes.NewReader(content))
+	require.NoError(t, err)
+	content, err = io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, `{"token":"human","trace":{}}`+"\n", string(content))
+
+	content, err = os.ReadFile(filepath.Join(dir, "spool.jsonl"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), `"bot"`)
+	assert.Contains(t, string(content), `"human"`)
+
+	// Sessions no longer in memory are erased from the dataset too
+	code, _ = call(t, mux, "DELETE", "/admin/v1/sessions/expired", "")
+	assert.Equal(t, http.StatusNoContent, code)
+	code, _ = call(t, mux, "DELETE", "/admin/v1/sessions/expired", "")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// The dataset is reopened after the erasure
+	datasetRepo.Append("next", trace.Trace{})
+	content, err = os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `"next"`)
+}
+
+func TestAdminApi_Override(t *testing.T) {
+	mux, _ := newAdminApi(t, nil)
+
+	code, _ := call(t, mux, "PUT", "/admin/v1/sessions/bot/override", `{"verdict":"maybe"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = call(t, mux, "PUT", "/admin/v1/sessions/nobody/override", `{"verdict":"allow"}`)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = call(t, mux, "PUT", "/admin/v1/sessions/bot/override", `{"verdict":"allow"}`)
+	assert.Equal(t, http.StatusNoContent, code)
+	_, body := call(t, mux, "GET", "/admin/v1/sessions?verdict=allow", "")
+	assert.Equal(t, []string{"bot"}, tokens(body))
+	assert.Equal(t, "allow", body["sessions"].([]any)[0].(map[string]any)["override"])
+
+	code, _ = call(t, mux, "DELETE", "/admin/v1/sessions/bot/override", "")
+	assert.Equal(t, http.StatusNoContent, code)
+	code, _ = call(t, mux, "DELETE", "/admin/v1/sessions/bot/override", "")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestAdminApi_Reload(t *testing.T) {
+	var reloadErr error
+	mux, _ := newAdminApi(t, func() ([]FileChecksum, error) {
+		return []FileChecksum{{File: "rules.yaml", Sha256: "00ff"}}, reloadErr
+	})
+
+	code, body := call(t, mux, "POST", "/admin/v1/rules/reload", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []any{map[string]any{"file": "rules.yaml", "sha256": "00ff"}}, body["rules"])
+
+	reloadErr = errors.New("invalid rule")
+	code, body = call(t, mux, "POST", "/admin/v1/rules/reload", "")
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Equal(t, "invalid rule", body["error"])
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"bean/internal/metrics"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Authenticator authorizes requests to the protected routes by API keys.
+// A key is passed in the X-Api-Key header or as a bearer token in the Authorization header.
+// Keys are taken from the configuration and from a keys file with one key per line;
+// empty lines and lines starting with # are ignored. The keys file is checked for changes
+// periodically, so keys can be rotated without a restart.
+// If client certificates are accepted, requests with a verified TLS client certificate
+// are authorized without a key.
+//
+// Authenticator is thread-safe.
+type Authenticator struct {
+	static     []string                            // keys from the configuration
+	file       string                              // path to the keys file, empty if not used
+	interval   time.Duration                       // interval between checks of the keys file
+	modTime    time.Time                           // modification time of the loaded keys file
+	keys       atomic.Pointer[[][sha256.Size]byte] // SHA-256 hashes of the valid keys
+	clientCert bool                                // authorize requests with a verified client certificate
+	ticker     *time.Ticker                        // ticker of the keys file checks
+}
+
+// Wrap returns the handler allowing only authorized requests.
+// Other requests are rejected with 401 and counted in the auth failures metric.
+// If the authenticator is nil, the handler is returned unchanged.
+func (a *Authenticator) Wrap(handler http.HandlerFunc) http.HandlerFunc {
+	if a == nil {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !a.authorized(r) {
+			slog.Warn("Unauthorized request", "path", r.URL.Path, "client", r.RemoteAddr)
+			metrics.AuthFailures.Inc()
+			w.Header().Set("WWW-Authenticate", `Bearer realm="bean"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// authorized reports whether the request has a valid key or a verified client certificate.
+// The key is compared with all valid keys in constant time.
+func (a *Authenticator) authorized(r *http.Request) bool {
+	if a.clientCert && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return true
+	}
+
+	key := r.Header.Get("X-Api-Key")
+	if key == "" {
+		var found bool
+		if key, found = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); !found || key == "" {
+			return false
+		}
+	}
+
+	hash := sha256.Sum256([]byte(key))
+	found := 0
+	for _, valid := range *a.keys.Load() {
+		found |= subtle.ConstantTimeCompare(hash[:], valid[:])
+	}
+	return found == 1
+}
+
+// reload loads the keys file if it has changed since the last load.
+// Returns an error if the file can't be read; the loaded keys are kept in that case.
+func (a *Authenticator) reload() error {
+	keys := make([][sha256.Size]byte, 0, len(a.static))
+	for _, key := range a.static {
+		keys = append(keys, sha256.Sum256([]byte(key)))
+	}
+
+	if a.file != "" {
+		info, err := os.Stat(a.file)
+		if err != nil {
+			return err
+		}
+		if info.ModTime().Equal(a.modTime) && a.keys.Load() != nil {
+			return nil
+		}
+
+		content, err := os.ReadFile(a.file)
+		if err != nil {
+			return err
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			keys = append(keys, sha256.Sum256([]byte(line)))
+		}
+		a.modTime = info.ModTime()
+		slog.Info("API keys loaded", "file", a.file, "keys", len(keys))
+	}
+
+	a.keys.Store(&keys)
+	return nil
+}
+
+// WithClientCertificates authorizes requests with a verified TLS client certificate without a key.
+// Returns the authenticator itself for chaining.
+func (a *Authenticator) WithClientCertificates() *Authenticator {
+	a.clientCert = true
+	return a
+}
+
+// Serve periodically checks the keys file for changes and reloads the keys.
+// If the file can't be read, the error is logged and the loaded keys are kept.
+// The method blocks execution and should be called in a separate goroutine.
+// Does nothing if the keys file is not used. Use the Stop method to stop.
+func (a *Authenticator) Serve() {
+	if a.file == "" {
+		return
+	}
+
+	a.ticker = time.NewTicker(a.interval)
+	for range a.ticker.C {
+		if err := a.reload(); err != nil {
+			slog.Error("Unable to reload API keys", "file", a.file, "error", err)
+		}
+	}
+}
+
+// Stop stops checking the keys file.
+// The method is safe to call even if Serve has not been started yet.
+func (a *Authenticator) Stop() {
+	if a.ticker != nil {
+		a.ticker.Stop()
+	}
+}
+
+// NewAuthenticator creates a new instance of Authenticator.
+//
+// Parameters:
+//   - keys: valid keys from the configuration
+//   - file: path to the keys file (can be empty)
+//   - interval: interval between checks of the keys file
+//
+// Returns a pointer to the Authenticator or an error if the keys file can't be read.
+// To reload the keys file on changes, call Serve in a separate goroutine.
+func NewAuthenticator(keys []string, file string, interval time.Duration) (*Authenticator, error) {
+	a := &Authenticator{static: keys, file: file, interval: interval}
+	if err := a.reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
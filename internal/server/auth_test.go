@@ -0,0 +1,84 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// authorize performs a request through the authenticator and returns the status code.
+func authorize(a *Authenticator, header, value string) int {
+	handler := a.Wrap(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/v1/scores/abc", nil)
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	handler(rec, req)
+	return rec.Code
+}
+
+func TestAuthenticator_Keys(t *testing.T) {
+	a, err := NewAuthenticator([]string{"key-1", "key-2"}, "", time.Minute)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, authorize(a, "X-Api-Key", "key-1"))
+	assert.Equal(t, http.StatusOK, authorize(a, "Authorization", "Bearer key-2"))
+	assert.Equal(t, http.StatusUnauthorized, authorize(a, "X-Api-Key", "key-3"))
+	assert.Equal(t, http.StatusUnauthorized, authorize(a, "Authorization", "key-1"))
+	assert.Equal(t, http.StatusUnauthorized, authorize(a, "", ""))
+
+	var public *Authenticator
+	assert.Equal(t, http.StatusOK, authorize(public, "", ""), "nil authenticator should not protect the route")
+}
+
+func TestAuthenticator_KeysFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, os.WriteFile(file, []byte("# rotated monthly\nold-key\n\n  new-key  \n"), 0o600))
+
+	a, err := NewAuthenticator([]string{"static"}, file, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, authorize(a, "X-Api-Key", "old-key"))
+	assert.Equal(t, http.StatusOK, authorize(a, "X-Api-Key", "new-key"))
+	assert.Equal(t, http.StatusUnauthorized, authorize(a, "X-Api-Key", "# rotated monthly"))
+
+	// Rotation: the old key is removed from the file
+	require.NoError(t, os.WriteFile(file, []byte("new-key\n"), 0o600))
+	require.NoError(t, os.Chtimes(file, time.Now(), time.Now().Add(time.Second)))
+	require.NoError(t, a.reload())
+	assert.Equal(t, http.StatusUnauthorized, authorize(a, "X-Api-Key", "old-key"))
+	assert.Equal(t, http.StatusOK, authorize(a, "X-Api-Key", "new-key"))
+	assert.Equal(t, http.StatusOK, authorize(a, "X-Api-Key", "static"))
+
+	// A missing file keeps the loaded keys
+	require.NoError(t, os.Remove(file))
+	assert.Error(t, a.reload())
+	assert.Equal(t, http.StatusOK, authorize(a, "X-Api-Key", "new-key"))
+
+	_, err = NewAuthenticator(nil, file, time.Minute)
+	assert.Error(t, err)
+}
+
+func TestAuthenticator_ClientCertificates(t *testing.T) {
+	a, err := NewAuthenticator([]string{"key"}, "", time.Minute)
+	require.NoError(t, err)
+
+	verified := httptest.NewRequest("GET", "/api/v1/scores/abc", nil)
+	verified.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+	assert.False(t, a.authorized(verified), "client certificates are not accepted by default")
+
+	a.WithClientCertificates()
+	assert.True(t, a.authorized(verified))
+
+	unverified := httptest.NewRequest("GET", "/api/v1/scores/abc", nil)
+	unverified.TLS = &tls.ConnectionState{}
+	assert.False(t, a.authorized(unverified))
+}
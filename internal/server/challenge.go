@@ -0,0 +1,170 @@
+package server
+
+import (
+	"bean/internal/challenge"
+	"bean/internal/metrics"
+	"bean/internal/score"
+	"bean/internal/trace"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// maxSolutionBytes limits the size of a challenge solution request body.
+const maxSolutionBytes = 1024
+
+// ChallengeOptions configures the proof-of-work challenges of suspicious sessions.
+type ChallengeOptions struct {
+	// Challenges — issued challenges and solutions. Can be nil — in this case, challenges are disabled.
+	Challenges *challenge.Challenges
+	// Verdict — verdict of the sessions that are challenged.
+	Verdict string
+	// Key — score key scaling the difficulty.
+	Key string
+	// Adjustment — score adjustment of the sessions that solved a challenge.
+	Adjustment score.Score
+}
+
+// solutionRequest is the body of a challenge solution.
+type solutionRequest struct {
+	Nonce string `json:"nonce"`
+}
+
+// challengeHandler issues a proof-of-work challenge to the session.
+// The token is extracted from the URL path: /api/v1/challenge/{token}; it must be the session
+// of the request (see ownSession), so the endpoint can't be used to probe other sessions.
+// The challenge is required if the verdict of the last computed score of the session is
+// the challenged one, otherwise it is optional with the minimum difficulty; the scorers are not run.
+// Returns the challenge as JSON:
+//
+//	{"seed": "9f86d081884c7d65…", "difficulty": 18, "expiresAt": "2026-01-01T00:05:00Z"}
+//
+// Returns 204 if the session has already solved a required challenge, or an optional one and
+// the challenge is not required, 403 if the token is not the session of the request and 404
+// if the session is not found.
+func (ar *ApiV1Router) challengeHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+	if !ar.ownSession(r, token) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if _, exists := ar.tracesRepo.Version(token); !exists {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	result, found := ar.compositeScorer.Last(token)
+	required := found && result.Verdict == ar.challenges.Verdict
+	issued, ok := ar.challenges.Challenges.Issue(token, result.Score[ar.challenges.Key], required)
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	body, err := json.Marshal(issued)
+	if err != nil {
+		slog.Warn("Unable to marshal challenge", "error", err, "client", r.RemoteAddr)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	metrics.Challenges.Inc("issued")
+	slog.Debug("Challenge issued", "id", token, "difficulty", issued.Difficulty, "required", required)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(body)
+}
+
+// solutionHandler verifies the solution of the pending challenge of the session.
+// Expects a JSON body with the nonce: {"nonce": "48213"}.
+// A solved required challenge applies the score adjustment to the session and notifies its
+// score streams and the notifier; a solved optional challenge changes nothing.
+// Returns 204 on success, 403 if the token is not the session of the request, 404 if the session
+// has no pending challenge, 410 if the challenge has expired, 422 if the nonce does not solve
+// the challenge or the body is invalid.
+func (ar *ApiV1Router) solutionHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+	if !ar.ownSession(r, token) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSolutionBytes))
+	var solution solutionRequest
+	if err == nil {
+		err = json.Unmarshal(body, &solution)
+	}
+	if err != nil {
+		slog.Warn("Unable to read challenge solution", "error", err, "client", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+
+	solved, err := ar.challenges.Challenges.Verify(token, solution.Nonce)
+	switch {
+	case errors.Is(err, challenge.ErrNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case errors.Is(err, challenge.ErrExpired):
+		metrics.Challenges.Inc("expired")
+		w.WriteHeader(http.StatusGone)
+		return
+	case err != nil:
+		metrics.Challenges.Inc("invalid")
+		slog.Debug("Invalid challenge solution", "id", token, "client", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+
+	if !solved.Required {
+		metrics.Challenges.Inc("solved_optional")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	metrics.Challenges.Inc("solved")
+	slog.Debug("Challenge solved", "id", token, "difficulty", solved.Difficulty, "time", solved.SolveTime)
+	if len(ar.challenges.Adjustment) > 0 {
+		if err := ar.compositeScorer.SetAdjustment(token, ar.challenges.Adjustment); err != nil {
+			slog.Warn("Unable to adjust score", "id", token, "error", err)
+		}
+	}
+	ar.streams.publish(token)
+	if ar.notifier != nil {
+		ar.notifier.Watch(token)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownSession reports whether the token is the session of the request: the value of the session
+// cookie and, if signed sessions are enabled, valid for the client address and User-Agent.
+func (ar *ApiV1Router) ownSession(r *http.Request, token string) bool {
+	if token == "" || ar.sessionCookie(r) != token {
+		return false
+	}
+	if ar.ingest.Sessions != nil {
+		clientIP, _ := ar.clientAddr(r)
+		if err := ar.ingest.Sessions.Verify(token, clientIP, r.UserAgent()); err != nil {
+			slog.Debug("Invalid session token", "error", err, "client", r.RemoteAddr, "token", token)
+			return false
+		}
+	}
+	return true
+}
+
+// setChallengeSignals sets the challenge solution of the session on the trace.
+//
+// Fields:
+//   - challengeSolved: the session has solved a challenge
+//   - challengeSolveMs: time between issuing the challenge and receiving the solution, in milliseconds
+//   - challengeDifficulty: difficulty of the solved challenge, in leading zero bits
+func (ar *ApiV1Router) setChallengeSignals(t trace.Trace, token string) {
+	var solution challenge.Solution
+	var solved bool
+	if ar.challenges.Challenges != nil {
+		solution, solved = ar.challenges.Challenges.Solution(token)
+	}
+	t["challengeSolved"] = solved
+	t["challengeSolveMs"] = solution.SolveTime.Milliseconds()
+	t["challengeDifficulty"] = int64(solution.Difficulty)
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"bean/internal/challenge"
+	"bean/internal/score"
+	"bean/internal/score/scorer"
+	"bean/internal/session"
+	"bean/internal/trace"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApiV1Router_Challenge(t *testing.T) {
+	repo := trace.NewTracesRepository(5, 0)
+	repo.Append("human", trace.Trace{"mouseMoves": 0.1})
+	repo.Append("suspect", trace.Trace{"mouseMoves": 0.5})
+	repo.Append("unscored", trace.Trace{"mouseMoves": 0.5})
+	cs := scorer.NewCompositeScorer([]scorer.Member{{Name: "moves", Scorer: movesScorer{}}}, repo, 0, scorer.Aggregator{},
+		score.Verdicts{Key: "automation", Bands: []score.Band{{Name: "human", Min: 0}, {Name: "challenge", Min: 0.4}, {Name: "bot", Min: 0.8}}})
+	mux := NewApiV1Router("", "token", repo, cs, nil, nil, StreamOptions{Heartbeat: time.Minute}, 1024, IngestOptions{},
+		ChallengeOptions{
+			Challenges: challenge.NewChallenges(4, 8, time.Minute),
+			Verdict:    "challenge",
+			Key:        "automation",
+			Adjustment: score.Score{"automation": -0.3},
+		}, nil).Mux()
+	for _, id := range []string{"human", "suspect"} {
+		_, err := cs.Score(context.Background(), id)
+		require.NoError(t, err)
+	}
+
+	request := func(method, path, body, cookie string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "token", Value: cookie})
+		}
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	issue := func(id string) challenge.Challenge {
+		rec := request("GET", "/api/v1/challenge/"+id, "", id)
+		require.Equal(t, http.StatusOK, rec.Code, id)
+		var issued challenge.Challenge
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &issued))
+		return issued
+	}
+	solve := func(issued challenge.Challenge) (string, string) {
+		var nonce, invalid string
+		for i := 0; nonce == "" || invalid == ""; i++ {
+			if challenge.LeadingZeroBits(issued.Seed, strconv.Itoa(i)) >= issued.Difficulty {
+				nonce = strconv.Itoa(i)
+			} else {
+				invalid = strconv.Itoa(i)
+			}
+		}
+		return nonce, invalid
+	}
+
+	// Other sessions can't be probed
+	assert.Equal(t, http.StatusForbidden, request("GET", "/api/v1/challenge/suspect", "", "").Code)
+	assert.Equal(t, http.StatusForbidden, request("GET", "/api/v1/challenge/suspect", "", "human").Code)
+	assert.Equal(t, http.StatusForbidden, request("POST", "/api/v1/challenge/suspect", `{"nonce": "1"}`, "human").Code)
+	assert.Equal(t, http.StatusNotFound, request("GET", "/api/v1/challenge/unknown", "", "unknown").Code)
+
+	// Sessions that are not challenged get optional challenges of the minimum difficulty
+	optional := issue("human")
+	assert.Equal(t, 4, optional.Difficulty)
+	assert.Equal(t, 4, issue("unscored").Difficulty, "session without a computed score is not challenged")
+	nonce, _ := solve(optional)
+	assert.Equal(t, http.StatusNoContent, request("POST", "/api/v1/challenge/human", `{"nonce": "`+nonce+`"}`, "human").Code)
+	result, found := cs.Last("human")
+	require.True(t, found)
+	assert.False(t, result.Adjusted, "optional challenge should not adjust the score")
+	assert.Equal(t, http.StatusNoContent, request("GET", "/api/v1/challenge/human", "", "human").Code, "optional challenge is solved once")
+
+	issued := issue("suspect")
+	assert.Equal(t, 7, issued.Difficulty, "difficulty should be scaled by the score range")
+	nonce, invalid := solve(issued)
+	assert.Equal(t, http.StatusUnprocessableEntity, request("POST", "/api/v1/challenge/suspect", `{"nonce": "`+invalid+`"}`, "suspect").Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, request("POST", "/api/v1/challenge/suspect", `not json`, "suspect").Code)
+	assert.Equal(t, http.StatusNoContent, request("POST", "/api/v1/challenge/suspect", `{"nonce": "`+nonce+`"}`, "suspect").Code)
+
+	result, err := cs.Score(context.Background(), "suspect")
+	require.NoError(t, err)
+	assert.InDelta(t, 0.2, result.Score["automation"], 0.0001, "solved challenge should lower the score")
+	assert.Equal(t, "human", result.Verdict)
+	assert.Equal(t, http.StatusNoContent, request("GET", "/api/v1/challenge/suspect", "", "suspect").Code, "solved session is not challenged again")
+
+	// The solution is reported to the rules with the next trace
+	req := httptest.NewRequest("POST", "/api/v1/traces", strings.NewReader(`{"mouseMoves": 0.5, "challengeSolved": false}`))
+	req.AddCookie(&http.Cookie{Name: "token", Value: "suspect"})
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	last, _ := repo.Last("suspect")
+	assert.Equal(t, true, last["challengeSolved"])
+	assert.Equal(t, int64(7), last["challengeDifficulty"])
+	assert.GreaterOrEqual(t, last["challengeSolveMs"], int64(0))
+}
+
+func TestApiV1Router_ChallengeSignedSession(t *testing.T) {
+	repo := trace.NewTracesRepository(5, 0)
+	sessions := session.NewIssuer([]string{strings.Repeat("s", 32)}, time.Hour, 0)
+	token, _ := sessions.Issue(netip.MustParseAddr("192.0.2.1"), "agent")
+	repo.Append(token, trace.Trace{"mouseMoves": 0.1})
+	cs := scorer.NewCompositeScorer([]scorer.Member{{Name: "moves", Scorer: movesScorer{}}}, repo, 0, scorer.Aggregator{}, score.Verdicts{})
+	mux := NewApiV1Router("", "token", repo, cs, nil, nil, StreamOptions{Heartbeat: time.Minute}, 1024,
+		IngestOptions{Sessions: sessions},
+		ChallengeOptions{Challenges: challenge.NewChallenges(4, 8, time.Minute), Verdict: "challenge", Key: "automation"}, nil).Mux()
+
+	request := func(userAgent string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/v1/challenge/"+token, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("User-Agent", userAgent)
+		req.AddCookie(&http.Cookie{Name: "token", Value: token})
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusOK, request("agent"))
+	assert.Equal(t, http.StatusForbidden, request("other-agent"), "token should be bound to the User-Agent")
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ClientIPResolver determines the client address of requests passed through trusted reverse proxies.
+// If the peer of the connection is a trusted proxy, the hops listed in the Forwarded header,
+// or in X-Forwarded-For if Forwarded is missing, are checked from right to left, and the first
+// address that is not a trusted proxy is the client. Headers sent by untrusted peers are ignored,
+// so clients can't spoof their address. Peers connected through a Unix domain socket are
+// local proxies and are always trusted.
+//
+// A nil ClientIPResolver trusts no proxies.
+type ClientIPResolver struct {
+	trusted []netip.Prefix // networks of the trusted proxies
+}
+
+// Resolve returns the client address of the request.
+// Returns an invalid address if the client address is unknown, e.g. for a Unix socket
+// peer without forwarding headers.
+func (c *ClientIPResolver) Resolve(r *http.Request) netip.Addr {
+	peer := parseHop(r.RemoteAddr)
+	if c == nil || (peer.IsValid() && !c.isTrusted(peer)) {
+		return peer
+	}
+
+	hops := forwardedHops(r.Header)
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := parseHop(hops[i])
+		if !hop.IsValid() {
+			break
+		}
+		client = hop
+		if !c.isTrusted(hop) {
+			break
+		}
+	}
+	return client
+}
+
+// isTrusted reports whether the address belongs to a trusted proxy.
+func (c *ClientIPResolver) isTrusted(addr netip.Addr) bool {
+	if c == nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range c.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedHops returns the addresses of the forwarding hops from the client to the last proxy.
+// The Forwarded header (RFC 7239) takes precedence over X-Forwarded-For.
+func forwardedHops(header http.Header) []string {
+	var hops []string
+	if forwarded := header.Values("Forwarded"); len(forwarded) > 0 {
+		for _, value := range forwarded {
+			for _, element := range strings.Split(value, ",") {
+				for _, pair := range strings.Split(element, ";") {
+					name, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+					if found && strings.EqualFold(name, "for") {
+						hops = append(hops, value)
+					}
+				}
+			}
+		}
+		return hops
+	}
+
+	for _, value := range header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	return hops
+}
+
+// parseHop parses an address of a forwarding hop or a peer.
+// Accepts addresses with or without a port, IPv6 addresses in brackets and quoted values.
+// Returns an invalid address if the value is not an IP address (e.g., "unknown" or an obfuscated identifier).
+func parseHop(value string) netip.Addr {
+	value = strings.Trim(strings.TrimSpace(value), `"`)
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(value, "[]"))
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+// NewClientIPResolver creates a new instance of ClientIPResolver.
+//
+// Parameters:
+//   - trusted: networks of the trusted reverse proxies
+//
+// Returns a pointer to the ClientIPResolver.
+func NewClientIPResolver(trusted []netip.Prefix) *ClientIPResolver {
+	return &ClientIPResolver{trusted: trusted}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIPResolver_Resolve(t *testing.T) {
+	resolver := NewClientIPResolver([]netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	})
+
+	tests := []struct {
+		name     string
+		peer     string
+		headers  map[string]string
+		expected string
+	}{
+		{"direct client", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"untrusted peer spoofing", "203.0.113.7:5000", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.7"},
+		{"trusted proxy", "10.0.0.1:5000", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"proxy chain", "10.0.0.1:5000", map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.1, 10.0.0.2"}, "198.51.100.1"},
+		{"all hops trusted", "10.0.0.1:5000", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"forwarded", "10.0.0.1:5000", map[string]string{
+			"Forwarded":       `for="[2001:db8::1]:4711";proto=https, for=198.51.100.2`,
+			"X-Forwarded-For": "1.1.1.1",
+		}, "198.51.100.2"},
+		{"obfuscated hop", "10.0.0.1:5000", map[string]string{"Forwarded": "for=_hidden, for=10.0.0.2"}, "10.0.0.2"},
+		{"ipv6 peer", "[2001:db8::5]:5000", map[string]string{"X-Forwarded-For": "198.51.100.3"}, "198.51.100.3"},
+		{"unix socket peer", "@", map[string]string{"X-Forwarded-For": "198.51.100.4"}, "198.51.100.4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.peer
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			assert.Equal(t, tt.expected, resolver.Resolve(r).String())
+		})
+	}
+}
+
+func TestClientIPResolver_Nil(t *testing.T) {
+	var resolver *ClientIPResolver
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:5000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	assert.Equal(t, "10.0.0.1", resolver.Resolve(r).String())
+}
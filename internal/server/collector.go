@@ -0,0 +1,179 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// collectorMaxAge is the default cache lifetime of the content-hashed collector script.
+const collectorMaxAge = 365 * 24 * time.Hour
+
+// collectorVersions is the number of the recent versions of the collector script served.
+const collectorVersions = 8
+
+// collectorScript is a version of the collector script.
+type collectorScript struct {
+	script    []byte // content of the script
+	file      string // content-hashed file name (e.g., "collector.3f1c2e9a5b7d4c10.js")
+	integrity string // Subresource Integrity hash (e.g., "sha384-…")
+	etag      string // entity tag
+}
+
+// newCollectorScript creates a version of the collector script with its hashes.
+func newCollectorScript(script []byte) *collectorScript {
+	sum := sha256.Sum256(script)
+	hash := hex.EncodeToString(sum[:8])
+	integrity := sha512.Sum384(script)
+	return &collectorScript{
+		script:    script,
+		file:      "collector." + hash + ".js",
+		integrity: "sha384-" + base64.StdEncoding.EncodeToString(integrity[:]),
+		etag:      `"` + hash + `"`,
+	}
+}
+
+// Collector serves the collector script at a content-hashed URL, so browsers and CDNs can
+// cache it forever and a new version of the script gets a new URL.
+// The Subresource Integrity hash of the script lets pages verify the loaded script.
+// The script can be replaced at runtime (e.g., by a rotated obfuscated variant); the recent
+// versions are still served, so pages loaded before the replacement keep working.
+//
+// Collector is thread-safe.
+type Collector struct {
+	// versions — recent versions of the script, the current one first.
+	versions []*collectorScript
+
+	// maxAge — cache lifetime of the script.
+	maxAge time.Duration
+
+	// mu — mutex to protect access to versions.
+	mu sync.RWMutex
+}
+
+// current returns the current version of the script.
+func (c *Collector) current() *collectorScript {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.versions[0]
+}
+
+// Update replaces the current script. Recent versions are served until they are
+// pushed out by newer ones.
+func (c *Collector) Update(script []byte) {
+	version := newCollectorScript(script)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.versions = append([]*collectorScript{version}, c.versions[:min(len(c.versions), collectorVersions-1)]...)
+}
+
+// Path returns the content-hashed URL path of the current script.
+func (c *Collector) Path() string {
+	return "/collector/" + c.current().file
+}
+
+// Integrity returns the Subresource Integrity hash of the current script.
+func (c *Collector) Integrity() string {
+	return c.current().integrity
+}
+
+// Snippet returns the <script> tag loading the current script with its integrity hash.
+// The base URL (e.g., "https://bean.example.com") is prepended to the script path,
+// an empty base keeps the path relative.
+func (c *Collector) Snippet(base string) string {
+	version := c.current()
+	src := strings.TrimSuffix(base, "/") + "/collector/" + version.file
+	return fmt.Sprintf(`<script src="%s" integrity="%s" crossorigin="anonymous"></script>`,
+		html.EscapeString(src), version.integrity)
+}
+
+// Register adds the collector routes to the mux:
+// - GET /collector/collector.{hash}.js — the script, cached as immutable
+// - GET /collector.js — redirect to the current content-hashed URL
+// - GET /api/v1/collector/snippet — the <script> tag with the integrity hash
+func (c *Collector) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /collector/{file}", c.scriptHandler)
+	mux.HandleFunc("GET /collector.js", c.redirectHandler)
+	mux.HandleFunc("GET /api/v1/collector/snippet", c.snippetHandler)
+}
+
+// scriptHandler serves a recent version of the script with long cache headers.
+// The script may be loaded by pages of other origins, so CORS is allowed for
+// the integrity check of cross-origin scripts.
+// Returns 404 for unknown versions.
+func (c *Collector) scriptHandler(w http.ResponseWriter, r *http.Request) {
+	file := r.PathValue("file")
+	c.mu.RLock()
+	index := slices.IndexFunc(c.versions, func(v *collectorScript) bool { return v.file == file })
+	var version *collectorScript
+	if index >= 0 {
+		version = c.versions[index]
+	}
+	c.mu.RUnlock()
+	if version == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", int(c.maxAge.Seconds())))
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("ETag", version.etag)
+	http.ServeContent(w, r, "collector.js", time.Time{}, bytes.NewReader(version.script))
+}
+
+// redirectHandler redirects to the content-hashed URL of the current script.
+// The redirect is not cached, so pages pick up a new version after a deploy.
+func (c *Collector) redirectHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache")
+	http.Redirect(w, r, c.Path(), http.StatusFound)
+}
+
+// snippetHandler returns the <script> tag of the current script as text/html.
+// The optional query parameter base is an absolute http(s) URL of Bean prepended to the script path
+// for pages of other origins: /api/v1/collector/snippet?base=https://bean.example.com.
+// Returns 400 if the base is not an absolute http(s) URL.
+func (c *Collector) snippetHandler(w http.ResponseWriter, r *http.Request) {
+	base := r.URL.Query().Get("base")
+	if base != "" {
+		u, err := url.Parse(base)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write([]byte(c.Snippet(base) + "\n"))
+}
+
+// NewCollector creates a new collector script asset.
+//
+// Parameters:
+//   - script: content of the collector script (the embedded one or a customized file)
+//
+// Returns a pointer to the Collector with the content hash and the integrity hash of the script,
+// cached for a year.
+func NewCollector(script []byte) *Collector {
+	return &Collector{versions: []*collectorScript{newCollectorScript(script)}, maxAge: collectorMaxAge}
+}
+
+// WithMaxAge limits the cache lifetime of the script, e.g. to the time the field mapping
+// of an obfuscated variant is decoded. Should be called before the routes are registered.
+func (c *Collector) WithMaxAge(maxAge time.Duration) *Collector {
+	c.maxAge = maxAge
+	return c
+}
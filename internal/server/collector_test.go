@@ -0,0 +1,117 @@
+package server
+
+import (
+	"bean/public"
+	"crypto/sha512"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector_Script(t *testing.T) {
+	collector := NewCollector(public.Collector)
+	mux := http.NewServeMux()
+	collector.Register(mux)
+
+	assert.Regexp(t, `^/collector/collector\.[0-9a-f]{16}\.js$`, collector.Path())
+	sum := sha512.Sum384(public.Collector)
+	assert.Equal(t, "sha384-"+base64.StdEncoding.EncodeToString(sum[:]), collector.Integrity())
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", collector.Path(), nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, public.Collector, rec.Body.Bytes())
+	assert.Contains(t, rec.Header().Get("Content-Type"), "javascript")
+	assert.Equal(t, "public, max-age=31536000, immutable", rec.Header().Get("Cache-Control"))
+	assert.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", collector.Path(), nil)
+	req.Header.Set("If-None-Match", collector.current().etag)
+	mux.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/collector/collector.0000000000000000.js", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code, "outdated version should not be served")
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/collector.js", nil))
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, collector.Path(), rec.Header().Get("Location"))
+	assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+
+	custom := NewCollector([]byte("console.log('custom');"))
+	assert.NotEqual(t, collector.Path(), custom.Path(), "changed script should get a new URL")
+
+	mux = http.NewServeMux()
+	custom.WithMaxAge(7 * time.Hour).Register(mux)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", custom.Path(), nil))
+	assert.Equal(t, "public, max-age=25200, immutable", rec.Header().Get("Cache-Control"))
+}
+
+func TestCollector_Update(t *testing.T) {
+	collector := NewCollector([]byte("console.log(0);"))
+	mux := http.NewServeMux()
+	collector.Register(mux)
+
+	paths := []string{collector.Path()}
+	for i := 1; i <= collectorVersions; i++ {
+		collector.Update([]byte("console.log(" + strconv.Itoa(i) + ");"))
+		paths = append(paths, collector.Path())
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/collector.js", nil))
+	assert.Equal(t, paths[collectorVersions], rec.Header().Get("Location"), "redirect should point to the current version")
+
+	for i, path := range paths {
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if i == 0 {
+			assert.Equal(t, http.StatusNotFound, rec.Code, "oldest version should be pushed out")
+			continue
+		}
+		assert.Equal(t, http.StatusOK, rec.Code, "recent version %d should be served", i)
+		assert.Equal(t, "console.log("+strconv.Itoa(i)+");", rec.Body.String())
+	}
+}
+
+func TestCollector_Snippet(t *testing.T) {
+	collector := NewCollector([]byte("console.log('collector');"))
+	mux := http.NewServeMux()
+	collector.Register(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/collector/snippet", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t,
+		`<script src="`+collector.Path()+`" integrity="`+collector.Integrity()+`" crossorigin="anonymous"></script>`+"\n",
+		rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/collector/snippet?base=https://bean.example.com/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	src := regexp.MustCompile(`src="([^"]+)"`).FindStringSubmatch(rec.Body.String())
+	require.Len(t, src, 2)
+	assert.Equal(t, "https://bean.example.com"+collector.Path(), src[1])
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", `/api/v1/collector/snippet?base=https://"><b>`, nil))
+	assert.NotContains(t, rec.Body.String(), `"><b>`, "base should be escaped")
+
+	for _, base := range []string{"javascript:alert(1)", "bean.example.com", "/static"} {
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/collector/snippet?base="+base, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, base)
+	}
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"bean/internal/trace"
+	"math"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// receivedAtLayout is the format of the receivedAt field, matching the client timestamp format.
+const receivedAtLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// enrichTrace sets the server-observed fields of the request on the trace.
+// The fields are always set, so rules can reference them; client-sent values are overwritten.
+//
+// Fields:
+//   - clientIp: client address behind trusted proxies (empty if unknown)
+//   - acceptLanguage: Accept-Language header
+//   - headerUserAgent: User-Agent header
+//   - secChUa, secChUaPlatform: Sec-CH-UA and Sec-CH-UA-Platform client hints (quotes of the platform removed)
+//   - secChUaMobile: Sec-CH-UA-Mobile client hint is "?1"
+//   - headerOrder: comma-separated lowercase header names in the received order (empty if unknown)
+//   - headerOrderKnown: the header order was recorded; false for TLS and HTTP/2 connections
+//   - httpProtocol: protocol of the request (e.g., "HTTP/1.1", "HTTP/2.0")
+//   - receivedAt: server time the trace was received, in UTC
+func enrichTrace(t trace.Trace, r *http.Request, clientIP netip.Addr, receivedAt time.Time) {
+	t["clientIp"] = ""
+	if clientIP.IsValid() {
+		t["clientIp"] = clientIP.String()
+	}
+	t["acceptLanguage"] = r.Header.Get("Accept-Language")
+	t["headerUserAgent"] = r.Header.Get("User-Agent")
+	t["secChUa"] = r.Header.Get("Sec-CH-UA")
+	t["secChUaMobile"] = r.Header.Get("Sec-CH-UA-Mobile") == "?1"
+	t["secChUaPlatform"] = strings.Trim(r.Header.Get("Sec-CH-UA-Platform"), `"`)
+	names, known := headerOrder(r)
+	t["headerOrder"] = strings.Join(names, ",")
+	t["headerOrderKnown"] = known
+	t["httpProtocol"] = r.Proto
+	t["receivedAt"] = receivedAt.UTC().Format(receivedAtLayout)
+}
+
+// reportSequence returns the sequence number and the nonce of the report sent by the collector.
+// Returns zero for a missing or invalid sequence number and an empty nonce for a missing nonce.
+func reportSequence(t trace.Trace) (int64, string) {
+	seq, _ := t["seq"].(float64)
+	nonce, _ := t["nonce"].(string)
+	if seq != math.Trunc(seq) || seq < 1 || seq > math.MaxInt64 {
+		return 0, nonce
+	}
+	return int64(seq), nonce
+}
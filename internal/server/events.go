@@ -0,0 +1,73 @@
+package server
+
+import (
+	"bean/internal/keystroke"
+	"bean/internal/trace"
+	"bean/internal/trajectory"
+)
+
+// Trace fields with the raw data sent by the collector.
+const (
+	eventsField     = "events"     // raw events
+	keystrokesField = "keystrokes" // keystroke timings
+)
+
+// EventsOptions configures the raw events and keystrokes sent by the collector.
+type EventsOptions struct {
+	// MaxEvents — maximum number of events per stream and of keystrokes decoded, the rest is dropped.
+	// Zero means no limit.
+	MaxEvents int
+	// Keep — keep the raw events and keystrokes in the stored trace and the dataset.
+	// Otherwise, only the features are stored.
+	Keep bool
+}
+
+// setEventFeatures decodes the raw events of the trace and sets their features (see trajectory.Fields).
+// The features are always set; without raw events they are zero.
+// rawEventsInvalid is set if the raw events are malformed or their features are not finite;
+// the features are zero then.
+func setEventFeatures(t trace.Trace, options EventsOptions) {
+	var features trajectory.Features
+	invalid := false
+	if raw, found := t[eventsField]; found {
+		events, err := trajectory.Decode(raw, options.MaxEvents)
+		if err == nil {
+			features = trajectory.Extract(events)
+		}
+		invalid = err != nil || !trajectory.Fields.Finite(features)
+		if invalid {
+			features = trajectory.Features{}
+		}
+		if !options.Keep {
+			delete(t, eventsField)
+		}
+	}
+
+	trajectory.Fields.Set(features, t)
+	t["rawEventsInvalid"] = invalid
+}
+
+// setKeystrokeFeatures decodes the keystrokes of the trace and sets their features (see keystroke.Fields).
+// The features are always set; without keystrokes they are zero.
+// keystrokesInvalid is set if the keystrokes are malformed or their features are not finite;
+// the features are zero then.
+func setKeystrokeFeatures(t trace.Trace, options EventsOptions) {
+	var features keystroke.Features
+	invalid := false
+	if raw, found := t[keystrokesField]; found {
+		keystrokes, err := keystroke.Decode(raw, options.MaxEvents)
+		if err == nil {
+			features = keystroke.Extract(keystrokes)
+		}
+		invalid = err != nil || !keystroke.Fields.Finite(features)
+		if invalid {
+			features = keystroke.Features{}
+		}
+		if !options.Keep {
+			delete(t, keystrokesField)
+		}
+	}
+
+	keystroke.Fields.Set(features, t)
+	t["keystrokesInvalid"] = invalid
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxHeadBytes limits the size of a recorded request head. Longer heads stop the recording.
+const maxHeadBytes = 64 * 1024
+
+// maxPendingHeads limits the number of recorded heads waiting to be taken by their requests.
+const maxPendingHeads = 16
+
+// headerOrderKey is the context key of the recorded header order of a request.
+type headerOrderKey struct{}
+
+// headerOrderConn records the order of the header names of HTTP/1.x requests read from
+// the connection. net/http does not preserve the order, but it is a strong client fingerprint:
+// browsers send headers in a fixed order, while HTTP libraries use their own.
+//
+// The request heads are parsed from the read bytes; bodies are skipped by Content-Length.
+// Recording stops for the rest of the connection if a body has no length (chunked encoding)
+// or a head can't be parsed.
+type headerOrderConn struct {
+	net.Conn
+	mu        sync.Mutex // mutex to protect the recording state
+	head      []byte     // received bytes of the current request head
+	remaining int64      // body bytes of the current request left to skip
+	stopped   bool       // recording has stopped
+	pending   [][]string // header names of the received heads not taken yet
+}
+
+// Read reads from the connection and records the request heads.
+func (c *headerOrderConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.record(p[:n])
+	return n, err
+}
+
+// record processes the bytes read from the connection.
+func (c *headerOrderConn) record(data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(data) > 0 && !c.stopped {
+		if c.remaining > 0 {
+			skip := min(c.remaining, int64(len(data)))
+			data = data[skip:]
+			c.remaining -= skip
+			continue
+		}
+
+		c.head = append(c.head, data...)
+		end := bytes.Index(c.head, []byte("\r\n\r\n"))
+		if end < 0 {
+			if len(c.head) > maxHeadBytes {
+				c.stopped = true
+			}
+			return
+		}
+
+		names, length, ok := parseHead(c.head[:end])
+		data = c.head[end+4:]
+		c.head = nil
+		if !ok {
+			c.stopped = true
+			return
+		}
+		if len(c.pending) == maxPendingHeads {
+			c.pending = c.pending[1:]
+		}
+		c.pending = append(c.pending, names)
+		c.remaining = length
+	}
+}
+
+// take returns the header names of the oldest received request head.
+// Returns nil if no head has been recorded.
+func (c *headerOrderConn) take() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.pending) == 0 {
+		return nil
+	}
+	names := c.pending[0]
+	c.pending = c.pending[1:]
+	return names
+}
+
+// parseHead parses a request head without the final empty line.
+// Returns the lowercase header names in the received order and the body length.
+// Returns false if the head is malformed or the body length is unknown.
+func parseHead(head []byte) ([]string, int64, bool) {
+	lines := strings.Split(strings.TrimLeft(string(head), "\r\n"), "\r\n")
+	if len(lines) == 0 || !strings.Contains(lines[0], " HTTP/1.") {
+		return nil, 0, false
+	}
+
+	names := make([]string, 0, len(lines)-1)
+	var length int64
+	for _, line := range lines[1:] {
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, 0, false
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+		names = append(names, name)
+
+		switch name {
+		case "content-length":
+			var err error
+			if length, err = strconv.ParseInt(value, 10, 64); err != nil || length < 0 {
+				return nil, 0, false
+			}
+		case "transfer-encoding":
+			return names, 0, false
+		}
+	}
+	return names, length, true
+}
+
+// headerOrderListener wraps accepted connections with headerOrderConn.
+type headerOrderListener struct {
+	net.Listener
+}
+
+// Accept waits for the next connection and wraps it with the header order recording.
+func (l headerOrderListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &headerOrderConn{Conn: conn}, nil
+}
+
+// headerOrderConnContext stores the recording connection in the context of its requests.
+// Used as http.Server.ConnContext.
+func headerOrderConnContext(ctx context.Context, conn net.Conn) context.Context {
+	if recorder, ok := conn.(*headerOrderConn); ok {
+		return context.WithValue(ctx, headerOrderKey{}, recorder)
+	}
+	return ctx
+}
+
+// withHeaderOrder passes the recorded header order to the request context.
+// Must wrap all handlers of the server, so every request takes its own recorded head.
+func withHeaderOrder(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if recorder, ok := r.Context().Value(headerOrderKey{}).(*headerOrderConn); ok {
+			var names []string
+			if r.ProtoMajor == 1 {
+				names = recorder.take()
+			}
+			r = r.WithContext(context.WithValue(r.Context(), headerOrderKey{}, names))
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// headerOrder returns the lowercase header names of the request in the received order.
+// Returns false if the order is unknown, e.g. for HTTP/2 or TLS connections.
+func headerOrder(r *http.Request) ([]string, bool) {
+	names, _ := r.Context().Value(headerOrderKey{}).([]string)
+	return names, names != nil
+}
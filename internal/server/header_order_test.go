@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderOrderConn_Record(t *testing.T) {
+	c := &headerOrderConn{}
+	request := "POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 4\r\n\r\nbodyGET / HTTP/1.1\r\nUser-Agent: b\r\nHost: a\r\n\r\n"
+
+	// Bytes arrive in arbitrary chunks
+	for i := 0; i < len(request); i += 7 {
+		c.record([]byte(request[i:min(i+7, len(request))]))
+	}
+
+	assert.Equal(t, []string{"host", "content-length"}, c.take())
+	assert.Equal(t, []string{"user-agent", "host"}, c.take())
+	assert.Nil(t, c.take())
+}
+
+func TestHeaderOrderConn_Chunked(t *testing.T) {
+	c := &headerOrderConn{}
+	c.record([]byte("POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\n\r\n4\r\nbody\r\n0\r\n\r\n"))
+	c.record([]byte("GET / HTTP/1.1\r\nHost: a\r\n\r\n"))
+
+	assert.Nil(t, c.take(), "recording should stop on bodies without length")
+	assert.True(t, c.stopped)
+}
+
+func TestWithHeaderOrder_Known(t *testing.T) {
+	var names []string
+	var known bool
+	handler := withHeaderOrder(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		names, known = headerOrder(r)
+	}))
+	serve := func(c *headerOrderConn, protoMajor int) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.ProtoMajor = protoMajor
+		if c != nil {
+			r = r.WithContext(context.WithValue(r.Context(), headerOrderKey{}, c))
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	c := &headerOrderConn{}
+	c.record([]byte("GET / HTTP/1.1\r\nHost: a\r\n\r\n"))
+	serve(c, 1)
+	assert.True(t, known)
+	assert.Equal(t, []string{"host"}, names)
+
+	serve(c, 1)
+	assert.False(t, known, "the order is unknown once recording has no head for the request")
+
+	c.record([]byte("GET / HTTP/1.1\r\nHost: a\r\n\r\n"))
+	serve(c, 2)
+	assert.False(t, known, "the order is unknown for HTTP/2")
+
+	serve(nil, 1)
+	assert.False(t, known, "the order is unknown without a recording connection, e.g. for TLS")
+}
+
+func TestParseHead(t *testing.T) {
+	names, length, ok := parseHead([]byte("\r\nPOST / HTTP/1.1\r\nHost: a\r\ncontent-length: 12"))
+	assert.True(t, ok)
+	assert.Equal(t, []string{"host", "content-length"}, names)
+	assert.Equal(t, int64(12), length)
+
+	_, _, ok = parseHead([]byte("PRI * HTTP/2.0\r\n"))
+	assert.False(t, ok)
+	_, _, ok = parseHead([]byte("GET / HTTP/1.1\r\nbroken"))
+	assert.False(t, ok)
+}
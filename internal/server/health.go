@@ -0,0 +1,153 @@
+package server
+
+import (
+	"bean/internal/buildinfo"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// readinessTimeout limits the readiness checks of a single probe.
+const readinessTimeout = 2 * time.Second
+
+// Service states reported by the readiness probe.
+const (
+	stateStarting int32 = iota
+	stateReady
+	stateStopping
+)
+
+// ReadinessCheck checks a dependency required to serve requests.
+type ReadinessCheck struct {
+	// Name — dependency name reported by the readiness probe.
+	Name string
+	// Check — returns an error if the dependency is not available.
+	Check func(ctx context.Context) error
+	// Optional — the check is only reported: its failure does not make the service unavailable.
+	Optional bool
+}
+
+// FileChecksum is the SHA-256 checksum of a loaded file.
+type FileChecksum struct {
+	// File — file path.
+	File string `json:"file"`
+	// Sha256 — hex-encoded checksum of the file content.
+	Sha256 string `json:"sha256"`
+}
+
+// VersionInfo is the build and configuration of the running service reported by /version.
+type VersionInfo struct {
+	buildinfo.Info
+	// Rules — checksums of the loaded rule files.
+	Rules []FileChecksum `json:"rules"`
+	// ConfigSha256 — checksum of the active configuration.
+	ConfigSha256 string `json:"config_sha256"`
+}
+
+// Health tracks the liveness and readiness of the service.
+// The service is not ready until MarkReady is called, and stops being ready
+// when the server starts shutting down. While the service is ready, each probe
+// runs the readiness checks of its dependencies.
+//
+// Health is thread-safe.
+type Health struct {
+	state   atomic.Int32                // current state: starting, ready or stopping
+	checks  []ReadinessCheck            // readiness checks of dependencies
+	version atomic.Pointer[VersionInfo] // reported version information
+}
+
+// MarkReady marks the service as initialized and ready to serve requests.
+func (h *Health) MarkReady() {
+	h.state.CompareAndSwap(stateStarting, stateReady)
+}
+
+// MarkStopping marks the service as shutting down. The service is not ready anymore.
+func (h *Health) MarkStopping() {
+	h.state.Store(stateStopping)
+}
+
+// SetVersion replaces the reported version information, e.g. after rules are reloaded.
+func (h *Health) SetVersion(info VersionInfo) {
+	h.version.Store(&info)
+}
+
+// healthzHandler reports that the process is alive. Always returns 200.
+func (h *Health) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyzHandler reports whether the service is ready to serve requests.
+// Returns 503 while the service is starting or stopping, or if any required readiness check fails;
+// the status of every check, including the optional ones, is listed in the response.
+func (h *Health) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	switch h.state.Load() {
+	case stateStarting:
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "starting"})
+		return
+	case stateStopping:
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "stopping"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := make(map[string]string, len(h.checks))
+	code, status := http.StatusOK, "ready"
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := "ok"
+			err := check.Check(ctx)
+			if err != nil {
+				result = err.Error()
+			}
+			mu.Lock()
+			checks[check.Name] = result
+			if err != nil && !check.Optional {
+				code, status = http.StatusServiceUnavailable, "unavailable"
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	writeJSON(w, code, map[string]any{"status": status, "checks": checks})
+}
+
+// versionHandler reports the build version and the loaded configuration.
+func (h *Health) versionHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.version.Load())
+}
+
+// writeJSON writes the value as a JSON response with the status code.
+func writeJSON(w http.ResponseWriter, code int, value any) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(body)
+}
+
+// NewHealth creates a new instance of Health in the starting state.
+//
+// Parameters:
+//   - version: reported version information
+//   - checks: readiness checks of dependencies
+//
+// Returns a pointer to the initialized Health.
+func NewHealth(version VersionInfo, checks []ReadinessCheck) *Health {
+	h := &Health{checks: checks}
+	h.SetVersion(version)
+	return h
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"bean/internal/buildinfo"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// probe performs a request to the admin routes and decodes the JSON response.
+func probe(t *testing.T, mux *http.ServeMux, path string) (int, map[string]any) {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return rec.Code, body
+}
+
+func TestHealth_Readiness(t *testing.T) {
+	var mlErr error
+	health := NewHealth(VersionInfo{}, []ReadinessCheck{
+		{Name: "ml-0", Check: func(ctx context.Context) error { return mlErr }},
+	})
+	mux := http.NewServeMux()
+	NewAdminRouter(http.NotFoundHandler(), health).Register(mux)
+
+	code, _ := probe(t, mux, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+
+	code, body := probe(t, mux, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "starting", body["status"])
+
+	health.MarkReady()
+	code, body = probe(t, mux, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]any{"ml-0": "ok"}, body["checks"])
+
+	mlErr = errors.New("connection refused")
+	code, body = probe(t, mux, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, map[string]any{"ml-0": "connection refused"}, body["checks"])
+
+	mlErr = nil
+	health.MarkStopping()
+	code, body = probe(t, mux, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "stopping", body["status"])
+
+	// The service does not become ready again after the shutdown has started
+	health.MarkReady()
+	code, _ = probe(t, mux, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+
+	code, _ = probe(t, mux, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestHealth_OptionalCheck(t *testing.T) {
+	health := NewHealth(VersionInfo{}, []ReadinessCheck{
+		{Name: "ml-0", Check: func(ctx context.Context) error { return nil }},
+		{Name: "ml-1", Check: func(ctx context.Context) error { return errors.New("connection refused") }, Optional: true},
+	})
+	mux := http.NewServeMux()
+	NewAdminRouter(http.NotFoundHandler(), health).Register(mux)
+	health.MarkReady()
+
+	code, body := probe(t, mux, "/readyz")
+	assert.Equal(t, http.StatusOK, code, "optional check should not gate the readiness")
+	assert.Equal(t, "ready", body["status"])
+	assert.Equal(t, map[string]any{"ml-0": "ok", "ml-1": "connection refused"}, body["checks"])
+}
+
+func TestHealth_Version(t *testing.T) {
+	health := NewHealth(VersionInfo{
+		Info:         buildinfo.Info{Version: "1.2.3", Commit: "abc", GoVersion: "go1.25"},
+		Rules:        []FileChecksum{{File: "rules.yaml", Sha256: "00ff"}},
+		ConfigSha256: "ff00",
+	}, nil)
+	mux := http.NewServeMux()
+	NewAdminRouter(http.NotFoundHandler(), health).Register(mux)
+
+	code, body := probe(t, mux, "/version")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]any{
+		"version":       "1.2.3",
+		"commit":        "abc",
+		"go":            "go1.25",
+		"rules":         []any{map[string]any{"file": "rules.yaml", "sha256": "00ff"}},
+		"config_sha256": "ff00",
+	}, body)
+}
+
+func TestAdminRouter_PublicListener(t *testing.T) {
+	status := func(mux *http.ServeMux, path, key string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", path, nil)
+		if key != "" {
+			req.Header.Set("X-Api-Key", key)
+		}
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	metrics := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	// Without auth the metrics and version are not exposed on the API listener
+	mux := http.NewServeMux()
+	NewAdminRouter(metrics, NewHealth(VersionInfo{}, nil)).register(mux, true)
+	assert.Equal(t, http.StatusNotFound, status(mux, "/metrics", ""))
+	assert.Equal(t, http.StatusNotFound, status(mux, "/version", ""))
+	assert.Equal(t, http.StatusOK, status(mux, "/healthz", ""))
+
+	auth, err := NewAuthenticator([]string{"key"}, "", time.Minute)
+	require.NoError(t, err)
+	mux = http.NewServeMux()
+	NewAdminRouter(metrics, NewHealth(VersionInfo{}, nil)).WithAuth(auth).register(mux, true)
+	assert.Equal(t, http.StatusUnauthorized, status(mux, "/metrics", ""))
+	assert.Equal(t, http.StatusOK, status(mux, "/metrics", "key"))
+	assert.Equal(t, http.StatusUnauthorized, status(mux, "/version", ""))
+	assert.Equal(t, http.StatusOK, status(mux, "/version", "key"))
+}
@@ -2,14 +2,49 @@ package server
 
 import (
 	"bean/internal/dataset"
+	"bean/internal/metrics"
+	"bean/internal/notification"
+	"bean/internal/obfuscation"
+	"bean/internal/ratelimit"
+	"bean/internal/score"
 	"bean/internal/score/scorer"
+	"bean/internal/session"
 	"bean/internal/trace"
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
 )
 
+// statusClientClosedRequest is the non-standard status of requests canceled by the client.
+const statusClientClosedRequest = 499
+
+// IngestOptions configures the trace ingestion.
+type IngestOptions struct {
+	// ClientIP — resolver of the client address behind trusted proxies.
+	// Can be nil — in this case, the peer address is used.
+	ClientIP *ClientIPResolver
+	// IPLimiter — rate limiter of traces by client IP. Can be nil — in this case, traces are not limited.
+	IPLimiter *ratelimit.Limiter
+	// SessionLimiter — rate limiter of traces by session token. Can be nil — in this case, traces are not limited.
+	SessionLimiter *ratelimit.Limiter
+	// Sessions — issuer of signed session tokens. Can be nil — in this case, any session token is accepted.
+	Sessions *session.Issuer
+	// RejectReplays — reject reports with a duplicate nonce instead of only flagging them.
+	// Out-of-order sequence numbers are only flagged.
+	RejectReplays bool
+	// Obfuscator — decoder of the payload keys of the obfuscated collector. Can be nil — in this case,
+	// traces use the canonical field names.
+	Obfuscator *obfuscation.Obfuscator
+	// Events — decoding of the raw events and keystrokes sent by the collector.
+	Events EventsOptions
+}
+
 // ApiV1Router manages routes for API version 1.
 // Handles receiving behavioral traces, calculating scores, and serving static files.
 // All endpoints follow a REST-like structure.
@@ -30,17 +65,54 @@ type ApiV1Router struct {
 	// datasetRepo — repository for saving traces to a dataset (e.g., to a file).
 	// Can be nil — in this case, no dataset logging occurs.
 	datasetRepo dataset.DatasetRepository
+
+	// notifier — notifier of verdict changes of sessions with ingested traces.
+	// Can be nil — in this case, no notifications are sent.
+	notifier *notification.Notifier
+
+	// streams — score stream subscriptions notified about ingested traces.
+	streams *streamHub
+
+	// stream — score stream settings.
+	stream StreamOptions
+
+	// maxTraceBytes — maximum size of a trace request body.
+	maxTraceBytes int64
+
+	// ingest — client address resolution and rate limits of the trace ingestion.
+	ingest IngestOptions
+
+	// challenges — proof-of-work challenges of suspicious sessions.
+	challenges ChallengeOptions
+
+	// auth — authenticator of the score routes.
+	// Can be nil — in this case, the score routes are public.
+	auth *Authenticator
 }
 
 // Mux returns a configured *http.ServeMux with registered handlers.
 // Registers the following routes:
 // - POST /api/v1/traces — receives a new trace
-// - GET /api/v1/scores/{token} — retrieves a score by token
+// - GET /api/v1/scores/{token} — retrieves a score by token (authorized if auth is set)
+// - GET /api/v1/scores/{token}/result — retrieves a score with its verdict and scorer errors (authorized if auth is set)
+// - GET /api/v1/scores/{token}/stream — streams score updates as server-sent events (authorized if auth is set)
+// - POST /api/v1/sessions — issues a signed session token (if signed sessions are enabled)
+// - GET /api/v1/challenge/{token} — issues a proof-of-work challenge (if challenges are enabled)
+// - POST /api/v1/challenge/{token} — verifies a challenge solution (if challenges are enabled)
 // - GET /static/... — serves static files (if enabled)
 func (ar *ApiV1Router) Mux() *http.ServeMux {
 	mux := http.NewServeMux()
 	mux.HandleFunc("POST /api/v1/traces", ar.traceHandler)
-	mux.HandleFunc("GET /api/v1/scores/{token}", ar.scoreHandler)
+	if ar.ingest.Sessions != nil {
+		mux.HandleFunc("POST /api/v1/sessions", ar.sessionHandler)
+	}
+	if ar.challenges.Challenges != nil {
+		mux.HandleFunc("GET /api/v1/challenge/{token}", ar.challengeHandler)
+		mux.HandleFunc("POST /api/v1/challenge/{token}", ar.solutionHandler)
+	}
+	mux.HandleFunc("GET /api/v1/scores/{token}", ar.auth.Wrap(ar.scoreHandler))
+	mux.HandleFunc("GET /api/v1/scores/{token}/result", ar.auth.Wrap(ar.resultHandler))
+	mux.HandleFunc("GET /api/v1/scores/{token}/stream", ar.auth.Wrap(ar.scoreStreamHandler))
 
 	if len(ar.static) != 0 {
 		fs := http.FileServer(http.Dir(ar.static))
@@ -56,14 +128,56 @@ func (ar *ApiV1Router) Mux() *http.ServeMux {
 // On error, returns an appropriate HTTP status.
 //
 // Behavior:
-// - Reads the request body and parses it as trace.Trace.
 // - Looks for a cookie with the name ar.tokenCookie to identify the session.
+// - Checks the rate limits of the client IP and of the session.
+// - Verifies the session token signature if signed sessions are enabled.
+// - Reads the request body limited to maxTraceBytes and parses it as trace.Trace.
+// - Decodes the payload keys of the obfuscated collector, if enabled.
+// - Checks the sequence number and the nonce of the report for replays.
+// - Sets the server-observed fields of the request (client IP, headers, protocol, received time)
+// and the challenge solution of the session.
+// - Compares the client timestamp with the receive time and with the previous trace of the session.
+// - Decodes the raw events of the trace and sets their kinematic features.
+// - Decodes the keystrokes of the trace and sets their keystroke dynamics features.
+// - Sets the sessionRateLimited and ipRateLimited fields to the number of traces rejected by the session
+// and the client IP rate limits since the previous accepted trace of the session and the client IP.
 // - Saves the trace to tracesRepo and, if present, to datasetRepo.
-// - Returns 200 on success, 422 on validation/parsing errors.
+// - Notifies score streams of the session and, if present, the notifier.
+// - Returns 200 on success, 403 if the session token is invalid or expired, 409 if the report is replayed
+// and replays are rejected, 413 if the body is too large, 422 on validation/parsing errors,
+// 429 if a rate limit is exceeded.
 func (ar *ApiV1Router) traceHandler(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
+	token := ar.sessionCookie(r)
+	receivedAt := time.Now()
+	clientIP, client := ar.clientAddr(r)
+	if !ar.ingest.IPLimiter.Allow(client) {
+		ar.rateLimited(w, client, token)
+		return
+	}
+	if ar.ingest.Sessions != nil && token != "" {
+		if err := ar.ingest.Sessions.Verify(token, clientIP, r.UserAgent()); err != nil {
+			slog.Debug("Invalid session token", "error", err, "client", client, "token", token)
+			metrics.TracesRejected.Inc("invalid_session")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}
+	if token != "" && !ar.ingest.SessionLimiter.Allow(token) {
+		ar.rateLimited(w, client, token)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, ar.maxTraceBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		slog.Warn("Trace request body too large", "limit", tooLarge.Limit, "client", r.RemoteAddr)
+		metrics.TracesRejected.Inc("too_large")
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
 	if err != nil {
 		slog.Warn("Empty trace request body", "error", err, "client", r.RemoteAddr)
+		metrics.TracesRejected.Inc("read_error")
 		w.WriteHeader(http.StatusUnprocessableEntity)
 		return
 	}
@@ -74,65 +188,198 @@ func (ar *ApiV1Router) traceHandler(w http.ResponseWriter, r *http.Request) {
 	err = json.Unmarshal(body, &trace)
 	if err != nil {
 		slog.Warn("Unable to unmarshal trace request body", "error", err, "client", r.RemoteAddr)
+		metrics.TracesRejected.Inc("invalid_json")
 		w.WriteHeader(http.StatusUnprocessableEntity)
 		return
 	}
 
-	var token string
-	cookies := r.Cookies()
-	for _, cookie := range cookies {
-		if cookie.Name == ar.tokenCookie {
-			token = cookie.Value
-			break
-		}
-	}
-
 	if len(token) == 0 {
 		slog.Warn("Empty trace token", "client", r.RemoteAddr)
+		metrics.TracesRejected.Inc("missing_token")
 		w.WriteHeader(http.StatusUnprocessableEntity)
 		return
 	}
 
+	mapping := ""
+	if ar.ingest.Obfuscator != nil {
+		mapping = ar.ingest.Obfuscator.Decode(trace)
+	}
+
+	seq, nonce := reportSequence(trace)
+	replay := ar.tracesRepo.CheckReplay(token, seq, nonce)
+	if replay.Duplicate && ar.ingest.RejectReplays {
+		slog.Debug("Replayed trace", "client", client, "token", token, "seq", seq, "nonce", nonce)
+		metrics.TracesRejected.Inc("replayed")
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	enrichTrace(trace, r, clientIP, receivedAt)
+	trace["collectorMapping"] = mapping
+	trace["sequenceGaps"] = replay.SequenceGaps
+	trace["duplicateReports"] = replay.DuplicateReports
+	trace["outOfOrderReports"] = replay.OutOfOrderReports
+	trace["unsequenced"] = replay.Unsequenced
+	ar.setChallengeSignals(trace, token)
+	previous, _ := ar.tracesRepo.Last(token)
+	setTimingSignals(trace, previous, receivedAt)
+	setEventFeatures(trace, ar.ingest.Events)
+	setKeystrokeFeatures(trace, ar.ingest.Events)
+	trace["sessionRateLimited"] = int64(ar.ingest.SessionLimiter.Rejected(token))
+	trace["ipRateLimited"] = int64(ar.ingest.IPLimiter.Rejected(client))
+
 	slog.Debug("Trace request", "client", r.RemoteAddr, "token", token, "trace", trace)
 
 	ar.tracesRepo.Append(token, trace)
+	metrics.TracesIngested.Inc()
 	if ar.datasetRepo != nil {
 		ar.datasetRepo.Append(token, trace)
 	}
+	ar.streams.publish(token)
+	if ar.notifier != nil {
+		ar.notifier.Watch(token)
+	}
 	w.WriteHeader(http.StatusOK)
 }
 
+// sessionHandler issues a signed session token bound to the client address and User-Agent.
+// Sets the token cookie and returns the token with its expiration time as JSON:
+//
+//	{"token": "v1.1767225600.…", "expiresAt": "2026-01-01T00:00:00Z"}
+//
+// Session creation shares the rate limit of the client IP with the trace ingestion.
+// Returns 429 if the limit is exceeded.
+func (ar *ApiV1Router) sessionHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP, client := ar.clientAddr(r)
+	if !ar.ingest.IPLimiter.Allow(client) {
+		slog.Debug("Session rate limit exceeded", "client", client)
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+
+	token, expires := ar.ingest.Sessions.Issue(clientIP, r.UserAgent())
+	body, err := json.Marshal(sessionResponse{Token: token, ExpiresAt: expires.UTC()})
+	if err != nil {
+		slog.Error("Unable to marshal session", "error", err, "client", client)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     ar.tokenCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	metrics.SessionsIssued.Inc()
+	slog.Debug("Session issued", "client", client, "token", token)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(body)
+}
+
+// sessionResponse is the response of the session creation.
+type sessionResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// sessionCookie returns the session token of the request cookie, or an empty string.
+func (ar *ApiV1Router) sessionCookie(r *http.Request) string {
+	cookie, err := r.Cookie(ar.tokenCookie)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// clientAddr returns the client address of the request and its string form used as
+// the rate limit key. The peer address is used as the key if the client address is unknown.
+func (ar *ApiV1Router) clientAddr(r *http.Request) (netip.Addr, string) {
+	clientIP := ar.ingest.ClientIP.Resolve(r)
+	if clientIP.IsValid() {
+		return clientIP, clientIP.String()
+	}
+	return clientIP, r.RemoteAddr
+}
+
+// rateLimited rejects a trace exceeding a rate limit with 429.
+func (ar *ApiV1Router) rateLimited(w http.ResponseWriter, client string, token string) {
+	slog.Debug("Trace rate limit exceeded", "client", client, "token", token)
+	metrics.TracesRejected.Inc("rate_limited")
+	w.Header().Set("Retry-After", "1")
+	w.WriteHeader(http.StatusTooManyRequests)
+}
+
 // scoreHandler handles requests to retrieve a score by token.
 // The token is extracted from the URL path: /api/v1/scores/{token}.
-// If the score is found, it is returned in JSON format.
-// If not, it returns 404.
+// If the score is found, its keys are returned as a flat JSON object. If not, it returns 404.
+// If a scorer with the fail policy fails, it returns 502.
+func (ar *ApiV1Router) scoreHandler(w http.ResponseWriter, r *http.Request) {
+	ar.serveScore(w, r, func(result score.Result) any { return result.Score })
+}
+
+// resultHandler handles requests to retrieve a score result by token.
+// The token is extracted from the URL path: /api/v1/scores/{token}/result.
+// Works like scoreHandler, but returns the score together with the verdict
+// and the handled scorer errors and timeouts.
+func (ar *ApiV1Router) resultHandler(w http.ResponseWriter, r *http.Request) {
+	ar.serveScore(w, r, func(result score.Result) any { return result })
+}
+
+// serveScore calculates the score of the session and writes the response built by the view.
 //
 // Behavior:
 // - Extracts the token from the request path.
-// - Calculates the score using compositeScorer.
-// - Serializes the result to JSON and sends it to the client.
+// - Calculates the score using compositeScorer within the request context.
+// - Serializes the view of the result to JSON and sends it to the client.
 // - Returns an appropriate HTTP status on error.
-func (ar *ApiV1Router) scoreHandler(w http.ResponseWriter, r *http.Request) {
+// - Observes the request latency by status code; canceled requests are reported as 499.
+func (ar *ApiV1Router) serveScore(w http.ResponseWriter, r *http.Request, view func(score.Result) any) {
+	start := time.Now()
+	code := http.StatusOK
+	defer func() {
+		metrics.ScoreRequestDuration.Observe(time.Since(start).Seconds(), strconv.Itoa(code))
+	}()
+
 	token := r.PathValue("token")
 	if len(token) == 0 {
 		slog.Warn("Empty trace token", "client", r.RemoteAddr)
-		w.WriteHeader(http.StatusUnprocessableEntity)
+		code = http.StatusUnprocessableEntity
+		w.WriteHeader(code)
 		return
 	}
 
-	score, err := ar.compositeScorer.Score(token)
-	if err != nil {
+	result, err := ar.compositeScorer.Score(r.Context(), token)
+	if errors.Is(err, context.Canceled) {
+		slog.Debug("Score request canceled", "id", token, "client", r.RemoteAddr)
+		code = statusClientClosedRequest
+		return
+	}
+	if errors.Is(err, scorer.ErrSessionNotFound) {
 		slog.Warn("Score not found", "id", token, "error", err, "client", r.RemoteAddr)
-		w.WriteHeader(http.StatusNotFound)
+		code = http.StatusNotFound
+		w.WriteHeader(code)
+		return
+	}
+	if err != nil {
+		slog.Error("Score calculation failed", "id", token, "error", err, "client", r.RemoteAddr)
+		code = http.StatusBadGateway
+		w.WriteHeader(code)
 		return
 	}
 
-	slog.Debug("Score request", "client", r.RemoteAddr, "token", token, "score", score)
+	slog.Debug("Score request", "client", r.RemoteAddr, "token", token, "score", result)
 
-	body, err := json.Marshal(score)
+	body, err := json.Marshal(view(result))
 	if err != nil {
 		slog.Warn("Unable to marshal score", "error", err, "client", r.RemoteAddr)
-		w.WriteHeader(http.StatusBadRequest)
+		code = http.StatusBadRequest
+		w.WriteHeader(code)
 		return
 	}
 
@@ -147,20 +394,45 @@ func (ar *ApiV1Router) scoreHandler(w http.ResponseWriter, r *http.Request) {
 //   - tracesRepo: trace storage
 //   - compositeScorer: service for score calculation
 //   - datasetRepo: repository for dataset collection (can be nil)
+//   - notifier: notifier of verdict changes (can be nil)
+//   - stream: score stream settings
+//   - maxTraceBytes: maximum size of a trace request body
+//   - ingest: client address resolution and rate limits of the trace ingestion
+//   - challenges: proof-of-work challenges of suspicious sessions
+//   - auth: authenticator of the score routes (can be nil)
 //
 // Returns a pointer to the configured ApiV1Router instance.
+// Score streams of sessions evicted from tracesRepo are closed.
 func NewApiV1Router(
 	static string,
 	tokenCookie string,
 	tracesRepo *trace.TracesRepository,
 	compositeScorer *scorer.CompositeScorer,
 	datasetRepo dataset.DatasetRepository,
+	notifier *notification.Notifier,
+	stream StreamOptions,
+	maxTraceBytes int64,
+	ingest IngestOptions,
+	challenges ChallengeOptions,
+	auth *Authenticator,
 ) *ApiV1Router {
-	return &ApiV1Router{
+	router := &ApiV1Router{
 		tracesRepo:      tracesRepo,
 		compositeScorer: compositeScorer,
 		static:          static,
 		tokenCookie:     tokenCookie,
 		datasetRepo:     datasetRepo,
+		notifier:        notifier,
+		streams:         newStreamHub(),
+		stream:          stream,
+		maxTraceBytes:   maxTraceBytes,
+		ingest:          ingest,
+		challenges:      challenges,
+		auth:            auth,
+	}
+	tracesRepo.OnEvict(router.streams.expire)
+	if challenges.Challenges != nil {
+		tracesRepo.OnEvict(challenges.Challenges.Forget)
 	}
+	return router
 }
@@ -2,65 +2,193 @@ package server
 
 import (
 	"bean/internal/dataset"
+	"bean/internal/notification"
 	"bean/internal/score/scorer"
 	"bean/internal/trace"
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 )
 
+// unixPrefix marks an address of a Unix domain socket (e.g., "unix:/run/bean/bean.sock").
+const unixPrefix = "unix:"
+
+// Limits configures the timeouts and request size limits of the server.
+type Limits struct {
+	// ReadTimeout — maximum duration for reading the entire request, including the body.
+	ReadTimeout time.Duration
+	// WriteTimeout — maximum duration before timing out writes of the response.
+	// Score streams extend the deadline before every write.
+	WriteTimeout time.Duration
+	// IdleTimeout — maximum time to wait for the next request on a keep-alive connection.
+	IdleTimeout time.Duration
+	// MaxHeaderBytes — maximum size of the request headers.
+	MaxHeaderBytes int
+	// MaxTraceBytes — maximum size of a trace request body.
+	MaxTraceBytes int64
+}
+
 // Server encapsulates the HTTP server of the application, providing controlled startup and shutdown.
 // Uses a customizable router and ensures timeouts for security and stability.
 type Server struct {
 	// server — embedded HTTP server from net/http package, fully configured and ready to use.
 	server *http.Server
+
+	// health — service health marked as stopping on shutdown, nil if not tracked.
+	health *Health
 }
 
 // ListenAndServe starts the HTTP server and begins listening on the specified address.
+// An address with the "unix:" prefix is a path to a Unix domain socket;
+// a stale socket file left by a previous run is removed, any other file at the path is an error.
+// If TLS is configured, the server serves HTTPS with HTTP/2 and HTTP/1.1;
+// otherwise, the header order of the requests is recorded.
 // Blocks execution until the server is stopped or an error occurs.
 // If server is stopped via Shutdown, method returns http.ErrServerClosed.
 func (s *Server) ListenAndServe() error {
-	return s.server.ListenAndServe()
+	network, address := "tcp", s.server.Addr
+	if path, found := strings.CutPrefix(address, unixPrefix); found {
+		network, address = "unix", path
+		if err := removeStaleSocket(path); err != nil {
+			return err
+		}
+	}
+
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+
+	if s.server.TLSConfig != nil {
+		return s.server.ServeTLS(ln, "", "")
+	}
+	if s.server.ConnContext != nil {
+		ln = headerOrderListener{ln}
+	}
+	return s.server.Serve(ln)
+}
+
+// removeStaleSocket removes the socket file at the path, if any.
+// Returns an error if the path exists but is not a socket, so that a misconfigured
+// address never deletes a regular file.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode().Type() != fs.ModeSocket {
+		return fmt.Errorf("%s: not a socket", path)
+	}
+	return os.Remove(path)
 }
 
 // Shutdown gracefully stops the server with the provided context.
 // Stops listening, terminates accepting new connections, and allows active connections
 // to complete within the timeout specified in the context.
 // Should be called during graceful shutdown of the application.
+// The service health, if set, stops reporting readiness before the listener is closed.
 func (s *Server) Shutdown(ctx context.Context) error {
+	if s.health != nil {
+		s.health.MarkStopping()
+	}
 	return s.server.Shutdown(ctx)
 }
 
+// WithHealth sets the service health marked as stopping when the server shuts down.
+// Returns the server itself for chaining.
+func (s *Server) WithHealth(health *Health) *Server {
+	s.health = health
+	return s
+}
+
+// WithTLS enables HTTPS with the certificate served by certs.
+//
+// Parameters:
+// - certs: certificate of the server, reloaded when its files change.
+// - minVersion: minimum TLS version (e.g., tls.VersionTLS12).
+// - clientCa: path to the CA certificates verifying client certificates (can be empty).
+//
+// Returns the server itself for chaining, or an error if the client CA can't be loaded.
+func (s *Server) WithTLS(certs *CertReloader, minVersion uint16, clientCa string) (*Server, error) {
+	config, err := newTLSConfig(certs, minVersion, clientCa)
+	if err != nil {
+		return nil, err
+	}
+
+	s.server.TLSConfig = config
+	s.server.Protocols = new(http.Protocols)
+	s.server.Protocols.SetHTTP1(true)
+	s.server.Protocols.SetHTTP2(true)
+	return s, nil
+}
+
 // NewServer creates and configures a new server instance.
 //
 // Parameters:
 // - address: address and port to listen on (e.g., ":8080").
 // - static: path to directory with static files to be served.
+// - collector: collector script served at a content-hashed URL (can be nil if not served).
 // - tokenCookie: name of cookie used for request authentication.
 // - tracesRepo: repository for storing and retrieving behavioral traces.
 // - scoreCalculator: calculator used for computing scores based on traces.
 // - datasetRepo: repository for storing bahavioral traces
+// - notifier: notifier of verdict changes (can be nil)
+// - stream: score stream settings
+// - limits: timeouts and request size limits
+// - ingest: client address resolution and rate limits of the trace ingestion
+// - challenges: proof-of-work challenges of suspicious sessions
+// - auth: authenticator of the score routes (can be nil if the score routes are public)
+// - admin: admin routes mounted on the API server (can be nil if served by a separate admin server),
+// metrics and version are mounted only if the admin router has an authenticator
 //
 // Configures API v1 routes, including static file handling and behavioral metrics processing.
-// Sets secure timeouts for reading and writing, and limits header size.
+// Sets timeouts for reading, writing and idle connections, and limits header and trace sizes.
 //
 // Returns pointer to a ready-to-run server.
 func NewServer(
 	address string,
 	static string,
+	collector *Collector,
 	tokenCookie string,
 	tracesRepo *trace.TracesRepository,
 	compositeScorer *scorer.CompositeScorer,
 	datasetRepo dataset.DatasetRepository,
+	notifier *notification.Notifier,
+	stream StreamOptions,
+	limits Limits,
+	ingest IngestOptions,
+	challenges ChallengeOptions,
+	auth *Authenticator,
+	admin *AdminRouter,
 ) *Server {
-	router := NewApiV1Router(static, tokenCookie, tracesRepo, compositeScorer, datasetRepo)
-	s := Server{&http.Server{
+	router := NewApiV1Router(static, tokenCookie, tracesRepo, compositeScorer, datasetRepo, notifier, stream, limits.MaxTraceBytes, ingest, challenges, auth)
+	mux := router.Mux()
+	if collector != nil {
+		collector.Register(mux)
+	}
+	if admin != nil {
+		admin.register(mux, true)
+	}
+
+	s := Server{server: &http.Server{
 		Addr:           address,
-		Handler:        router.Mux(),
-		ReadTimeout:    time.Second * 3,
-		WriteTimeout:   time.Second * 3,
-		MaxHeaderBytes: 1024 * 10,
+		Handler:        withHeaderOrder(mux),
+		ConnContext:    headerOrderConnContext,
+		ReadTimeout:    limits.ReadTimeout,
+		WriteTimeout:   limits.WriteTimeout,
+		IdleTimeout:    limits.IdleTimeout,
+		MaxHeaderBytes: limits.MaxHeaderBytes,
 	}}
+	s.server.RegisterOnShutdown(router.streams.close)
 
 	return &s
 }
@@ -0,0 +1,463 @@
+package server
+
+import (
+	"bean/internal/keystroke"
+	"bean/internal/metrics"
+	"bean/internal/obfuscation"
+	"bean/internal/ratelimit"
+	"bean/internal/score"
+	"bean/internal/score/scorer"
+	"bean/internal/session"
+	"bean/internal/trace"
+	"bean/internal/trajectory"
+	"bean/public"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer creates a server without scorers and with the limits.
+func newTestServer(address string, limits Limits) (*Server, *trace.TracesRepository) {
+	return newIngestServer(address, limits, IngestOptions{})
+}
+
+// newIngestServer creates a server without scorers and with the limits and ingest options.
+func newIngestServer(address string, limits Limits, ingest IngestOptions) (*Server, *trace.TracesRepository) {
+	repo := trace.NewTracesRepository(10, 0)
+	cs := scorer.NewCompositeScorer(nil, repo, 0, scorer.Aggregator{}, score.Verdicts{})
+	s := NewServer(address, "", nil, "token", repo, cs, nil, nil, StreamOptions{Heartbeat: time.Minute}, limits, ingest, ChallengeOptions{}, nil, nil)
+	return s, repo
+}
+
+// postTrace sends a trace with the session cookie to the handler and returns the status code.
+func postTrace(handler http.Handler, body string) int {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/v1/traces", strings.NewReader(body))
+	req.AddCookie(&http.Cookie{Name: "token", Value: "user1"})
+	handler.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestServer_MaxTraceBytes(t *testing.T) {
+	s, repo := newTestServer(":0", Limits{MaxTraceBytes: 32})
+	rejected := metrics.TracesRejected.Value("too_large")
+
+	assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, `{"mouseMoves": 1}`))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, postTrace(s.server.Handler, `{"mouseMoves": 1, "clicks": 2, "keys": 3}`))
+
+	traces, _ := repo.Get("user1")
+	assert.Len(t, traces, 1, "the rejected trace should not be stored")
+	assert.Equal(t, rejected+1, metrics.TracesRejected.Value("too_large"))
+}
+
+func TestServer_UnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "bean.sock")
+	s, _ := newTestServer("unix:"+socket, Limits{MaxTraceBytes: 1024})
+
+	served := make(chan error, 1)
+	go func() { served <- s.ListenAndServe() }()
+	defer s.Shutdown(context.Background())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	require.Eventually(t, func() bool {
+		resp, err := client.Get("http://bean/api/v1/scores/unknown")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusNotFound
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, s.Shutdown(context.Background()))
+	assert.ErrorIs(t, <-served, http.ErrServerClosed)
+}
+
+func TestServer_UnixSocketNotRemoved(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "bean.sock")
+	require.NoError(t, os.WriteFile(file, []byte("data"), 0o600))
+	s, _ := newTestServer("unix:"+file, Limits{MaxTraceBytes: 1024})
+
+	assert.ErrorContains(t, s.ListenAndServe(), "not a socket")
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(content), "regular file should not be removed")
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	s, repo := newIngestServer(":0", Limits{MaxTraceBytes: 1024}, IngestOptions{
+		IPLimiter:      ratelimit.NewLimiter(0, 3),
+		SessionLimiter: ratelimit.NewLimiter(0, 2),
+	})
+
+	assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, `{"mouseMoves": 1}`))
+	assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, `{"mouseMoves": 2, "sessionRateLimited": 0}`))
+	assert.Equal(t, http.StatusTooManyRequests, postTrace(s.server.Handler, `{"mouseMoves": 3}`), "session limit")
+	assert.Equal(t, http.StatusTooManyRequests, postTrace(s.server.Handler, `{"mouseMoves": 4}`), "IP limit")
+
+	traces, _ := repo.Get("user1")
+	require.Len(t, traces, 2)
+	assert.Equal(t, int64(0), traces[0]["sessionRateLimited"])
+	assert.Equal(t, int64(0), traces[1]["sessionRateLimited"], "the client value should be replaced")
+}
+
+func TestServer_RateLimitSignal(t *testing.T) {
+	s, repo := newIngestServer(":0", Limits{MaxTraceBytes: 1024}, IngestOptions{IPLimiter: ratelimit.NewLimiter(20, 1)})
+
+	assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, `{"mouseMoves": 1}`))
+	assert.Equal(t, http.StatusTooManyRequests, postTrace(s.server.Handler, `{"mouseMoves": 2}`))
+
+	// The next accepted trace reports the rejected one
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, `{"mouseMoves": 3}`))
+
+	traces, _ := repo.Get("user1")
+	require.Len(t, traces, 2)
+	assert.Equal(t, int64(1), traces[1]["ipRateLimited"])
+	assert.Equal(t, int64(0), traces[1]["sessionRateLimited"])
+}
+
+func TestServer_RateLimitSharedIP(t *testing.T) {
+	s, repo := newIngestServer(":0", Limits{MaxTraceBytes: 1024}, IngestOptions{
+		IPLimiter:      ratelimit.NewLimiter(20, 2),
+		SessionLimiter: ratelimit.NewLimiter(20, 1),
+	})
+	// Both sessions come from the same address, e.g. behind a NAT
+	post := func(token string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/v1/traces", strings.NewReader(`{"mouseMoves": 1}`))
+		req.AddCookie(&http.Cookie{Name: "token", Value: token})
+		s.server.Handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, post("bot"))
+	assert.Equal(t, http.StatusTooManyRequests, post("bot"), "session limit")
+	assert.Equal(t, http.StatusTooManyRequests, post("bot"), "IP limit")
+
+	time.Sleep(150 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, post("human"))
+	assert.Equal(t, http.StatusOK, post("bot"))
+
+	human, _ := repo.Last("human")
+	assert.Equal(t, int64(0), human["sessionRateLimited"], "violations of another session should not be pinned on the session")
+	assert.Equal(t, int64(1), human["ipRateLimited"])
+	bot, _ := repo.Last("bot")
+	assert.Equal(t, int64(1), bot["sessionRateLimited"])
+	assert.Equal(t, int64(0), bot["ipRateLimited"])
+}
+
+func TestServer_Enrichment(t *testing.T) {
+	s, repo := newIngestServer(":0", Limits{MaxTraceBytes: 1024}, IngestOptions{
+		ClientIP: NewClientIPResolver([]netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}),
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/v1/traces", strings.NewReader(`{"language": "en-US", "clientIp": "127.0.0.1"}`))
+	req.AddCookie(&http.Cookie{Name: "token", Value: "user1"})
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	req.Header.Set("Accept-Language", "de-DE,de;q=0.9")
+	req.Header.Set("User-Agent", "test-agent")
+	req.Header.Set("Sec-CH-UA", `"Chromium";v="140"`)
+	req.Header.Set("Sec-CH-UA-Mobile", "?1")
+	req.Header.Set("Sec-CH-UA-Platform", `"Android"`)
+	s.server.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	traces, _ := repo.Get("user1")
+	require.Len(t, traces, 1)
+	tr := traces[0]
+	assert.Equal(t, "198.51.100.1", tr["clientIp"], "the client value should be replaced")
+	assert.Equal(t, "de-DE,de;q=0.9", tr["acceptLanguage"])
+	assert.Equal(t, "test-agent", tr["headerUserAgent"])
+	assert.Equal(t, `"Chromium";v="140"`, tr["secChUa"])
+	assert.Equal(t, true, tr["secChUaMobile"])
+	assert.Equal(t, "Android", tr["secChUaPlatform"])
+	assert.Equal(t, "", tr["headerOrder"], "the order is unknown without a recording connection")
+	assert.Equal(t, false, tr["headerOrderKnown"])
+	assert.Equal(t, "HTTP/1.1", tr["httpProtocol"])
+
+	receivedAt, err := time.Parse(time.RFC3339, tr["receivedAt"].(string))
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), receivedAt, time.Minute)
+}
+
+func TestServer_HeaderOrder(t *testing.T) {
+	s, repo := newTestServer("127.0.0.1:0", Limits{MaxTraceBytes: 1024})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.server.Serve(headerOrderListener{ln})
+	defer s.Shutdown(context.Background())
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// A request not ingesting a trace is followed by a trace on the same connection
+	body := `{"mouseMoves": 1}`
+	_, err = fmt.Fprintf(conn, "GET /api/v1/scores/unknown HTTP/1.1\r\nHost: bean\r\nX-First: 1\r\n\r\n"+
+		"POST /api/v1/traces HTTP/1.1\r\nHost: bean\r\nCookie: token=user1\r\nAccept: */*\r\n"+
+		"Content-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(conn)
+	for _, status := range []int{http.StatusNotFound, http.StatusOK} {
+		resp, err := http.ReadResponse(reader, nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, status, resp.StatusCode)
+	}
+
+	traces, _ := repo.Get("user1")
+	require.Len(t, traces, 1)
+	assert.Equal(t, "host,cookie,accept,content-type,content-length", traces[0]["headerOrder"])
+	assert.Equal(t, true, traces[0]["headerOrderKnown"])
+}
+
+func TestServer_TimingSignals(t *testing.T) {
+	s, repo := newTestServer(":0", Limits{MaxTraceBytes: 1024})
+	timestamp := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339Nano)
+
+	assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, `{"timestamp": "`+timestamp+`", "outOfOrder": true}`))
+	assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, `{"timestamp": "`+timestamp+`"}`))
+
+	traces, _ := repo.Get("user1")
+	require.Len(t, traces, 2)
+	assert.Equal(t, false, traces[0]["outOfOrder"], "the client value should be replaced")
+	assert.Equal(t, false, traces[0]["duplicateTimestamp"])
+	assert.Equal(t, true, traces[1]["duplicateTimestamp"], "the replayed timestamp should be detected")
+	assert.InDelta(t, -60000, traces[1]["clockSkewMs"], 1000, "the client clock should be a minute behind")
+}
+
+func TestServer_SignedSessions(t *testing.T) {
+	s, repo := newIngestServer(":0", Limits{MaxTraceBytes: 1024}, IngestOptions{
+		Sessions: session.NewIssuer([]string{strings.Repeat("s", 32)}, time.Hour, 0),
+	})
+	rejected := metrics.TracesRejected.Value("invalid_session")
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/v1/sessions", nil)
+	req.Header.Set("User-Agent", "agent")
+	s.server.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+	var issued sessionResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &issued))
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "token", cookies[0].Name)
+	assert.Equal(t, issued.Token, cookies[0].Value)
+
+	send := func(token string, userAgent string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/v1/traces", strings.NewReader(`{"mouseMoves": 1}`))
+		req.AddCookie(&http.Cookie{Name: "token", Value: token})
+		req.Header.Set("User-Agent", userAgent)
+		s.server.Handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send(issued.Token, "agent"))
+	assert.Equal(t, http.StatusForbidden, send(issued.Token, "other-agent"), "token should be bound to the User-Agent")
+	assert.Equal(t, http.StatusForbidden, send("3b241101-e2bb-4255-8caa-4d2e7cde4a3a", "agent"), "unsigned token should be rejected")
+
+	traces, _ := repo.Get(issued.Token)
+	assert.Len(t, traces, 1)
+	assert.Equal(t, rejected+2, metrics.TracesRejected.Value("invalid_session"))
+}
+
+func TestServer_SessionsDisabled(t *testing.T) {
+	s, _ := newTestServer(":0", Limits{MaxTraceBytes: 1024})
+
+	rec := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/sessions", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestServer_Replay(t *testing.T) {
+	tests := []struct {
+		name     string
+		reject   bool
+		expected int
+		stored   int
+	}{
+		{"flag", false, http.StatusOK, 3},
+		{"reject", true, http.StatusConflict, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repo := newIngestServer(":0", Limits{MaxTraceBytes: 1024}, IngestOptions{RejectReplays: tt.reject})
+
+			assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, `{"seq": 1, "nonce": "a", "duplicateReports": 0}`))
+			assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, `{"seq": 3, "nonce": "b"}`))
+			assert.Equal(t, tt.expected, postTrace(s.server.Handler, `{"seq": 3, "nonce": "b"}`))
+
+			traces, _ := repo.Get("user1")
+			require.Len(t, traces, tt.stored)
+			assert.Equal(t, int64(0), traces[0]["sequenceGaps"])
+			assert.Equal(t, false, traces[0]["unsequenced"])
+			assert.Equal(t, int64(1), traces[1]["sequenceGaps"])
+			if !tt.reject {
+				assert.Equal(t, int64(1), traces[2]["duplicateReports"])
+			}
+
+			// A repeated sequence number with a fresh nonce is flagged but never rejected
+			assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, `{"seq": 3, "nonce": "c"}`))
+			traces, _ = repo.Get("user1")
+			assert.Equal(t, int64(1), traces[len(traces)-1]["outOfOrderReports"])
+		})
+	}
+}
+
+func TestServer_Obfuscation(t *testing.T) {
+	obfuscator, err := obfuscation.NewObfuscator(public.Collector, "", 0)
+	require.NoError(t, err)
+	key := regexp.MustCompile(`\n(\w+): metrics\.clicks,`).FindStringSubmatch(string(obfuscator.Script()))
+	require.Len(t, key, 2)
+
+	s, repo := newIngestServer(":0", Limits{MaxTraceBytes: 1024}, IngestOptions{Obfuscator: obfuscator})
+	body := fmt.Sprintf(`{"_v": %q, %q: 2}`, obfuscator.MappingID(), key[1])
+	assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, body))
+	assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, `{"clicks": 2}`))
+	assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, `{"_v": "00000000", "clicks": 2}`))
+
+	traces, _ := repo.Get("user1")
+	require.Len(t, traces, 3)
+	assert.Equal(t, float64(2), traces[0]["clicks"])
+	assert.NotContains(t, traces[0], key[1])
+	assert.NotContains(t, traces[0], obfuscation.MappingField)
+	for i, status := range []string{obfuscation.StatusCurrent, obfuscation.StatusMissing, obfuscation.StatusUnknown} {
+		assert.Equal(t, status, traces[i]["collectorMapping"])
+	}
+
+	s, repo = newTestServer(":0", Limits{MaxTraceBytes: 1024})
+	assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, `{"clicks": 2}`))
+	traces, _ = repo.Get("user1")
+	assert.Equal(t, "", traces[0]["collectorMapping"], "mapping should be empty without obfuscation")
+}
+
+func TestServer_RawEvents(t *testing.T) {
+	s, repo := newIngestServer(":0", Limits{MaxTraceBytes: 1024}, IngestOptions{Events: EventsOptions{MaxEvents: 2}})
+	body := `{"clicks": 1, "events": {"mouse": [0, 10, 0, 0, 0, 10, 30, 40, 0, 10, 30, 40], "keys": [1, 5, 2, 50]}}`
+	assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, body))
+	assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, `{"clicks": 1, "events": {"mouse": [0, 10]}}`))
+	assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, `{"clicks": 1}`))
+
+	traces, _ := repo.Get("user1")
+	require.Len(t, traces, 3)
+	assert.NotContains(t, traces[0], "events", "raw events should not be stored")
+	assert.Equal(t, int64(2), traces[0]["rawMouseEvents"])
+	assert.Equal(t, int64(2), traces[0]["rawKeyEvents"])
+	assert.Equal(t, true, traces[0]["rawEventsTruncated"])
+	assert.Equal(t, false, traces[0]["rawEventsInvalid"])
+	assert.Equal(t, float64(50), traces[0]["pathLength"])
+	assert.Equal(t, float64(5000), traces[0]["velocityMean"])
+
+	assert.Equal(t, true, traces[1]["rawEventsInvalid"])
+	assert.Equal(t, int64(0), traces[1]["rawMouseEvents"])
+	assert.Equal(t, false, traces[2]["rawEventsInvalid"])
+	assert.Equal(t, float64(0), traces[2]["pathLength"], "features should be set without raw events")
+	for _, field := range trajectory.Fields {
+		assert.Contains(t, traces[2], field.Name)
+	}
+
+	// Overflowing positions and times must not set NaN features, which break the dataset and the ML batches.
+	for _, events := range []string{
+		`{"mouse": [0, 10, 1e308, 0, 0, 10, 1e308, 0, 0, 10, 1e308, 0]}`,
+		`{"mouse": [0, 0, 0, 0, 0, 1e-310, 1000, 0, 0, 1e-310, -1000, 0]}`,
+	} {
+		s, repo := newIngestServer(":0", Limits{MaxTraceBytes: 1024}, IngestOptions{})
+		assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, `{"clicks": 1, "events": `+events+`}`))
+		traces, _ := repo.Get("user1")
+		require.Len(t, traces, 1)
+		assert.Equal(t, true, traces[0]["rawEventsInvalid"], events)
+		assert.Equal(t, float64(0), traces[0]["velocityMean"], events)
+		_, err := json.Marshal(traces[0])
+		assert.NoError(t, err, events)
+	}
+
+	s, repo = newIngestServer(":0", Limits{MaxTraceBytes: 1024}, IngestOptions{Events: EventsOptions{Keep: true}})
+	assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, body))
+	traces, _ = repo.Get("user1")
+	assert.Contains(t, traces[0], "events", "raw events should be kept")
+}
+
+func TestServer_Keystrokes(t *testing.T) {
+	s, repo := newIngestServer(":0", Limits{MaxTraceBytes: 1024}, IngestOptions{Events: EventsOptions{MaxEvents: 1000}})
+	assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, `{"clicks": 1, "keystrokes": [100, 0, 0, 80, -20, 0, 50, 100, 1]}`))
+	assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, `{"clicks": 1, "keystrokes": [100, 0]}`))
+	assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, `{"clicks": 1}`))
+
+	traces, _ := repo.Get("user1")
+	require.Len(t, traces, 3)
+	assert.NotContains(t, traces[0], "keystrokes", "keystrokes should not be stored")
+	assert.Equal(t, int64(3), traces[0]["keystrokeCount"])
+	assert.Equal(t, float64(40), traces[0]["flightMean"])
+	assert.Equal(t, float64(2500), traces[0]["digraphLatencyVariance"])
+	assert.Equal(t, false, traces[0]["keystrokesInvalid"])
+
+	assert.Equal(t, true, traces[1]["keystrokesInvalid"])
+	assert.Equal(t, false, traces[2]["keystrokesInvalid"])
+	for _, field := range keystroke.Fields {
+		assert.Contains(t, traces[2], field.Name, "features should be set without keystrokes")
+	}
+
+	// Overflowing times must not set NaN features, which break the dataset and the ML batches.
+	s, repo = newIngestServer(":0", Limits{MaxTraceBytes: 1024}, IngestOptions{})
+	assert.Equal(t, http.StatusOK, postTrace(s.server.Handler, `{"clicks": 1, "keystrokes": [1e308, 0, 0, 1e308, 1e308, 0, 1e308, 1e308, 0]}`))
+	traces, _ = repo.Get("user1")
+	require.Len(t, traces, 1)
+	assert.Equal(t, true, traces[0]["keystrokesInvalid"])
+	assert.Equal(t, float64(0), traces[0]["dwellMean"])
+	_, err := json.Marshal(traces[0])
+	assert.NoError(t, err)
+}
+
+func TestApiV1Router_Score(t *testing.T) {
+	ar, _ := newStreamRouter(time.Minute)
+	mux := ar.Mux()
+
+	get := func(path string) (int, map[string]any) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		var body map[string]any
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		}
+		return rec.Code, body
+	}
+
+	code, body := get("/api/v1/scores/human")
+	assert.Equal(t, http.StatusOK, code)
+	require.Len(t, body, 1, "score response should be a flat map of keys")
+	assert.InDelta(t, 0.1, body["automation"], 0.0001)
+
+	code, body = get("/api/v1/scores/human/result")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "human", body["verdict"])
+	assert.InDelta(t, 0.1, body["score"].(map[string]any)["automation"], 0.0001)
+
+	code, _ = get("/api/v1/scores/unknown")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = get("/api/v1/scores/unknown/result")
+	assert.Equal(t, http.StatusNotFound, code)
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"bean/internal/score"
+	"bean/internal/score/scorer"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// streamWriteTimeout limits the time of a single write to a score stream.
+const streamWriteTimeout = 10 * time.Second
+
+// StreamOptions configures score streams.
+type StreamOptions struct {
+	// Delta — minimal change of any score key that triggers a score event.
+	Delta float32
+	// Heartbeat — interval between heartbeat comments keeping the connection open.
+	Heartbeat time.Duration
+}
+
+// subscription is a score stream listening to the updates of a session.
+type subscription struct {
+	updates chan struct{} // signaled when a trace for the session is ingested
+	expired chan struct{} // closed when the session expires
+}
+
+// streamHub tracks score stream subscriptions by session token.
+// The trace handler publishes session updates, the repository reports expired sessions.
+//
+// streamHub is thread-safe.
+type streamHub struct {
+	subscriptions map[string]map[*subscription]struct{} // subscriptions by session token
+	mu            sync.Mutex                            // mutex to protect access to subscriptions
+	closed        chan struct{}                         // closed when the server shuts down
+	closeOnce     sync.Once                             // guards closing of closed
+}
+
+// subscribe creates a subscription to the updates of the session.
+func (h *streamHub) subscribe(token string) *subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub := &subscription{
+		updates: make(chan struct{}, 1),
+		expired: make(chan struct{}),
+	}
+	if h.subscriptions[token] == nil {
+		h.subscriptions[token] = make(map[*subscription]struct{})
+	}
+	h.subscriptions[token][sub] = struct{}{}
+	return sub
+}
+
+// unsubscribe removes the subscription.
+func (h *streamHub) unsubscribe(token string, sub *subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.subscriptions[token], sub)
+	if len(h.subscriptions[token]) == 0 {
+		delete(h.subscriptions, token)
+	}
+}
+
+// publish notifies the subscriptions of the session about an ingested trace.
+// Never blocks: pending notifications are coalesced.
+func (h *streamHub) publish(token string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscriptions[token] {
+		select {
+		case sub.updates <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// expire closes the subscriptions of the expired session.
+func (h *streamHub) expire(token string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscriptions[token] {
+		close(sub.expired)
+	}
+	delete(h.subscriptions, token)
+}
+
+// close ends all score streams. Used on server shutdown, which does not wait
+// for long-lived responses on its own.
+func (h *streamHub) close() {
+	h.closeOnce.Do(func() { close(h.closed) })
+}
+
+// newStreamHub creates an empty stream hub.
+func newStreamHub() *streamHub {
+	return &streamHub{
+		subscriptions: make(map[string]map[*subscription]struct{}),
+		closed:        make(chan struct{}),
+	}
+}
+
+// changed reports whether the new result differs from the previous one enough to be sent:
+// the verdict changed, a key appeared or disappeared, or any key changed by at least delta.
+func changed(prev, next score.Result, delta float32) bool {
+	if prev.Verdict != next.Verdict || len(prev.Score) != len(next.Score) {
+		return true
+	}
+
+	for k, v := range next.Score {
+		p, found := prev.Score[k]
+		if !found || v-p >= delta || p-v >= delta {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeEvent writes a server-sent event with JSON data and flushes it to the client.
+func writeEvent(rc *http.ResponseController, w http.ResponseWriter, event string, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, body); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
+
+// writeHeartbeat writes an SSE comment keeping the connection open.
+func writeHeartbeat(rc *http.ResponseController, w http.ResponseWriter) error {
+	rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
+
+// scoreStreamHandler streams score updates of a session as server-sent events.
+// The token is extracted from the URL path: /api/v1/scores/{token}/stream.
+//
+// Behavior:
+// - Returns 404 if the session is not found.
+// - Sends the current score as a "score" event.
+// - On every ingested trace recalculates the score and sends it if it changed enough.
+// - A changed verdict is sent as a "verdict" event, a changed score as a "score" event.
+// - Sends heartbeat comments at the configured interval.
+// - Sends an "expired" event and closes the stream when the session expires.
+// - Closes the stream when the server shuts down.
+func (ar *ApiV1Router) scoreStreamHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+	if len(token) == 0 {
+		slog.Warn("Empty trace token", "client", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Subscribe before the first calculation so that no update is missed
+	sub := ar.streams.subscribe(token)
+	defer ar.streams.unsubscribe(token, sub)
+
+	last, err := ar.compositeScorer.Score(r.Context(), token)
+	if errors.Is(err, scorer.ErrSessionNotFound) {
+		slog.Warn("Score not found", "id", token, "error", err, "client", r.RemoteAddr)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("Score calculation failed", "id", token, "error", err, "client", r.RemoteAddr)
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	if err = writeEvent(rc, w, "score", last); err != nil {
+		slog.Debug("Score stream write", "id", token, "error", err, "client", r.RemoteAddr)
+		return
+	}
+
+	heartbeat := time.NewTicker(ar.stream.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ar.streams.closed:
+			return
+		case <-sub.expired:
+			writeEvent(rc, w, "expired", map[string]string{"token": token})
+			return
+		case <-heartbeat.C:
+			err = writeHeartbeat(rc, w)
+		case <-sub.updates:
+			var next score.Result
+			next, err = ar.compositeScorer.Score(r.Context(), token)
+			if errors.Is(err, scorer.ErrSessionNotFound) {
+				writeEvent(rc, w, "expired", map[string]string{"token": token})
+				return
+			}
+			if err != nil {
+				slog.Warn("Score stream calculation failed", "id", token, "error", err, "client", r.RemoteAddr)
+				err = nil
+				continue
+			}
+			if !changed(last, next, ar.stream.Delta) {
+				continue
+			}
+
+			event := "score"
+			if next.Verdict != last.Verdict {
+				event = "verdict"
+			}
+			last = next
+			err = writeEvent(rc, w, event, next)
+		}
+
+		if err != nil {
+			slog.Debug("Score stream write", "id", token, "error", err, "client", r.RemoteAddr)
+			return
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"bean/internal/score"
+	"bean/internal/score/scorer"
+	"bean/internal/trace"
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamHub(t *testing.T) {
+	h := newStreamHub()
+	first := h.subscribe("a")
+	second := h.subscribe("a")
+	other := h.subscribe("b")
+
+	// A consumer that doesn't read never blocks the publisher: notifications are coalesced
+	for range 10 {
+		h.publish("a")
+	}
+	assert.Len(t, first.updates, 1)
+	assert.Len(t, second.updates, 1)
+	assert.Len(t, other.updates, 0, "other sessions should not be notified")
+
+	h.unsubscribe("a", first)
+	<-second.updates
+	h.publish("a")
+	assert.Len(t, first.updates, 1, "unsubscribed stream should not be notified")
+	assert.Len(t, second.updates, 1)
+
+	h.expire("a")
+	_, open := <-second.expired
+	assert.False(t, open)
+	assert.NotContains(t, h.subscriptions, "a")
+
+	h.unsubscribe("b", other)
+	assert.Empty(t, h.subscriptions)
+
+	h.close()
+	h.close()
+	_, open = <-h.closed
+	assert.False(t, open)
+}
+
+func TestChanged(t *testing.T) {
+	prev := score.Result{Score: score.Score{"automation": 0.5}, Verdict: "human"}
+
+	assert.False(t, changed(prev, prev, 0.1))
+	assert.False(t, changed(prev, score.Result{Score: score.Score{"automation": 0.55}, Verdict: "human"}, 0.1))
+	assert.True(t, changed(prev, score.Result{Score: score.Score{"automation": 0.7}, Verdict: "human"}, 0.1))
+	assert.True(t, changed(prev, score.Result{Score: score.Score{"automation": 0.3}, Verdict: "human"}, 0.1))
+	assert.True(t, changed(prev, score.Result{Score: score.Score{"automation": 0.5}, Verdict: "bot"}, 0.1), "verdict change")
+	assert.True(t, changed(prev, score.Result{Score: score.Score{"other": 0.5}, Verdict: "human"}, 0.1), "key change")
+	assert.True(t, changed(prev, score.Result{Score: score.Score{"automation": 0.5, "other": 0}, Verdict: "human"}, 0.1), "new key")
+}
+
+// sseEvent is a server-sent event read from a score stream.
+type sseEvent struct {
+	name string
+	data map[string]any
+}
+
+// readEvent reads the next event of the stream, skipping heartbeat comments.
+func readEvent(t *testing.T, reader *bufio.Reader) sseEvent {
+	t.Helper()
+	var event sseEvent
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.data))
+		case line == "" && event.name != "":
+			return event
+		}
+	}
+}
+
+// newStreamRouter creates a router scoring sessions by mouseMoves with the "human" session (score 0.1).
+func newStreamRouter(heartbeat time.Duration) (*ApiV1Router, *trace.TracesRepository) {
+	repo := trace.NewTracesRepository(5, 0)
+	repo.Append("human", trace.Trace{"mouseMoves": 0.1})
+	cs := scorer.NewCompositeScorer([]scorer.Member{{Name: "moves", Scorer: movesScorer{}}}, repo, 0, scorer.Aggregator{},
+		score.Verdicts{Key: "automation", Bands: []score.Band{{Name: "human", Min: 0}, {Name: "bot", Min: 0.8}}})
+	ar := NewApiV1Router("", "token", repo, cs, nil, nil, StreamOptions{Delta: 0.1, Heartbeat: heartbeat}, 1024, IngestOptions{}, ChallengeOptions{}, nil)
+	return ar, repo
+}
+
+// subscriptions returns the number of score streams of the session.
+func subscriptions(h *streamHub, token string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscriptions[token])
+}
+
+func TestApiV1Router_ScoreStream(t *testing.T) {
+	ar, repo := newStreamRouter(10 * time.Millisecond)
+	ts := httptest.NewServer(ar.Mux())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/v1/scores/unknown/stream")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, 0, subscriptions(ar.streams, "unknown"))
+
+	resp, err = http.Get(ts.URL + "/api/v1/scores/human/stream")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	reader := bufio.NewReader(resp.Body)
+
+	event := readEvent(t, reader)
+	assert.Equal(t, "score", event.name)
+	assert.Equal(t, "human", event.data["verdict"])
+
+	post := func(body string) {
+		req := httptest.NewRequest("POST", "/api/v1/traces", strings.NewReader(body))
+		req.AddCookie(&http.Cookie{Name: "token", Value: "human"})
+		rec := httptest.NewRecorder()
+		ar.Mux().ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// Changes below the delta are filtered out, so the next event is the verdict change
+	post(`{"mouseMoves": 0.15}`)
+	post(`{"mouseMoves": 0.9}`)
+	event = readEvent(t, reader)
+	assert.Equal(t, "verdict", event.name)
+	assert.Equal(t, "bot", event.data["verdict"])
+	assert.InDelta(t, 0.9, event.data["score"].(map[string]any)["automation"], 0.0001)
+
+	post(`{"mouseMoves": 0.7}`)
+	event = readEvent(t, reader)
+	assert.Equal(t, "verdict", event.name)
+	post(`{"mouseMoves": 0.5}`)
+	event = readEvent(t, reader)
+	assert.Equal(t, "score", event.name)
+	assert.InDelta(t, 0.5, event.data["score"].(map[string]any)["automation"], 0.0001)
+
+	require.True(t, repo.Delete("human"))
+	event = readEvent(t, reader)
+	assert.Equal(t, "expired", event.name)
+	assert.Equal(t, "human", event.data["token"])
+	assert.Eventually(t, func() bool { return subscriptions(ar.streams, "human") == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestApiV1Router_ScoreStreamDisconnect(t *testing.T) {
+	ar, _ := newStreamRouter(time.Minute)
+	ts := httptest.NewServer(ar.Mux())
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, "GET", ts.URL+"/api/v1/scores/human/stream", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "score", readEvent(t, bufio.NewReader(resp.Body)).name)
+	assert.Equal(t, 1, subscriptions(ar.streams, "human"))
+
+	// The handler exits and unsubscribes when the client goes away
+	cancel()
+	assert.Eventually(t, func() bool { return subscriptions(ar.streams, "human") == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestApiV1Router_ScoreStreamShutdown(t *testing.T) {
+	ar, _ := newStreamRouter(time.Minute)
+	ts := httptest.NewServer(ar.Mux())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/v1/scores/human/stream")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	assert.Equal(t, "score", readEvent(t, reader).name)
+
+	ar.streams.close()
+	_, err = reader.ReadString('\n')
+	assert.Error(t, err, "stream should end on shutdown")
+	assert.Eventually(t, func() bool { return subscriptions(ar.streams, "human") == 0 }, time.Second, 10*time.Millisecond)
+}
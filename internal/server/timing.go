@@ -0,0 +1,70 @@
+package server
+
+import (
+	"bean/internal/trace"
+	"time"
+)
+
+// parseTraceTime parses a time field of the trace (e.g., the client timestamp).
+// Returns false if the field is missing or is not an RFC 3339 time.
+func parseTraceTime(t trace.Trace, field string) (time.Time, bool) {
+	value, ok := t[field].(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return parsed, true
+}
+
+// setTimingSignals compares the client timestamp of the trace with the server receive time
+// and with the previous trace of the session. Scripted clients often send fixed, replayed
+// or impossible timestamps, or report at intervals that don't match their delivery.
+// The signals are always set; values that can't be computed are zero.
+//
+// Fields:
+//   - timestampValid: the client timestamp is an RFC 3339 time
+//   - clockSkewMs: client timestamp minus the server receive time, in milliseconds
+//   - interReportMs: client timestamp minus the client timestamp of the previous trace, in milliseconds
+//   - reportJitterMs: absolute difference between the client and the server intervals since the previous trace, in milliseconds
+//   - outOfOrder: the client timestamp is earlier than the one of the previous trace
+//   - duplicateTimestamp: the client timestamp equals the one of the previous trace
+//
+// Parameters:
+//   - t: trace to set the signals on, with the receivedAt field set
+//   - previous: previous trace of the session (can be nil)
+//   - receivedAt: server time the trace was received
+func setTimingSignals(t trace.Trace, previous trace.Trace, receivedAt time.Time) {
+	t["clockSkewMs"] = int64(0)
+	t["interReportMs"] = int64(0)
+	t["reportJitterMs"] = int64(0)
+	t["outOfOrder"] = false
+	t["duplicateTimestamp"] = false
+
+	clientTime, valid := parseTraceTime(t, "timestamp")
+	t["timestampValid"] = valid
+	if !valid {
+		return
+	}
+	t["clockSkewMs"] = clientTime.Sub(receivedAt).Milliseconds()
+
+	if previous == nil {
+		return
+	}
+	previousClientTime, ok := parseTraceTime(previous, "timestamp")
+	if !ok {
+		return
+	}
+
+	clientInterval := clientTime.Sub(previousClientTime)
+	t["interReportMs"] = clientInterval.Milliseconds()
+	t["outOfOrder"] = clientInterval < 0
+	t["duplicateTimestamp"] = clientInterval == 0
+
+	if previousReceivedAt, ok := parseTraceTime(previous, "receivedAt"); ok {
+		jitter := clientInterval - receivedAt.Sub(previousReceivedAt)
+		t["reportJitterMs"] = max(jitter, -jitter).Milliseconds()
+	}
+}
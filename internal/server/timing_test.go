@@ -0,0 +1,66 @@
+package server
+
+import (
+	"bean/internal/trace"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetTimingSignals(t *testing.T) {
+	received := time.Date(2026, 1, 1, 12, 0, 10, 0, time.UTC)
+	previous := trace.Trace{"timestamp": "2026-01-01T12:00:00.000Z", "receivedAt": "2026-01-01T12:00:01.000Z"}
+
+	tests := []struct {
+		name      string
+		timestamp any
+		previous  trace.Trace
+		expected  trace.Trace
+	}{
+		{"first trace", "2026-01-01T12:00:09.500Z", nil, trace.Trace{
+			"timestampValid": true, "clockSkewMs": int64(-500), "interReportMs": int64(0), "reportJitterMs": int64(0),
+			"outOfOrder": false, "duplicateTimestamp": false,
+		}},
+		{"regular report", "2026-01-01T12:00:09.000Z", previous, trace.Trace{
+			"timestampValid": true, "clockSkewMs": int64(-1000), "interReportMs": int64(9000), "reportJitterMs": int64(0),
+			"outOfOrder": false, "duplicateTimestamp": false,
+		}},
+		{"jitter", "2026-01-01T12:00:03.000Z", previous, trace.Trace{
+			"timestampValid": true, "clockSkewMs": int64(-7000), "interReportMs": int64(3000), "reportJitterMs": int64(6000),
+			"outOfOrder": false, "duplicateTimestamp": false,
+		}},
+		{"duplicate", "2026-01-01T12:00:00Z", previous, trace.Trace{
+			"timestampValid": true, "clockSkewMs": int64(-10000), "interReportMs": int64(0), "reportJitterMs": int64(9000),
+			"outOfOrder": false, "duplicateTimestamp": true,
+		}},
+		{"out of order", "2026-01-01T11:59:59.000Z", previous, trace.Trace{
+			"timestampValid": true, "clockSkewMs": int64(-11000), "interReportMs": int64(-1000), "reportJitterMs": int64(10000),
+			"outOfOrder": true, "duplicateTimestamp": false,
+		}},
+		{"client clock ahead", "2026-01-01T12:05:10.000Z", nil, trace.Trace{
+			"timestampValid": true, "clockSkewMs": int64(300000), "interReportMs": int64(0), "reportJitterMs": int64(0),
+			"outOfOrder": false, "duplicateTimestamp": false,
+		}},
+		{"invalid timestamp", "yesterday", previous, trace.Trace{
+			"timestampValid": false, "clockSkewMs": int64(0), "interReportMs": int64(0), "reportJitterMs": int64(0),
+			"outOfOrder": false, "duplicateTimestamp": false,
+		}},
+		{"missing timestamp", nil, previous, trace.Trace{
+			"timestampValid": false, "clockSkewMs": int64(0), "interReportMs": int64(0), "reportJitterMs": int64(0),
+			"outOfOrder": false, "duplicateTimestamp": false,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := trace.Trace{}
+			if tt.timestamp != nil {
+				tr["timestamp"] = tt.timestamp
+			}
+			setTimingSignals(tr, tt.previous, received)
+			delete(tr, "timestamp")
+			assert.Equal(t, tt.expected, tr)
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// CertReloader serves the TLS certificate loaded from files and reloads it
+// when the files change, e.g. after the certificate is renewed by cert-manager.
+//
+// CertReloader is thread-safe.
+type CertReloader struct {
+	cert     string                          // path to the PEM-encoded certificate chain
+	key      string                          // path to the PEM-encoded private key
+	interval time.Duration                   // interval between checks of the files
+	modTime  time.Time                       // latest modification time of the loaded files
+	current  atomic.Pointer[tls.Certificate] // loaded certificate
+	ticker   *time.Ticker                    // ticker of the file checks
+}
+
+// GetCertificate returns the loaded certificate. Used as tls.Config.GetCertificate.
+func (c *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.current.Load(), nil
+}
+
+// reload loads the certificate if any of the files has changed since the last load.
+// Returns an error if the files can't be loaded; the loaded certificate is kept in that case.
+func (c *CertReloader) reload() error {
+	var modTime time.Time
+	for _, file := range []string{c.cert, c.key} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	if modTime.Equal(c.modTime) && c.current.Load() != nil {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.cert, c.key)
+	if err != nil {
+		return err
+	}
+	c.current.Store(&cert)
+	c.modTime = modTime
+	slog.Info("TLS certificate loaded", "cert", c.cert)
+	return nil
+}
+
+// Serve periodically checks the certificate files for changes and reloads the certificate.
+// If the files can't be loaded, for example while they are being replaced, the error is logged
+// and the loaded certificate is kept.
+// The method blocks execution and should be called in a separate goroutine.
+// Use the Stop method to stop.
+func (c *CertReloader) Serve() {
+	c.ticker = time.NewTicker(c.interval)
+	for range c.ticker.C {
+		if err := c.reload(); err != nil {
+			slog.Error("Unable to reload TLS certificate", "cert", c.cert, "error", err)
+		}
+	}
+}
+
+// Stop stops checking the certificate files.
+// The method is safe to call even if Serve has not been started yet.
+func (c *CertReloader) Stop() {
+	if c.ticker != nil {
+		c.ticker.Stop()
+	}
+}
+
+// NewCertReloader creates a new instance of CertReloader.
+//
+// Parameters:
+//   - cert: path to the PEM-encoded certificate chain
+//   - key: path to the PEM-encoded private key
+//   - interval: interval between checks of the files
+//
+// Returns a pointer to the CertReloader or an error if the certificate can't be loaded.
+// To reload the certificate on changes, call Serve in a separate goroutine.
+func NewCertReloader(cert, key string, interval time.Duration) (*CertReloader, error) {
+	c := &CertReloader{cert: cert, key: key, interval: interval}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// newTLSConfig creates the TLS configuration of the server.
+// If clientCa is set, client certificates signed by the CA are requested and verified,
+// but not required, so browsers can still send traces without a certificate.
+func newTLSConfig(certs *CertReloader, minVersion uint16, clientCa string) (*tls.Config, error) {
+	config := &tls.Config{
+		GetCertificate: certs.GetCertificate,
+		MinVersion:     minVersion,
+	}
+
+	if clientCa != "" {
+		content, err := os.ReadFile(clientCa)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(content) {
+			return nil, errors.New("no certificates found in " + clientCa)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return config, nil
+}
+
+// NewRedirectServer creates a server redirecting all plain HTTP requests to HTTPS.
+// Requests are redirected with 308, so POST requests keep their method and body.
+//
+// Parameters:
+// - address: address and port to listen on (e.g., ":80").
+// - httpsAddress: address of the TLS server; its port is used in the redirect location.
+//
+// Returns pointer to a ready-to-run server.
+func NewRedirectServer(address string, httpsAddress string) *Server {
+	_, port, _ := net.SplitHostPort(httpsAddress)
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	}
+
+	s := Server{server: &http.Server{
+		Addr:           address,
+		Handler:        http.HandlerFunc(handler),
+		ReadTimeout:    time.Second * 3,
+		WriteTimeout:   time.Second * 3,
+		MaxHeaderBytes: 1024 * 10,
+	}}
+
+	return &s
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCertificate writes a self-signed certificate with the common name and its key to the directory.
+// Returns the paths to the certificate and the key.
+func writeCertificate(t *testing.T, dir, name string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+	return certFile, keyFile
+}
+
+// commonName returns the common name of the certificate served by the reloader.
+func commonName(t *testing.T, c *CertReloader) string {
+	cert, err := c.GetCertificate(nil)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCertificate(t, dir, "first")
+
+	c, err := NewCertReloader(certFile, keyFile, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "first", commonName(t, c))
+
+	writeCertificate(t, dir, "second")
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+	require.NoError(t, os.Chtimes(keyFile, later, later))
+	require.NoError(t, c.reload())
+	assert.Equal(t, "second", commonName(t, c))
+
+	// A broken certificate keeps the loaded one
+	require.NoError(t, os.WriteFile(certFile, []byte("broken"), 0o600))
+	require.NoError(t, os.Chtimes(certFile, later.Add(time.Second), later.Add(time.Second)))
+	assert.Error(t, c.reload())
+	assert.Equal(t, "second", commonName(t, c))
+
+	_, err = NewCertReloader(filepath.Join(dir, "missing.crt"), keyFile, time.Minute)
+	assert.Error(t, err)
+}
+
+func TestServer_TLS(t *testing.T) {
+	certFile, keyFile := writeCertificate(t, t.TempDir(), "bean")
+	certs, err := NewCertReloader(certFile, keyFile, time.Minute)
+	require.NoError(t, err)
+
+	s := Server{server: &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})}}
+	_, err = s.WithTLS(certs, tls.VersionTLS13, "")
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.server.ServeTLS(ln, "", "")
+	defer s.server.Close()
+
+	pool := x509.NewCertPool()
+	content, _ := os.ReadFile(certFile)
+	pool.AppendCertsFromPEM(content)
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: pool},
+		ForceAttemptHTTP2: true,
+	}}
+
+	resp, err := client.Get("https://" + ln.Addr().String())
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 2, resp.ProtoMajor, "HTTP/2 should be negotiated")
+
+	// Clients below the minimum version are rejected
+	old := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MaxVersion: tls.VersionTLS12}}}
+	_, err = old.Get("https://" + ln.Addr().String())
+	assert.Error(t, err)
+
+	_, err = s.WithTLS(certs, tls.VersionTLS12, certFile+".missing")
+	assert.Error(t, err)
+}
+
+func TestRedirectServer(t *testing.T) {
+	tests := []struct {
+		httpsAddress string
+		expected     string
+	}{
+		{":443", "https://example.com/api/v1/traces?a=1"},
+		{":8443", "https://example.com:8443/api/v1/traces?a=1"},
+	}
+
+	for _, tt := range tests {
+		s := NewRedirectServer(":80", tt.httpsAddress)
+		rec := httptest.NewRecorder()
+		s.server.Handler.ServeHTTP(rec, httptest.NewRequest("POST", "http://example.com:80/api/v1/traces?a=1", nil))
+
+		assert.Equal(t, http.StatusPermanentRedirect, rec.Code)
+		assert.Equal(t, tt.expected, rec.Header().Get("Location"))
+	}
+}
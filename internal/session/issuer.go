@@ -0,0 +1,228 @@
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenVersion is the prefix of the token format.
+const tokenVersion = "v1"
+
+// Prefix lengths of the client address bound to tokens. Clients keep their
+// tokens while their address changes within the network (e.g., DHCP or privacy addresses).
+const (
+	ipv4PrefixBits = 24
+	ipv6PrefixBits = 48
+)
+
+var (
+	// ErrMalformed is returned for tokens not issued by Issuer.
+	ErrMalformed = errors.New("malformed session token")
+	// ErrExpired is returned for tokens past their expiration time.
+	ErrExpired = errors.New("session token expired")
+	// ErrUnknownKey is returned for tokens signed by a removed or foreign key.
+	ErrUnknownKey = errors.New("session token signed by unknown key")
+	// ErrInvalidSignature is returned for forged tokens or tokens used from another network or browser.
+	ErrInvalidSignature = errors.New("invalid session token signature")
+)
+
+// key is a signing key of the keyring.
+type key struct {
+	id      string    // key identifier stored in tokens
+	secret  []byte    // HMAC secret
+	retired time.Time // time the key stopped signing, zero for the current key
+}
+
+// newKey creates a key with the identifier derived from the secret,
+// so instances sharing secrets accept each other's tokens.
+func newKey(secret []byte) key {
+	sum := sha256.Sum256(secret)
+	return key{id: hex.EncodeToString(sum[:4]), secret: secret}
+}
+
+// Issuer issues and verifies session tokens signed with HMAC-SHA256.
+// A token carries its expiration time, a random nonce and the identifier of the signing key;
+// the signature also covers the network prefix of the client address (/24 for IPv4, /48 for IPv6)
+// and the hash of the User-Agent, so a token copied to another network or browser is rejected.
+//
+// The keyring is either fixed (configured secrets; the first one signs, the others verify
+// tokens issued before a rotation) or generated: a random key replaced every rotation interval.
+// Retired generated keys verify tokens until the tokens they signed expire.
+// Generated keys are not shared between instances and are lost on restart.
+//
+// Issuer is thread-safe.
+type Issuer struct {
+	keys   []key            // keyring, the current key first
+	ttl    time.Duration    // lifetime of issued tokens
+	rotate time.Duration    // interval of the generated key rotation, zero for fixed keys
+	now    func() time.Time // clock, replaced in tests
+	mu     sync.RWMutex     // mutex to protect access to keys
+	ticker *time.Ticker     // ticker of the key rotation
+}
+
+// Issue returns a new token bound to the client address and User-Agent, and its expiration time.
+func (i *Issuer) Issue(client netip.Addr, userAgent string) (string, time.Time) {
+	i.mu.RLock()
+	current := i.keys[0]
+	i.mu.RUnlock()
+
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	expires := i.now().Add(i.ttl).Truncate(time.Second)
+
+	payload := strings.Join([]string{
+		tokenVersion,
+		strconv.FormatInt(expires.Unix(), 10),
+		base64.RawURLEncoding.EncodeToString(nonce),
+		current.id,
+	}, ".")
+	return payload + "." + sign(current.secret, payload, client, userAgent), expires
+}
+
+// Verify checks the signature and the expiration time of the token issued for the client
+// address and User-Agent.
+// Returns ErrMalformed, ErrExpired, ErrUnknownKey or ErrInvalidSignature if the token is not valid.
+func (i *Issuer) Verify(token string, client netip.Addr, userAgent string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 || parts[0] != tokenVersion {
+		return ErrMalformed
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return ErrMalformed
+	}
+
+	secret, found := i.secret(parts[3])
+	if !found {
+		return ErrUnknownKey
+	}
+	payload := strings.Join(parts[:4], ".")
+	if !hmac.Equal([]byte(parts[4]), []byte(sign(secret, payload, client, userAgent))) {
+		return ErrInvalidSignature
+	}
+
+	if !i.now().Before(time.Unix(expires, 0)) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// TTL returns the lifetime of issued tokens.
+func (i *Issuer) TTL() time.Duration {
+	return i.ttl
+}
+
+// secret returns the secret of the key with the identifier.
+func (i *Issuer) secret(id string) ([]byte, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	for _, k := range i.keys {
+		if k.id == id {
+			return k.secret, true
+		}
+	}
+	return nil, false
+}
+
+// sign returns the signature of the token payload bound to the client.
+func sign(secret []byte, payload string, client netip.Addr, userAgent string) string {
+	uaSum := sha256.Sum256([]byte(userAgent))
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	mac.Write([]byte{0})
+	mac.Write([]byte(clientPrefix(client)))
+	mac.Write([]byte{0})
+	mac.Write(uaSum[:])
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// clientPrefix returns the network of the client address bound to tokens.
+// Returns an empty string for an unknown address.
+func clientPrefix(client netip.Addr) string {
+	if !client.IsValid() {
+		return ""
+	}
+	client = client.Unmap()
+	bits := ipv6PrefixBits
+	if client.Is4() {
+		bits = ipv4PrefixBits
+	}
+	prefix, _ := client.Prefix(bits)
+	return prefix.String()
+}
+
+// rotateKeys replaces the current generated key with a new one and removes
+// the retired keys whose tokens have expired.
+func (i *Issuer) rotateKeys() {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := i.now()
+	keys := []key{newKey(secret)}
+	for _, k := range i.keys {
+		if k.retired.IsZero() {
+			k.retired = now
+		}
+		if now.Sub(k.retired) < i.ttl {
+			keys = append(keys, k)
+		}
+	}
+	i.keys = keys
+}
+
+// Serve starts a background process that rotates the generated keys once per rotation interval.
+// Does nothing for fixed keys. The method blocks execution and should be called
+// in a separate goroutine. Use the Stop method to stop.
+func (i *Issuer) Serve() {
+	if i.rotate == 0 {
+		return
+	}
+	i.ticker = time.NewTicker(i.rotate)
+	for range i.ticker.C {
+		i.rotateKeys()
+	}
+}
+
+// Stop stops the key rotation.
+// The method is safe to call even if Serve has not been started yet.
+func (i *Issuer) Stop() {
+	if i.ticker != nil {
+		i.ticker.Stop()
+	}
+}
+
+// NewIssuer creates a new instance of Issuer.
+//
+// Parameters:
+//   - secrets: signing secrets, the first one signs new tokens; if empty, keys are generated and rotated
+//   - ttl: lifetime of issued tokens
+//   - rotate: interval of the generated key rotation, ignored if secrets are set
+//
+// Returns a pointer to the Issuer. To rotate generated keys, call Serve in a separate goroutine.
+func NewIssuer(secrets []string, ttl time.Duration, rotate time.Duration) *Issuer {
+	i := &Issuer{ttl: ttl, now: time.Now}
+	if len(secrets) > 0 {
+		for _, secret := range secrets {
+			i.keys = append(i.keys, newKey([]byte(secret)))
+		}
+		return i
+	}
+
+	i.rotate = rotate
+	i.rotateKeys()
+	return i
+}
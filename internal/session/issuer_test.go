@@ -0,0 +1,69 @@
+package session
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssuer_Verify(t *testing.T) {
+	issuer := NewIssuer([]string{"secret-1"}, time.Hour, 0)
+	client := netip.MustParseAddr("203.0.113.7")
+	token, expires := issuer.Issue(client, "agent")
+
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expires, time.Second)
+	assert.NoError(t, issuer.Verify(token, client, "agent"))
+	assert.NoError(t, issuer.Verify(token, netip.MustParseAddr("203.0.113.200"), "agent"), "same network should be accepted")
+
+	assert.ErrorIs(t, issuer.Verify(token, netip.MustParseAddr("198.51.100.7"), "agent"), ErrInvalidSignature)
+	assert.ErrorIs(t, issuer.Verify(token, client, "other-agent"), ErrInvalidSignature)
+	assert.ErrorIs(t, issuer.Verify(strings.Replace(token, ".", ".1", 1), client, "agent"), ErrInvalidSignature, "changed expiration should be rejected")
+	assert.ErrorIs(t, issuer.Verify("b7f4c3d2-uuid", client, "agent"), ErrMalformed)
+	assert.ErrorIs(t, issuer.Verify("", client, "agent"), ErrMalformed)
+
+	other := NewIssuer([]string{"secret-2"}, time.Hour, 0)
+	assert.ErrorIs(t, other.Verify(token, client, "agent"), ErrUnknownKey)
+}
+
+func TestIssuer_Expired(t *testing.T) {
+	issuer := NewIssuer([]string{"secret"}, time.Minute, 0)
+	client := netip.MustParseAddr("2001:db8::1")
+	token, _ := issuer.Issue(client, "agent")
+
+	issuer.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	assert.ErrorIs(t, issuer.Verify(token, client, "agent"), ErrExpired)
+}
+
+func TestIssuer_FixedKeysRotation(t *testing.T) {
+	client := netip.MustParseAddr("203.0.113.7")
+	old := NewIssuer([]string{"old"}, time.Hour, 0)
+	token, _ := old.Issue(client, "agent")
+
+	rotated := NewIssuer([]string{"new", "old"}, time.Hour, 0)
+	assert.NoError(t, rotated.Verify(token, client, "agent"), "tokens of the previous key should be accepted")
+
+	newToken, _ := rotated.Issue(client, "agent")
+	assert.ErrorIs(t, old.Verify(newToken, client, "agent"), ErrUnknownKey, "new tokens should be signed by the first key")
+}
+
+func TestIssuer_GeneratedKeysRotation(t *testing.T) {
+	now := time.Now()
+	issuer := NewIssuer(nil, time.Hour, 10*time.Minute)
+	issuer.now = func() time.Time { return now }
+	client := netip.MustParseAddr("203.0.113.7")
+	token, _ := issuer.Issue(client, "agent")
+
+	now = now.Add(10 * time.Minute)
+	issuer.rotateKeys()
+	assert.NoError(t, issuer.Verify(token, client, "agent"), "retired key should verify its tokens")
+	require.Len(t, issuer.keys, 2)
+
+	now = now.Add(time.Hour)
+	issuer.rotateKeys()
+	assert.ErrorIs(t, issuer.Verify(token, client, "agent"), ErrUnknownKey, "key should be removed after its tokens expire")
+	assert.Len(t, issuer.keys, 2)
+}
@@ -1,6 +1,11 @@
 package trace
 
-import "github.com/google/cel-go/cel"
+import (
+	"bean/internal/keystroke"
+	"bean/internal/trajectory"
+
+	"github.com/google/cel-go/cel"
+)
 
 // NewMovementTraceEnv creates and returns a new CEL environment (cel.Env) pre-configured
 // with variables corresponding to the fields of a behavioral trace.
@@ -43,6 +48,42 @@ func NewMovementTraceEnv() (*cel.Env, error) {
 		cel.Variable("browserVersion", cel.StringType),
 		cel.Variable("osName", cel.StringType),
 		cel.Variable("osVersion", cel.StringType),
+
+		// Server-side signals
+		cel.Variable("sessionRateLimited", cel.IntType),
+		cel.Variable("ipRateLimited", cel.IntType),
+		cel.Variable("clientIp", cel.StringType),
+		cel.Variable("acceptLanguage", cel.StringType),
+		cel.Variable("headerUserAgent", cel.StringType),
+		cel.Variable("secChUa", cel.StringType),
+		cel.Variable("secChUaMobile", cel.BoolType),
+		cel.Variable("secChUaPlatform", cel.StringType),
+		cel.Variable("headerOrder", cel.StringType),
+		cel.Variable("headerOrderKnown", cel.BoolType),
+		cel.Variable("httpProtocol", cel.StringType),
+		cel.Variable("receivedAt", cel.StringType),
+		cel.Variable("timestampValid", cel.BoolType),
+		cel.Variable("clockSkewMs", cel.IntType),
+		cel.Variable("interReportMs", cel.IntType),
+		cel.Variable("reportJitterMs", cel.IntType),
+		cel.Variable("outOfOrder", cel.BoolType),
+		cel.Variable("duplicateTimestamp", cel.BoolType),
+		cel.Variable("sequenceGaps", cel.IntType),
+		cel.Variable("duplicateReports", cel.IntType),
+		cel.Variable("outOfOrderReports", cel.IntType),
+		cel.Variable("unsequenced", cel.BoolType),
+		cel.Variable("challengeSolved", cel.BoolType),
+		cel.Variable("challengeSolveMs", cel.IntType),
+		cel.Variable("challengeDifficulty", cel.IntType),
+		cel.Variable("collectorMapping", cel.StringType),
+		cel.Variable("rawEventsInvalid", cel.BoolType),
+
+		// Trajectory features of the raw events
+		trajectory.Fields.Variables(),
+
+		// Keystroke dynamics
+		cel.Variable("keystrokesInvalid", cel.BoolType),
+		keystroke.Fields.Variables(),
 	)
 
 	if err != nil {
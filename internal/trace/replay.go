@@ -0,0 +1,124 @@
+package trace
+
+import "sync"
+
+// replayWindowSize is the number of recent reports of a session checked for duplicates.
+const replayWindowSize = 128
+
+// report identifies a received report of a session.
+type report struct {
+	seq   int64  // sequence number of the report
+	nonce string // random nonce of the report
+}
+
+// Replay is the result of the replay check of a report.
+type Replay struct {
+	// Duplicate — the nonce of the report has been received before.
+	Duplicate bool
+	// OutOfOrder — the sequence number of the report is not higher than the highest received one:
+	// the report is late or its sequence number is repeated. Such reports are never rejected.
+	OutOfOrder bool
+	// Unsequenced — the report has no sequence number or nonce and was not checked.
+	Unsequenced bool
+	// SequenceGaps — number of sequence numbers of the session skipped and not received later.
+	SequenceGaps int64
+	// DuplicateReports — number of duplicate reports of the session, including this one.
+	DuplicateReports int64
+	// OutOfOrderReports — number of out-of-order reports of the session, including this one.
+	OutOfOrderReports int64
+}
+
+// replayWindow tracks the recent reports of a session.
+type replayWindow struct {
+	recent     [replayWindowSize]report // ring of the recent reports
+	next       int                      // index of the next report in the ring
+	lastSeq    int64                    // highest received sequence number
+	gaps       int64                    // skipped sequence numbers not received later
+	duplicates int64                    // received duplicate reports
+	outOfOrder int64                    // received out-of-order reports
+	mu         sync.Mutex               // mutex to protect the window
+}
+
+// check records the report and returns the replay status of the session.
+func (w *replayWindow) check(seq int64, nonce string) Replay {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	duplicate, repeated := false, false
+	for _, r := range w.recent {
+		duplicate = duplicate || r.nonce == nonce
+		repeated = repeated || r.seq == seq
+	}
+
+	outOfOrder := false
+	switch {
+	case duplicate:
+		w.duplicates++
+	case seq > w.lastSeq:
+		w.gaps += seq - w.lastSeq - 1
+		w.lastSeq = seq
+	default:
+		outOfOrder = true
+		w.outOfOrder++
+		if !repeated && w.gaps > 0 {
+			// A late report fills a gap
+			w.gaps--
+		}
+	}
+
+	if !duplicate {
+		w.recent[w.next] = report{seq: seq, nonce: nonce}
+		w.next = (w.next + 1) % replayWindowSize
+	}
+
+	return Replay{
+		Duplicate:         duplicate,
+		OutOfOrder:        outOfOrder,
+		SequenceGaps:      w.gaps,
+		DuplicateReports:  w.duplicates,
+		OutOfOrderReports: w.outOfOrder,
+	}
+}
+
+// status returns the replay status of the session without recording a report.
+func (w *replayWindow) status() Replay {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return Replay{Unsequenced: true, SequenceGaps: w.gaps, DuplicateReports: w.duplicates, OutOfOrderReports: w.outOfOrder}
+}
+
+// CheckReplay records a report of the session with the sequence number and nonce sent by
+// the collector, and returns whether the report is a duplicate of a recent one together with
+// the session counters of skipped sequence numbers, duplicate and out-of-order reports.
+// Only a repeated nonce makes a report a duplicate. Sequence numbers start at 1 and increase
+// by one with every report; a report with a sequence number not higher than the highest
+// received one is out of order, and if its sequence number is not among the recent ones,
+// it fills its gap. A report with a non-positive sequence number or
+// an empty nonce is not recorded and is marked as unsequenced.
+// The counters are removed together with the traces of the session.
+// The method is thread-safe.
+func (tr *TracesRepository) CheckReplay(id string, seq int64, nonce string) Replay {
+	tr.tracesMu.RLock()
+	window, found := tr.replays[id]
+	tr.tracesMu.RUnlock()
+
+	if !found {
+		if seq <= 0 || nonce == "" {
+			return Replay{Unsequenced: true}
+		}
+
+		tr.tracesMu.Lock()
+		// Double-checked locking
+		if window, found = tr.replays[id]; !found {
+			window = &replayWindow{}
+			tr.replays[id] = window
+		}
+		tr.tracesMu.Unlock()
+	}
+
+	if seq <= 0 || nonce == "" {
+		return window.status()
+	}
+	return window.check(seq, nonce)
+}
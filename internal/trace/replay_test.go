@@ -0,0 +1,57 @@
+package trace
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracesRepository_CheckReplay(t *testing.T) {
+	repo := NewTracesRepository(10, 0)
+
+	assert.Equal(t, Replay{}, repo.CheckReplay("user1", 1, "a"))
+	assert.Equal(t, Replay{}, repo.CheckReplay("user1", 2, "b"))
+
+	// Replayed report
+	assert.Equal(t, Replay{Duplicate: true, DuplicateReports: 1}, repo.CheckReplay("user1", 2, "b"))
+	// Replayed nonce with a fresh sequence number
+	assert.Equal(t, Replay{Duplicate: true, DuplicateReports: 2}, repo.CheckReplay("user1", 3, "a"))
+	// Repeated sequence number with a fresh nonce is only out of order
+	assert.Equal(t, Replay{OutOfOrder: true, DuplicateReports: 2, OutOfOrderReports: 1}, repo.CheckReplay("user1", 1, "c"))
+
+	// Skipped reports 3 and 4
+	assert.Equal(t, Replay{SequenceGaps: 2, DuplicateReports: 2, OutOfOrderReports: 1}, repo.CheckReplay("user1", 5, "d"))
+	// Late report fills a gap
+	assert.Equal(t, Replay{OutOfOrder: true, SequenceGaps: 1, DuplicateReports: 2, OutOfOrderReports: 2}, repo.CheckReplay("user1", 3, "e"))
+	// Repeated late report doesn't fill another gap
+	assert.Equal(t, Replay{OutOfOrder: true, SequenceGaps: 1, DuplicateReports: 2, OutOfOrderReports: 3}, repo.CheckReplay("user1", 3, "f"))
+
+	// Unsequenced report keeps the counters
+	assert.Equal(t, Replay{Unsequenced: true, SequenceGaps: 1, DuplicateReports: 2, OutOfOrderReports: 3}, repo.CheckReplay("user1", 0, "g"))
+	assert.Equal(t, Replay{Unsequenced: true}, repo.CheckReplay("user2", 1, ""))
+
+	// First report of a session starting at a later sequence number
+	assert.Equal(t, Replay{SequenceGaps: 9}, repo.CheckReplay("user3", 10, "a"))
+}
+
+func TestTracesRepository_CheckReplayWindow(t *testing.T) {
+	repo := NewTracesRepository(10, 0)
+	for seq := int64(1); seq <= replayWindowSize+1; seq++ {
+		repo.CheckReplay("user1", seq, fmt.Sprint("nonce-", seq))
+	}
+
+	assert.True(t, repo.CheckReplay("user1", 2, "nonce-2").Duplicate)
+	replay := repo.CheckReplay("user1", 1, "nonce-1")
+	assert.False(t, replay.Duplicate, "reports out of the window are forgotten")
+	assert.True(t, replay.OutOfOrder, "sequence regression out of the window is still reported")
+}
+
+func TestTracesRepository_DeleteReplays(t *testing.T) {
+	repo := NewTracesRepository(10, 0)
+	repo.CheckReplay("user1", 1, "a")
+	repo.Append("user1", Trace{"MouseMoves": 1})
+
+	assert.True(t, repo.Delete("user1"))
+	assert.False(t, repo.CheckReplay("user1", 1, "a").Duplicate, "replay state should be removed with the session")
+}
@@ -1,14 +1,20 @@
 package trace
 
 import (
+	"bean/internal/metrics"
 	"bean/internal/utils"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // TracesRepository — a thread-safe storage for traces with automatic cleanup of outdated records.
 // For each identifier (id), a fixed-size ring buffer is maintained.
 // Traces that have not been updated longer than the specified TTL are deleted by a background process.
+// Each identifier has a version counter incremented on every appended trace, which allows
+// consumers to detect changes without comparing traces.
+// Registered annotators are applied to each trace on append, and their results
+// are stored alongside the trace in the ring buffer.
 //
 // Example usage:
 //
@@ -16,36 +22,60 @@ import (
 // go repo.Serve() // start background cleanup
 // repo.Append("user-123", trace.Trace{"MouseMoves": 5})
 type TracesRepository struct {
-	length        int                                  // maximum number of traces per identifier
-	ttl           time.Duration                        // trace lifetime; after this it is considered outdated
-	traces        map[string]*utils.RingBuffer[Trace] // trace storage by ID
-	tracesUpdates map[string]time.Time                // last update time for each ID
-	cleanTicker   *time.Ticker                         // ticker for periodic cleanup
-	tracesMu      sync.RWMutex                         // mutex to protect access to maps
+	length         int                                 // maximum number of traces per identifier
+	ttl            time.Duration                       // trace lifetime; after this it is considered outdated
+	traces         map[string]*utils.RingBuffer[Entry] // trace storage by ID
+	tracesCreated  map[string]time.Time                // creation time for each ID; the ttl is counted from it
+	tracesUpdates  map[string]*atomic.Int64            // last update time (Unix nanoseconds) for each ID
+	tracesVersions map[string]*atomic.Uint64           // number of appended traces for each ID
+	replays        map[string]*replayWindow            // recent reports for each ID checked for replays
+	evictHandlers  []func(id string)                   // handlers called after outdated IDs are removed
+	annotators     map[string]Annotator                // annotators applied to appended traces by name
+	cleanTicker    *time.Ticker                        // ticker for periodic cleanup
+	tracesMu       sync.RWMutex                        // mutex to protect access to maps
 }
 
 // Append adds trace t to the buffer associated with the specified identifier id.
 // If there is no buffer for the given id, it is created automatically.
-// The last update time for id is updated when creating or on first addition.
+// The creation time for id, which the ttl is counted from, is set when the buffer is created;
+// the last update time reported by Sessions is updated on every addition.
+// The version of id is incremented after the trace is added.
+// Registered annotators are applied to the trace before it is added.
 // The method is thread-safe.
 func (tr *TracesRepository) Append(id string, t Trace) {
 	tr.tracesMu.RLock()
 	buffer, found := tr.traces[id]
+	version := tr.tracesVersions[id]
+	updated := tr.tracesUpdates[id]
+	annotators := tr.annotators
 	tr.tracesMu.RUnlock()
 
+	entry := Entry{Trace: t}
+	if len(annotators) > 0 {
+		entry.Annotations = make(map[string]any, len(annotators))
+		for name, annotator := range annotators {
+			entry.Annotations[name] = annotator.Annotate(t)
+		}
+	}
+
 	if !found {
 		tr.tracesMu.Lock()
 		// Double-checked locking
 		if buffer, found = tr.traces[id]; !found {
-			buffer = utils.NewRingBuffer[Trace](tr.length)
+			buffer = utils.NewRingBuffer[Entry](tr.length)
 			tr.traces[id] = buffer
-			// Update last update time
-			tr.tracesUpdates[id] = time.Now()
+			tr.tracesVersions[id] = &atomic.Uint64{}
+			tr.tracesUpdates[id] = &atomic.Int64{}
+			tr.tracesCreated[id] = time.Now()
 		}
+		version = tr.tracesVersions[id]
+		updated = tr.tracesUpdates[id]
 		tr.tracesMu.Unlock()
 	}
 
-	buffer.Push(t)
+	buffer.Push(entry)
+	updated.Store(time.Now().UnixNano())
+	version.Add(1)
 }
 
 // Get returns a copy of all traces for the specified identifier id in order from old to new.
@@ -60,11 +90,173 @@ func (tr *TracesRepository) Get(id string) ([]Trace, bool) {
 		return nil, false
 	}
 
-	return buffer.ToSlice(), true
+	return tracesOf(buffer.ToSlice()), true
+}
+
+// Last returns the newest trace for the specified identifier id.
+// If traces for the given id are missing, returns (nil, false).
+// The method is thread-safe.
+func (tr *TracesRepository) Last(id string) (Trace, bool) {
+	tr.tracesMu.RLock()
+	defer tr.tracesMu.RUnlock()
+
+	buffer, found := tr.traces[id]
+	if !found || buffer.Len() == 0 {
+		return nil, false
+	}
+
+	return buffer.At(buffer.Len() - 1).Trace, true
+}
+
+// GetVersioned returns a copy of all traces for the specified identifier id
+// together with the version of id. The version is read before the traces are copied,
+// so the traces are at least as new as the version.
+// If traces for the given id are missing, returns (nil, 0, false).
+// The method is thread-safe.
+func (tr *TracesRepository) GetVersioned(id string) ([]Trace, uint64, bool) {
+	tr.tracesMu.RLock()
+	defer tr.tracesMu.RUnlock()
+
+	buffer, found := tr.traces[id]
+	if !found {
+		return nil, 0, false
+	}
+
+	version := tr.tracesVersions[id].Load()
+	return tracesOf(buffer.ToSlice()), version, true
+}
+
+// GetEntries works like GetVersioned but returns the traces together with their annotations.
+// The method is thread-safe.
+func (tr *TracesRepository) GetEntries(id string) ([]Entry, uint64, bool) {
+	tr.tracesMu.RLock()
+	defer tr.tracesMu.RUnlock()
+
+	buffer, found := tr.traces[id]
+	if !found {
+		return nil, 0, false
+	}
+
+	version := tr.tracesVersions[id].Load()
+	return buffer.ToSlice(), version, true
+}
+
+// AddAnnotator registers an annotator applied to every trace appended after the call.
+// Traces appended before the registration have no annotation of this annotator.
+// The method is thread-safe.
+func (tr *TracesRepository) AddAnnotator(name string, annotator Annotator) {
+	tr.tracesMu.Lock()
+	defer tr.tracesMu.Unlock()
+
+	// Copy on write: Append reads the map without holding the lock
+	annotators := make(map[string]Annotator, len(tr.annotators)+1)
+	for n, a := range tr.annotators {
+		annotators[n] = a
+	}
+	annotators[name] = annotator
+	tr.annotators = annotators
+}
+
+// tracesOf extracts traces from the entries.
+func tracesOf(entries []Entry) []Trace {
+	traces := make([]Trace, len(entries))
+	for i := range entries {
+		traces[i] = entries[i].Trace
+	}
+	return traces
+}
+
+// Version returns the number of traces appended for the specified identifier id.
+// If traces for the given id are missing, returns (0, false).
+// The method is thread-safe.
+func (tr *TracesRepository) Version(id string) (uint64, bool) {
+	tr.tracesMu.RLock()
+	defer tr.tracesMu.RUnlock()
+
+	version, found := tr.tracesVersions[id]
+	if !found {
+		return 0, false
+	}
+
+	return version.Load(), true
+}
+
+// Session describes the stored traces of an identifier.
+type Session struct {
+	// Id — session identifier.
+	Id string
+	// LastUpdate — time the last trace was appended.
+	LastUpdate time.Time
+	// Traces — number of stored traces.
+	Traces int
+	// Version — number of appended traces.
+	Version uint64
+}
+
+// Sessions returns a snapshot of all stored identifiers in no particular order.
+// The method is thread-safe.
+func (tr *TracesRepository) Sessions() []Session {
+	tr.tracesMu.RLock()
+	defer tr.tracesMu.RUnlock()
+
+	sessions := make([]Session, 0, len(tr.traces))
+	for id, buffer := range tr.traces {
+		sessions = append(sessions, Session{
+			Id:         id,
+			LastUpdate: time.Unix(0, tr.tracesUpdates[id].Load()),
+			Traces:     buffer.Len(),
+			Version:    tr.tracesVersions[id].Load(),
+		})
+	}
+
+	return sessions
+}
+
+// Delete removes all traces of the specified identifier id and calls the evict handlers,
+// so data derived from the traces is removed as well.
+// Returns false if traces for the given id are missing.
+// The method is thread-safe.
+func (tr *TracesRepository) Delete(id string) bool {
+	tr.tracesMu.Lock()
+	if _, found := tr.traces[id]; !found {
+		tr.tracesMu.Unlock()
+		return false
+	}
+	delete(tr.traces, id)
+	delete(tr.tracesCreated, id)
+	delete(tr.tracesUpdates, id)
+	delete(tr.tracesVersions, id)
+	delete(tr.replays, id)
+	handlers := tr.evictHandlers
+	tr.tracesMu.Unlock()
+
+	for _, handler := range handlers {
+		handler(id)
+	}
+	return true
+}
+
+// Len returns the number of stored identifiers.
+// The method is thread-safe.
+func (tr *TracesRepository) Len() int {
+	tr.tracesMu.RLock()
+	defer tr.tracesMu.RUnlock()
+
+	return len(tr.traces)
+}
+
+// OnEvict registers a handler called with the ID of each record removed as outdated or deleted.
+// Handlers are called from the cleanup goroutine and must not block.
+// Should be called before Serve.
+func (tr *TracesRepository) OnEvict(handler func(id string)) {
+	tr.tracesMu.Lock()
+	defer tr.tracesMu.Unlock()
+
+	tr.evictHandlers = append(tr.evictHandlers, handler)
 }
 
 // Serve starts a background goroutine that periodically (once a minute) checks
-// and removes outdated traces — those where more than ttl has passed since the last update.
+// and removes outdated traces — those where more than ttl has passed since their creation.
 // The method blocks execution and should be called in a separate goroutine:
 //
 // go repo.Serve()
@@ -78,8 +270,8 @@ func (tr *TracesRepository) Serve() {
 		// Collect list of outdated IDs under read lock
 		tr.tracesMu.RLock()
 		now := time.Now()
-		for id, ts := range tr.tracesUpdates {
-			if now.Sub(ts) > tr.ttl {
+		for id, created := range tr.tracesCreated {
+			if now.Sub(created) > tr.ttl {
 				outdated = append(outdated, id)
 			}
 		}
@@ -88,11 +280,22 @@ func (tr *TracesRepository) Serve() {
 		// Delete outdated records under write lock
 		if len(outdated) > 0 {
 			tr.tracesMu.Lock()
+			metrics.SessionsEvicted.Add(float64(len(outdated)))
 			for _, id := range outdated {
 				delete(tr.traces, id)
+				delete(tr.tracesCreated, id)
 				delete(tr.tracesUpdates, id)
+				delete(tr.tracesVersions, id)
+				delete(tr.replays, id)
 			}
+			handlers := tr.evictHandlers
 			tr.tracesMu.Unlock()
+
+			for _, id := range outdated {
+				for _, handler := range handlers {
+					handler(id)
+				}
+			}
 		}
 	}
 }
@@ -115,10 +318,13 @@ func (tr *TracesRepository) Stop() {
 // To start automatic cleanup, call Serve in a separate goroutine.
 func NewTracesRepository(length int, ttl time.Duration) *TracesRepository {
 	repo := TracesRepository{
-		length:        length,
-		ttl:           ttl,
-		traces:        make(map[string]*utils.RingBuffer[Trace]),
-		tracesUpdates: make(map[string]time.Time),
+		length:         length,
+		ttl:            ttl,
+		traces:         make(map[string]*utils.RingBuffer[Entry]),
+		tracesCreated:  make(map[string]time.Time),
+		tracesUpdates:  make(map[string]*atomic.Int64),
+		tracesVersions: make(map[string]*atomic.Uint64),
+		replays:        make(map[string]*replayWindow),
 	}
 
 	return &repo
@@ -68,6 +68,21 @@ func TestTracesRepository_Get(t *testing.T) {
 	assert.False(t, ok, "expected Get to return false for non-existent ID")
 }
 
+func TestTracesRepository_Last(t *testing.T) {
+	repo := NewTracesRepository(2, 0)
+
+	_, ok := repo.Last("user1")
+	assert.False(t, ok, "expected Last to return false for non-existent ID")
+
+	for i := 1; i <= 3; i++ {
+		repo.Append("user1", Trace{"MouseMoves": i})
+	}
+
+	last, ok := repo.Last("user1")
+	assert.True(t, ok)
+	assert.Equal(t, Trace{"MouseMoves": 3}, last, "the newest trace should be returned after wrap-around")
+}
+
 // TestTracesRepository_ConcurrentAppend verifies Append thread-safety
 func TestTracesRepository_ConcurrentAppend(t *testing.T) {
 	repo := NewTracesRepository(100, 0)
@@ -157,3 +172,62 @@ func TestTracesRepository_MultipleIDs(t *testing.T) {
 	assert.Equal(t, expected1, traces1, "user1 traces should match")
 	assert.Equal(t, expected2, traces2, "user2 traces should match")
 }
+
+// TestTracesRepository_Version verifies that the version is incremented on every append
+func TestTracesRepository_Version(t *testing.T) {
+	repo := NewTracesRepository(2, 0)
+
+	_, ok := repo.Version("user1")
+	assert.False(t, ok, "expected no version for non-existent ID")
+
+	repo.Append("user1", Trace{"MouseMoves": 1})
+	repo.Append("user1", Trace{"MouseMoves": 2})
+	repo.Append("user1", Trace{"MouseMoves": 3}) // displaces the first trace
+
+	version, ok := repo.Version("user1")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(3), version, "version should count all appended traces")
+
+	traces, version, ok := repo.GetVersioned("user1")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(3), version)
+	assert.Len(t, traces, 2)
+}
+
+// TestTracesRepository_Sessions verifies that the last update time follows every append
+func TestTracesRepository_Sessions(t *testing.T) {
+	repo := NewTracesRepository(2, 0)
+
+	repo.Append("user1", Trace{"MouseMoves": 1})
+	first := repo.Sessions()[0].LastUpdate
+	time.Sleep(time.Millisecond)
+	repo.Append("user1", Trace{"MouseMoves": 2})
+	repo.Append("user1", Trace{"MouseMoves": 3})
+
+	sessions := repo.Sessions()
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, "user1", sessions[0].Id)
+	assert.Equal(t, 2, sessions[0].Traces, "traces should be limited by the buffer length")
+	assert.Equal(t, uint64(3), sessions[0].Version)
+	assert.True(t, sessions[0].LastUpdate.After(first), "last update should move on every append")
+}
+
+// TestTracesRepository_Delete verifies that deleted IDs are removed and evict handlers are called
+func TestTracesRepository_Delete(t *testing.T) {
+	repo := NewTracesRepository(2, 0)
+	var evicted []string
+	repo.OnEvict(func(id string) { evicted = append(evicted, id) })
+
+	repo.Append("user1", Trace{"MouseMoves": 1})
+	repo.Append("user2", Trace{"MouseMoves": 2})
+
+	assert.True(t, repo.Delete("user1"))
+	assert.False(t, repo.Delete("user1"), "expected false for already deleted ID")
+	assert.Equal(t, []string{"user1"}, evicted)
+
+	_, ok := repo.Get("user1")
+	assert.False(t, ok)
+	_, ok = repo.Version("user1")
+	assert.False(t, ok)
+	assert.Equal(t, 1, repo.Len())
+}
@@ -1,3 +1,16 @@
 package trace
 
 type Trace map[string]any
+
+// Annotator computes data attached to a trace when it is appended to the repository.
+// Annotations are stored alongside the trace and evicted together with it.
+// Implementations must be safe for concurrent use.
+type Annotator interface {
+	Annotate(t Trace) any
+}
+
+// Entry is a stored trace together with its annotations by annotator name.
+type Entry struct {
+	Trace       Trace
+	Annotations map[string]any
+}
@@ -0,0 +1,172 @@
+package trajectory
+
+import (
+	"errors"
+	"math"
+)
+
+// Kinds of raw events.
+const (
+	// KindMove — the pointer moved.
+	KindMove = 0
+	// KindDown — a mouse button was pressed, a touch started or a key was pressed.
+	KindDown = 1
+	// KindUp — a mouse button was released, a touch ended or a key was released.
+	KindUp = 2
+)
+
+// Bounds of the decoded events. Positions are viewport coordinates and times are within a report,
+// so larger values are forged; they are rejected before the features overflow.
+const (
+	maxPosition = 1e5             // maximum absolute position, px
+	maxTime     = 24 * 3600 * 1e3 // maximum time since the start of the report, ms (a day)
+)
+
+// Sizes of the encoded events.
+const (
+	pointStride = 4 // [kind, dt, dx, dy]
+	keyStride   = 2 // [kind, dt]
+)
+
+// ErrInvalidEvents is returned for raw events not encoded by the collector.
+var ErrInvalidEvents = errors.New("invalid raw events")
+
+// Point is a pointer event.
+type Point struct {
+	// Kind — kind of the event: KindMove, KindDown or KindUp.
+	Kind int
+	// T — time of the event since the start of the report, in milliseconds.
+	T float64
+	// X, Y — position of the pointer in the viewport, in pixels.
+	X, Y float64
+}
+
+// KeyEvent is a keyboard event. Key values are not collected.
+type KeyEvent struct {
+	// Kind — kind of the event: KindDown or KindUp.
+	Kind int
+	// T — time of the event since the start of the report, in milliseconds.
+	T float64
+}
+
+// Events are the raw events of a report.
+type Events struct {
+	// Mouse — mouse moves and button presses.
+	Mouse []Point
+	// Touch — touch moves, starts and ends of the primary touch.
+	Touch []Point
+	// Keys — key presses and releases.
+	Keys []KeyEvent
+	// Truncated — the collector or the server dropped events above the limit.
+	Truncated bool
+}
+
+// Decode decodes the raw events of a trace sent by the collector:
+//
+//	{"mouse": [kind, dt, dx, dy, …], "touch": [kind, dt, dx, dy, …], "keys": [kind, dt, …], "truncated": false}
+//
+// Times and positions are deltas from the previous event of the stream; the first event
+// of a stream is relative to the start of the report and to the viewport origin.
+// Streams are limited to maxEvents events, the rest is dropped and marked as truncated;
+// zero means no limit. Returns ErrInvalidEvents if the events are malformed or the decoded
+// positions and times are out of bounds.
+func Decode(raw any, maxEvents int) (Events, error) {
+	fields, ok := raw.(map[string]any)
+	if !ok {
+		return Events{}, ErrInvalidEvents
+	}
+
+	var events Events
+	var err error
+	events.Truncated, _ = fields["truncated"].(bool)
+	var truncated [3]bool
+	if events.Mouse, truncated[0], err = decodePoints(fields["mouse"], maxEvents); err != nil {
+		return Events{}, err
+	}
+	if events.Touch, truncated[1], err = decodePoints(fields["touch"], maxEvents); err != nil {
+		return Events{}, err
+	}
+	if events.Keys, truncated[2], err = decodeKeys(fields["keys"], maxEvents); err != nil {
+		return Events{}, err
+	}
+	events.Truncated = events.Truncated || truncated[0] || truncated[1] || truncated[2]
+	return events, nil
+}
+
+// decodePoints decodes a delta-encoded pointer stream.
+func decodePoints(raw any, maxEvents int) ([]Point, bool, error) {
+	values, truncated, err := numbers(raw, pointStride, maxEvents)
+	if err != nil {
+		return nil, false, err
+	}
+
+	points := make([]Point, 0, len(values)/pointStride)
+	var last Point
+	for i := 0; i < len(values); i += pointStride {
+		kind, dt := values[i], values[i+1]
+		if !validKind(kind) || dt < 0 {
+			return nil, false, ErrInvalidEvents
+		}
+		last = Point{Kind: int(kind), T: last.T + dt, X: last.X + values[i+2], Y: last.Y + values[i+3]}
+		if last.T > maxTime || math.Abs(last.X) > maxPosition || math.Abs(last.Y) > maxPosition {
+			return nil, false, ErrInvalidEvents
+		}
+		points = append(points, last)
+	}
+	return points, truncated, nil
+}
+
+// decodeKeys decodes a delta-encoded key stream.
+func decodeKeys(raw any, maxEvents int) ([]KeyEvent, bool, error) {
+	values, truncated, err := numbers(raw, keyStride, maxEvents)
+	if err != nil {
+		return nil, false, err
+	}
+
+	keys := make([]KeyEvent, 0, len(values)/keyStride)
+	var last KeyEvent
+	for i := 0; i < len(values); i += keyStride {
+		kind, dt := values[i], values[i+1]
+		if (kind != KindDown && kind != KindUp) || dt < 0 {
+			return nil, false, ErrInvalidEvents
+		}
+		last = KeyEvent{Kind: int(kind), T: last.T + dt}
+		if last.T > maxTime {
+			return nil, false, ErrInvalidEvents
+		}
+		keys = append(keys, last)
+	}
+	return keys, truncated, nil
+}
+
+// numbers returns the numbers of a JSON array of events with the given stride,
+// limited to maxEvents events. A missing stream is empty.
+func numbers(raw any, stride int, maxEvents int) ([]float64, bool, error) {
+	if raw == nil {
+		return nil, false, nil
+	}
+	array, ok := raw.([]any)
+	if !ok || len(array)%stride != 0 {
+		return nil, false, ErrInvalidEvents
+	}
+
+	truncated := false
+	if maxEvents > 0 && len(array) > maxEvents*stride {
+		array = array[:maxEvents*stride]
+		truncated = true
+	}
+	values := make([]float64, len(array))
+	for i, value := range array {
+		number, ok := value.(float64)
+		if !ok || math.IsNaN(number) || math.IsInf(number, 0) {
+			return nil, false, ErrInvalidEvents
+		}
+		values[i] = number
+	}
+	return values, truncated, nil
+}
+
+// validKind reports whether the number is a kind of a pointer event.
+func validKind(kind float64) bool {
+	return kind == KindMove || kind == KindDown || kind == KindUp
+}
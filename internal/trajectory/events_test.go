@@ -0,0 +1,61 @@
+package trajectory
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parse decodes the JSON events as the trace handler receives them.
+func parse(t *testing.T, payload string) any {
+	var raw any
+	require.NoError(t, json.Unmarshal([]byte(payload), &raw))
+	return raw
+}
+
+func TestDecode(t *testing.T) {
+	raw := parse(t, `{"mouse": [0, 10, 100, 200, 0, 16, 5, -3, 1, 4, 0, 0], "touch": [], "keys": [1, 50, 2, 80]}`)
+	events, err := Decode(raw, 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Point{
+		{Kind: KindMove, T: 10, X: 100, Y: 200},
+		{Kind: KindMove, T: 26, X: 105, Y: 197},
+		{Kind: KindDown, T: 30, X: 105, Y: 197},
+	}, events.Mouse)
+	assert.Empty(t, events.Touch)
+	assert.Equal(t, []KeyEvent{{Kind: KindDown, T: 50}, {Kind: KindUp, T: 130}}, events.Keys)
+	assert.False(t, events.Truncated)
+}
+
+func TestDecode_Truncated(t *testing.T) {
+	events, err := Decode(parse(t, `{"mouse": [0, 1, 1, 1, 0, 1, 1, 1, 0, 1, 1, 1]}`), 2)
+	require.NoError(t, err)
+	assert.Len(t, events.Mouse, 2)
+	assert.True(t, events.Truncated, "events above the limit should be dropped")
+
+	events, err = Decode(parse(t, `{"keys": [1, 1], "truncated": true}`), 2)
+	require.NoError(t, err)
+	assert.True(t, events.Truncated, "truncation by the collector should be kept")
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	for _, payload := range []string{
+		`[0, 1, 1, 1]`,
+		`{"mouse": [0, 1, 1]}`,
+		`{"mouse": [0, -1, 1, 1]}`,
+		`{"mouse": [3, 1, 1, 1]}`,
+		`{"mouse": [0, "1", 1, 1]}`,
+		`{"keys": [0, 1]}`,
+		`{"touch": {"x": 1}}`,
+		`{"mouse": [0, 10, 1e308, 0, 0, 10, 1e308, 0, 0, 10, 1e308, 0]}`,
+		`{"mouse": [0, 10, 60000, 0, 0, 10, 60000, 0]}`,
+		`{"touch": [0, 1e308, 0, 0, 0, 1e308, 0, 0]}`,
+		`{"keys": [1, 1e308, 2, 1e308]}`,
+	} {
+		_, err := Decode(parse(t, payload), 0)
+		assert.ErrorIs(t, err, ErrInvalidEvents, payload)
+	}
+}
@@ -0,0 +1,336 @@
+package trajectory
+
+import (
+	"bean/internal/feature"
+	"math"
+)
+
+// pauseThreshold is the minimum time without pointer events counted as a pause, in milliseconds.
+const pauseThreshold = 100
+
+// overshootThreshold is the minimum distance the pointer passes beyond a press counted
+// as an overshoot, in pixels. Smaller distances are jitter of the hand and rounding.
+const overshootThreshold = 2
+
+// angleBins is the number of bins of the direction change histogram.
+const angleBins = 8
+
+// Features are the kinematic features of the raw events of a report.
+// The pointer features are computed from the mouse stream, or from the touch stream
+// if it has more moves (touch devices).
+type Features struct {
+	// MouseEvents, TouchEvents, KeyEvents — number of events of each stream.
+	MouseEvents, TouchEvents, KeyEvents int
+	// Truncated — events above the limit were dropped.
+	Truncated bool
+	// PathLength — length of the pointer path, in pixels.
+	PathLength float64
+	// Straightness — ratio of the straight-line distance to the path length of the strokes
+	// between presses and releases, 1 for straight strokes; zero without movement.
+	Straightness float64
+	// VelocityMean, VelocityStd — mean and standard deviation of the pointer velocity, in pixels per second.
+	VelocityMean, VelocityStd float64
+	// CurvatureMean — mean change of the movement direction per pixel, in radians per pixel.
+	CurvatureMean float64
+	// AccelerationMean, AccelerationStd — mean and standard deviation of the absolute pointer acceleration,
+	// in pixels per second squared.
+	AccelerationMean, AccelerationStd float64
+	// JerkMean — mean absolute change of the acceleration, in pixels per second cubed.
+	JerkMean float64
+	// AngleEntropy — Shannon entropy of the direction changes between segments, in bits:
+	// 0 for straight movements, up to 3 for erratic ones.
+	AngleEntropy float64
+	// PathEfficiency — mean ratio of the straight-line distance to the path length of the movements
+	// ending at a press; 1 for movements straight to the target, zero without such movements.
+	PathEfficiency float64
+	// Pauses — number of stops of the pointer longer than 100 ms during movements.
+	Pauses int
+	// Overshoots — number of movements ending at a press that passed beyond the press point.
+	Overshoots int
+	// OvershootMean — mean distance the movements ending at a press passed beyond the press point, in pixels.
+	OvershootMean float64
+}
+
+// segment is a pointer movement between two consecutive events.
+type segment struct {
+	dx, dy float64 // displacement, px
+	t, dt  float64 // time of the end and duration, ms
+	stroke int     // index of the stroke the segment belongs to
+	held   bool    // the segment starts at a press (a button or a finger is held)
+}
+
+// sample is a value of a kinematic quantity (velocity, acceleration, jerk) at a time of a stroke.
+type sample struct {
+	value  float64 // value of the quantity, per second
+	t      float64 // time, ms
+	stroke int     // index of the stroke
+}
+
+// length returns the length of the segment.
+func (s segment) length() float64 {
+	return math.Hypot(s.dx, s.dy)
+}
+
+// Extract computes the features of the events.
+func Extract(events Events) Features {
+	features := Features{
+		MouseEvents: len(events.Mouse),
+		TouchEvents: len(events.Touch),
+		KeyEvents:   len(events.Keys),
+		Truncated:   events.Truncated,
+	}
+
+	pointer := events.Mouse
+	if moves(events.Touch) > moves(events.Mouse) {
+		pointer = events.Touch
+	}
+	segments := segments(pointer)
+	if len(segments) == 0 {
+		return features
+	}
+
+	features.PathLength, features.Straightness = straightness(segments)
+	velocities := velocity(segments)
+	accelerations := derivative(velocities)
+	features.VelocityMean, features.VelocityStd = feature.MeanStd(values(velocities))
+	features.AccelerationMean, features.AccelerationStd = feature.MeanStd(absolute(accelerations))
+	features.JerkMean, _ = feature.MeanStd(absolute(derivative(accelerations)))
+	features.CurvatureMean = curvature(segments)
+	features.AngleEntropy = angleEntropy(segments)
+	features.Pauses = pauses(segments)
+	features.PathEfficiency, features.Overshoots, features.OvershootMean = aimedMovements(pointer)
+	return features
+}
+
+// moves returns the number of move events of the stream.
+func moves(points []Point) int {
+	count := 0
+	for _, p := range points {
+		if p.Kind == KindMove {
+			count++
+		}
+	}
+	return count
+}
+
+// segments splits the pointer path into segments between consecutive events.
+// A stroke ends at every press and release; the jump from a release to the next press
+// (a lifted finger) is not a movement.
+func segments(points []Point) []segment {
+	result := []segment{}
+	stroke := 0
+	for i := 1; i < len(points); i++ {
+		from, to := points[i-1], points[i]
+		if from.Kind != KindMove {
+			stroke++
+		}
+		if from.Kind == KindUp && to.Kind == KindDown {
+			continue
+		}
+		result = append(result, segment{
+			dx: to.X - from.X, dy: to.Y - from.Y,
+			t: to.T, dt: to.T - from.T,
+			stroke: stroke, held: from.Kind == KindDown,
+		})
+	}
+	return result
+}
+
+// straightness returns the path length and the ratio of the straight-line distance of the strokes
+// to their length.
+func straightness(segments []segment) (float64, float64) {
+	var length, chords float64
+	var dx, dy float64
+	for i, s := range segments {
+		length += s.length()
+		dx += s.dx
+		dy += s.dy
+		if i == len(segments)-1 || segments[i+1].stroke != s.stroke {
+			chords += math.Hypot(dx, dy)
+			dx, dy = 0, 0
+		}
+	}
+	if length == 0 {
+		return 0, 0
+	}
+	return length, chords / length
+}
+
+// velocity returns the velocities of the segments in pixels per second, at the middle of the segments.
+// Segments of simultaneous events are skipped.
+func velocity(segments []segment) []sample {
+	velocities := []sample{}
+	for _, s := range segments {
+		if s.dt > 0 {
+			velocities = append(velocities, sample{value: s.length() / s.dt * 1000, t: s.t - s.dt/2, stroke: s.stroke})
+		}
+	}
+	return velocities
+}
+
+// derivative returns the rates of change of consecutive samples of a stroke, per second,
+// at the middle between the samples.
+func derivative(samples []sample) []sample {
+	result := []sample{}
+	for i := 1; i < len(samples); i++ {
+		a, b := samples[i-1], samples[i]
+		if a.stroke != b.stroke || b.t <= a.t {
+			continue
+		}
+		result = append(result, sample{value: (b.value - a.value) / (b.t - a.t) * 1000, t: (a.t + b.t) / 2, stroke: b.stroke})
+	}
+	return result
+}
+
+// values returns the values of the samples.
+func values(samples []sample) []float64 {
+	result := make([]float64, len(samples))
+	for i, s := range samples {
+		result[i] = s.value
+	}
+	return result
+}
+
+// absolute returns the absolute values of the samples.
+func absolute(samples []sample) []float64 {
+	result := make([]float64, len(samples))
+	for i, s := range samples {
+		result[i] = math.Abs(s.value)
+	}
+	return result
+}
+
+// turn is a direction change between consecutive moving segments of a stroke.
+type turn struct {
+	angle  float64 // direction change, rad in [-π, π]
+	length float64 // mean length of the segments, px
+}
+
+// turns returns the direction changes between consecutive moving segments of a stroke.
+func turns(segments []segment) []turn {
+	result := []turn{}
+	for i := 1; i < len(segments); i++ {
+		a, b := segments[i-1], segments[i]
+		if a.stroke != b.stroke || a.length() == 0 || b.length() == 0 {
+			continue
+		}
+		result = append(result, turn{
+			angle:  angleDiff(math.Atan2(a.dy, a.dx), math.Atan2(b.dy, b.dx)),
+			length: (a.length() + b.length()) / 2,
+		})
+	}
+	return result
+}
+
+// curvature returns the mean absolute direction change between consecutive moving segments
+// of a stroke, divided by their mean length.
+func curvature(segments []segment) float64 {
+	turns := turns(segments)
+	if len(turns) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, t := range turns {
+		sum += math.Abs(t.angle) / t.length
+	}
+	return sum / float64(len(turns))
+}
+
+// angleEntropy returns the Shannon entropy of the histogram of the direction changes, in bits.
+// The changes are binned into angleBins equal bins over [-π, π].
+func angleEntropy(segments []segment) float64 {
+	turns := turns(segments)
+	if len(turns) == 0 {
+		return 0
+	}
+	var histogram [angleBins]int
+	for _, t := range turns {
+		bin := int((t.angle + math.Pi) / (2 * math.Pi) * angleBins)
+		histogram[min(max(bin, 0), angleBins-1)]++
+	}
+
+	return feature.Entropy(histogram[:])
+}
+
+// pauses returns the number of segments longer than pauseThreshold, i.e. the pointer stood still
+// before the next event. Segments starting at a press (holding a button or a finger) are not pauses.
+func pauses(segments []segment) int {
+	count := 0
+	for _, s := range segments {
+		if !s.held && s.dt > pauseThreshold {
+			count++
+		}
+	}
+	return count
+}
+
+// aimedMovements finds the movements ending at a press: the pointer path since the previous
+// press or release, or since the last pause. Returns the mean path efficiency of the movements,
+// the number of overshoots and the mean overshoot distance, in pixels.
+//
+// The overshoot of a movement is the largest distance the pointer passed beyond the press point
+// along the direction from the start of the movement to the press point.
+func aimedMovements(points []Point) (float64, int, float64) {
+	var efficiency, overshoot float64
+	movements, overshoots := 0, 0
+	start := 0
+	for i := 1; i < len(points); i++ {
+		p := points[i]
+		if p.Kind == KindMove {
+			if p.T-points[i-1].T > pauseThreshold {
+				start = i - 1
+			}
+			continue
+		}
+		// A press after at least one move ends an aimed movement.
+		if p.Kind == KindDown && i-start >= 2 {
+			if e, o, ok := aimedMovement(points[start : i+1]); ok {
+				efficiency += e
+				overshoot += o
+				movements++
+				if o >= overshootThreshold {
+					overshoots++
+				}
+			}
+		}
+		start = i
+	}
+	if movements == 0 {
+		return 0, 0, 0
+	}
+	return efficiency / float64(movements), overshoots, overshoot / float64(movements)
+}
+
+// aimedMovement returns the path efficiency and the overshoot of a movement ending at its last point.
+// Returns false for a movement without displacement.
+func aimedMovement(points []Point) (float64, float64, bool) {
+	from, to := points[0], points[len(points)-1]
+	distance := math.Hypot(to.X-from.X, to.Y-from.Y)
+	var length float64
+	for i := 1; i < len(points); i++ {
+		length += math.Hypot(points[i].X-points[i-1].X, points[i].Y-points[i-1].Y)
+	}
+	if distance == 0 || length == 0 {
+		return 0, 0, false
+	}
+
+	// Unit vector of the direction to the target.
+	ux, uy := (to.X-from.X)/distance, (to.Y-from.Y)/distance
+	var overshoot float64
+	for _, p := range points {
+		overshoot = max(overshoot, (p.X-to.X)*ux+(p.Y-to.Y)*uy)
+	}
+	return distance / length, overshoot, true
+}
+
+// angleDiff returns the difference of the angles wrapped to [-π, π].
+func angleDiff(a, b float64) float64 {
+	d := b - a
+	for d > math.Pi {
+		d -= 2 * math.Pi
+	}
+	for d < -math.Pi {
+		d += 2 * math.Pi
+	}
+	return d
+}
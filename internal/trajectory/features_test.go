@@ -0,0 +1,175 @@
+package trajectory
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// frame is the interval between the mouse moves sent by the collector, in milliseconds.
+const frame = 16
+
+// humanPath returns a synthetic human-like movement to the target: a minimum-jerk velocity profile
+// along an arc with hand tremor, an overshoot corrected back to the target, a pause and a press.
+func humanPath(tx, ty float64) []Point {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	points := []Point{}
+	steps := 40
+	for i := 0; i <= steps; i++ {
+		tau := float64(i) / float64(steps)
+		s := 1.08 * (10*math.Pow(tau, 3) - 15*math.Pow(tau, 4) + 6*math.Pow(tau, 5))
+		arc := 30 * math.Sin(math.Pi*tau)
+		points = append(points, Point{
+			Kind: KindMove,
+			T:    float64(i * frame),
+			X:    s*tx - arc*ty/math.Hypot(tx, ty) + rnd.NormFloat64(),
+			Y:    s*ty + arc*tx/math.Hypot(tx, ty) + rnd.NormFloat64(),
+		})
+	}
+	last := points[len(points)-1]
+	for i := 1; i <= 6; i++ {
+		k := float64(i) / 6
+		points = append(points, Point{Kind: KindMove, T: last.T + float64(i*frame), X: last.X + (tx-last.X)*k, Y: last.Y + (ty-last.Y)*k})
+	}
+	last = points[len(points)-1]
+	return append(points, Point{Kind: KindDown, T: last.T + 180, X: tx, Y: ty}, Point{Kind: KindUp, T: last.T + 260, X: tx, Y: ty})
+}
+
+// botPath returns a synthetic bot-like movement: a straight line to the target at a constant velocity
+// followed by an immediate press.
+func botPath(tx, ty float64) []Point {
+	points := []Point{}
+	steps := 30
+	for i := 0; i <= steps; i++ {
+		k := float64(i) / float64(steps)
+		points = append(points, Point{Kind: KindMove, T: float64(i * frame), X: tx * k, Y: ty * k})
+	}
+	last := points[len(points)-1]
+	return append(points, Point{Kind: KindDown, T: last.T, X: tx, Y: ty}, Point{Kind: KindUp, T: last.T + 50, X: tx, Y: ty})
+}
+
+func TestExtract_Line(t *testing.T) {
+	points := []Point{}
+	for i := range 11 {
+		points = append(points, Point{Kind: KindMove, T: float64(i * 10), X: float64(i * 10)})
+	}
+	points = append(points, Point{Kind: KindDown, T: 110, X: 100})
+
+	f := Extract(Events{Mouse: points, Keys: []KeyEvent{{Kind: KindDown}}})
+	assert.Equal(t, 12, f.MouseEvents)
+	assert.Equal(t, 1, f.KeyEvents)
+	assert.InDelta(t, 100, f.PathLength, 1e-9)
+	assert.InDelta(t, 1, f.Straightness, 1e-9)
+	assert.InDelta(t, 909.09, f.VelocityMean, 0.01, "10 segments of 1000 px/s and a zero one")
+	assert.Zero(t, f.CurvatureMean)
+}
+
+func TestExtract_Curve(t *testing.T) {
+	points := []Point{}
+	for i := range 9 {
+		angle := float64(i) * math.Pi / 8
+		points = append(points, Point{Kind: KindMove, T: float64(i * 16), X: 100 * math.Cos(angle), Y: 100 * math.Sin(angle)})
+	}
+
+	f := Extract(Events{Mouse: points})
+	assert.Less(t, f.Straightness, 0.65, "half circle is not straight")
+	assert.InDelta(t, 0.01, f.CurvatureMean, 0.001, "circle of radius 100 turns by 1/100 rad per px")
+	assert.InDelta(t, 0, f.VelocityStd, 1e-9, "uniform circular motion")
+}
+
+func TestExtract_Touch(t *testing.T) {
+	touch := []Point{
+		{Kind: KindDown, T: 0, X: 0, Y: 0},
+		{Kind: KindMove, T: 10, X: 0, Y: 10},
+		{Kind: KindUp, T: 20, X: 0, Y: 20},
+		{Kind: KindDown, T: 500, X: 300, Y: 300},
+		{Kind: KindMove, T: 510, X: 310, Y: 300},
+		{Kind: KindUp, T: 520, X: 320, Y: 300},
+	}
+
+	f := Extract(Events{Mouse: []Point{{Kind: KindMove}}, Touch: touch})
+	assert.InDelta(t, 40, f.PathLength, 1e-9, "jump between touches is not a movement")
+	assert.InDelta(t, 1, f.Straightness, 1e-9)
+	assert.InDelta(t, 1000, f.VelocityMean, 1e-9)
+
+	assert.Equal(t, Features{}, Extract(Events{}))
+}
+
+func TestExtract_Human(t *testing.T) {
+	f := Extract(Events{Mouse: humanPath(400, 200)})
+
+	assert.Greater(t, f.AccelerationMean, 1000.0)
+	assert.Greater(t, f.AccelerationStd, 1000.0)
+	assert.Greater(t, f.JerkMean, 10000.0)
+	assert.Greater(t, f.AngleEntropy, 1.0, "tremor and corrections change the direction")
+	assert.Greater(t, f.PathEfficiency, 0.8)
+	assert.Less(t, f.PathEfficiency, 0.99)
+	assert.Equal(t, 1, f.Pauses, "pause before the press")
+	assert.Equal(t, 1, f.Overshoots)
+	assert.InDelta(t, 0.08*math.Hypot(400, 200), f.OvershootMean, 3)
+}
+
+func TestExtract_Bot(t *testing.T) {
+	f := Extract(Events{Mouse: botPath(400, 200)})
+
+	assert.InDelta(t, 0, f.AccelerationMean, 1e-6, "constant velocity")
+	assert.InDelta(t, 0, f.JerkMean, 1e-6)
+	assert.Zero(t, f.AngleEntropy, "straight line")
+	assert.InDelta(t, 1, f.PathEfficiency, 1e-9)
+	assert.InDelta(t, 1, f.Straightness, 1e-9)
+	assert.Zero(t, f.Pauses)
+	assert.Zero(t, f.Overshoots)
+	assert.InDelta(t, 0, f.OvershootMean, 1e-9)
+}
+
+func TestExtract_AimedMovements(t *testing.T) {
+	points := []Point{
+		{Kind: KindMove, T: 0, X: 0, Y: 0},
+		{Kind: KindMove, T: 16, X: 50, Y: 0},
+		{Kind: KindMove, T: 500, X: 60, Y: 0},
+		{Kind: KindMove, T: 516, X: 110, Y: 0},
+		{Kind: KindMove, T: 532, X: 100, Y: 0},
+		{Kind: KindDown, T: 600, X: 100, Y: 0},
+	}
+
+	f := Extract(Events{Mouse: points})
+	assert.Equal(t, 1, f.Pauses)
+	assert.Equal(t, 1, f.Overshoots)
+	assert.InDelta(t, 10, f.OvershootMean, 1e-9, "movement starts after the pause at (50, 0)")
+	assert.InDelta(t, 50.0/70, f.PathEfficiency, 1e-9)
+}
+
+func TestFeatures_Set(t *testing.T) {
+	fields := map[string]any{}
+	Fields.Set(Extract(Events{Mouse: botPath(100, 0)}), fields)
+
+	require.Len(t, fields, len(Fields))
+	for _, field := range Fields {
+		switch field.Type.String() {
+		case "int":
+			assert.IsType(t, int64(0), fields[field.Name], field.Name)
+		case "bool":
+			assert.IsType(t, false, fields[field.Name], field.Name)
+		case "double":
+			assert.IsType(t, float64(0), fields[field.Name], field.Name)
+		default:
+			t.Errorf("unexpected type %s of %s", field.Type, field.Name)
+		}
+	}
+	assert.Equal(t, int64(33), fields["rawMouseEvents"])
+}
+
+func TestFeatures_Finite(t *testing.T) {
+	assert.True(t, Fields.Finite(Extract(Events{Mouse: humanPath(400, 200)})))
+
+	// Moves a fraction of a microsecond apart overflow the velocity and the acceleration.
+	f := Extract(Events{Mouse: []Point{
+		{Kind: KindMove, T: 0, X: 0},
+		{Kind: KindMove, T: 1e-310, X: 1000},
+		{Kind: KindMove, T: 2e-310, X: 0},
+	}})
+	assert.False(t, Fields.Finite(f))
+}
@@ -0,0 +1,28 @@
+package trajectory
+
+import (
+	"bean/internal/feature"
+
+	"github.com/google/cel-go/cel"
+)
+
+// Fields are the trace fields of the features.
+var Fields = feature.Fields[Features]{
+	{Name: "rawMouseEvents", Type: cel.IntType, Value: func(f Features) any { return int64(f.MouseEvents) }},
+	{Name: "rawTouchEvents", Type: cel.IntType, Value: func(f Features) any { return int64(f.TouchEvents) }},
+	{Name: "rawKeyEvents", Type: cel.IntType, Value: func(f Features) any { return int64(f.KeyEvents) }},
+	{Name: "rawEventsTruncated", Type: cel.BoolType, Value: func(f Features) any { return f.Truncated }},
+	{Name: "pathLength", Type: cel.DoubleType, Value: func(f Features) any { return f.PathLength }},
+	{Name: "pathStraightness", Type: cel.DoubleType, Value: func(f Features) any { return f.Straightness }},
+	{Name: "velocityMean", Type: cel.DoubleType, Value: func(f Features) any { return f.VelocityMean }},
+	{Name: "velocityStd", Type: cel.DoubleType, Value: func(f Features) any { return f.VelocityStd }},
+	{Name: "curvatureMean", Type: cel.DoubleType, Value: func(f Features) any { return f.CurvatureMean }},
+	{Name: "accelerationMean", Type: cel.DoubleType, Value: func(f Features) any { return f.AccelerationMean }},
+	{Name: "accelerationStd", Type: cel.DoubleType, Value: func(f Features) any { return f.AccelerationStd }},
+	{Name: "jerkMean", Type: cel.DoubleType, Value: func(f Features) any { return f.JerkMean }},
+	{Name: "angleEntropy", Type: cel.DoubleType, Value: func(f Features) any { return f.AngleEntropy }},
+	{Name: "pathEfficiency", Type: cel.DoubleType, Value: func(f Features) any { return f.PathEfficiency }},
+	{Name: "pointerPauses", Type: cel.IntType, Value: func(f Features) any { return int64(f.Pauses) }},
+	{Name: "clickOvershoots", Type: cel.IntType, Value: func(f Features) any { return int64(f.Overshoots) }},
+	{Name: "clickOvershootMean", Type: cel.DoubleType, Value: func(f Features) any { return f.OvershootMean }},
+}
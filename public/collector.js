@@ -26,9 +26,17 @@ class BehavioralMetricsCollector {
       reportInterval: options.reportInterval || 5000, // 5 seconds
       skipEmpty: options.skipEmpty !== false,
       address: options.address,
+      sessionAddress: options.sessionAddress,
+      challengeAddress: options.challengeAddress,
+      rawEvents: options.rawEvents || false,
+      maxEvents: options.maxEvents || 500,
+      keystrokes: options.keystrokes || false,
       sessionIdCookie: options.clientIdCookie || "bean-session"
     };
 
+    this.events = this.newEvents();
+    this.keystrokes = this.newKeystrokes();
+
     this.lastClickTime = null;
     this.lastScrollTime = null;
     this.lastTextInputTime = null;
@@ -113,10 +121,128 @@ class BehavioralMetricsCollector {
   }
 
   generateSessionCookie() {
-    const exists = document.cookie.split(';').find(it => it.trim().startsWith(this.options.sessionIdCookie));
-    if (!exists) {
+    const exists = document.cookie.split(';').find(it => it.trim().startsWith(this.options.sessionIdCookie + '='));
+    if (exists) {
+      return;
+    }
+    if (!this.options.sessionAddress) {
       document.cookie = this.options.sessionIdCookie + '=' + crypto.randomUUID() + "; path=/";
+      return;
+    }
+
+    // The server sets the signed session token cookie
+    this.sessionRequest = fetch(this.options.sessionAddress, { method: 'POST' })
+      .then(response => {
+        if (!response.ok) {
+          this.log('Failed to create session. Status:', response.status);
+        }
+      })
+      .catch(error => {
+        this.log('Error creating session:', error);
+      })
+      .finally(() => {
+        this.sessionRequest = null;
+      });
+  }
+
+  /**
+   * Return the session token from the session cookie, or an empty string
+   */
+  sessionToken() {
+    const cookie = document.cookie.split(';').find(it => it.trim().startsWith(this.options.sessionIdCookie + '='));
+    return cookie ? cookie.trim().substring(this.options.sessionIdCookie.length + 1) : '';
+  }
+
+  /**
+   * Return the next sequence number of the session reports.
+   * The counter is kept in localStorage, so it continues across the pages of the session.
+   */
+  nextSequence() {
+    const key = 'bean-seq:' + this.sessionToken();
+    let seq = (this.sequence || 0) + 1;
+    try {
+      seq = Math.max(seq, (parseInt(localStorage.getItem(key), 10) || 0) + 1);
+      localStorage.setItem(key, String(seq));
+    } catch (e) {
+      // Storage is unavailable, the counter is kept per page
     }
+    this.sequence = seq;
+    return seq;
+  }
+
+  /**
+   * Request a proof-of-work challenge for the session and solve it in a Web Worker.
+   * The server challenges every session once; only solutions of suspicious sessions change the score.
+   * Once the server responds with 204, the challenge is no longer requested on this page.
+   * Resolves to true if a challenge was solved.
+   */
+  solveChallenge() {
+    const token = this.sessionToken();
+    if (!this.options.challengeAddress || !token || this.challengeRunning || this.challengeDone || typeof Worker === 'undefined') {
+      return Promise.resolve(false);
+    }
+
+    const url = this.options.challengeAddress + '/' + encodeURIComponent(token);
+    this.challengeRunning = true;
+    return fetch(url)
+      .then(response => {
+        if (response.status === 204) {
+          this.challengeDone = true;
+        }
+        return response.status === 200 ? response.json() : null;
+      })
+      .then(challenge => {
+        if (!challenge) {
+          return false;
+        }
+        this.log('Solving challenge', challenge);
+        return this.runChallengeWorker(challenge)
+          .then(nonce => fetch(url, {
+            method: 'POST',
+            headers: {
+              'Content-Type': 'application/json'
+            },
+            body: JSON.stringify({ nonce: nonce })
+          }))
+          .then(response => {
+            this.log('Challenge solution sent. Status:', response.status);
+            this.challengeDone = response.ok;
+            return response.ok;
+          });
+      })
+      .catch(error => {
+        this.log('Error solving challenge:', error);
+        return false;
+      })
+      .finally(() => {
+        this.challengeRunning = false;
+      });
+  }
+
+  /**
+   * Find the nonce of the challenge in a Web Worker, so the page stays responsive
+   */
+  runChallengeWorker(challenge) {
+    const source = '(' + challengeSolver.toString() + ')()';
+    const workerUrl = URL.createObjectURL(new Blob([source], { type: 'text/javascript' }));
+    const worker = new Worker(workerUrl);
+    return new Promise((resolve, reject) => {
+      worker.onmessage = event => resolve(event.data);
+      worker.onerror = event => reject(event.message);
+      worker.postMessage({ seed: challenge.seed, difficulty: challenge.difficulty });
+    }).finally(() => {
+      worker.terminate();
+      URL.revokeObjectURL(workerUrl);
+    });
+  }
+
+  /**
+   * Drop the rejected session token and request a new one
+   */
+  renewSession() {
+    document.cookie = this.options.sessionIdCookie + '=; path=/; max-age=0';
+    this.sequence = 0;
+    this.generateSessionCookie();
   }
 
   /**
@@ -129,6 +255,12 @@ class BehavioralMetricsCollector {
     this.attachScrollListener();
     this.attachTextInputListener();
     this.attachVisibilityListener();
+    if (this.options.rawEvents) {
+      this.attachRawEventListeners();
+    }
+    if (this.options.keystrokes) {
+      this.attachKeystrokeListeners();
+    }
 
     if (this.options.reportInterval > 0) {
       this.startReportInterval();
@@ -146,6 +278,8 @@ class BehavioralMetricsCollector {
     this.removeScrollListener();
     this.removeTextInputListener();
     this.removeVisibilityListener();
+    this.removeRawEventListeners();
+    this.removeKeystrokeListeners();
     this.stopReportInterval();
 
     this.log('Metrics collection stopped');
@@ -181,6 +315,7 @@ class BehavioralMetricsCollector {
 
         this.lastMousePosition = currentPos;
         this.lastMouseMoveTime = now;
+        this.recordEvent('mouse', 0, currentPos.x, currentPos.y);
 
         throttleTimer = setTimeout(() => {
           throttleTimer = null;
@@ -332,6 +467,160 @@ class BehavioralMetricsCollector {
     }
   }
 
+  /**
+   * Create empty raw event streams
+   */
+  newEvents() {
+    return { mouse: [], touch: [], keys: [], truncated: false, last: {} };
+  }
+
+  /**
+   * Record a raw event as deltas from the previous event of the stream.
+   * Pointer events are encoded as [kind, dt, dx, dy], key events as [kind, dt];
+   * kinds: 0 — move, 1 — press, 2 — release. Key values are not recorded.
+   */
+  recordEvent(stream, kind, x = 0, y = 0) {
+    if (!this.options.rawEvents) {
+      return;
+    }
+
+    const events = this.events[stream];
+    const size = stream === 'keys' ? 2 : 4;
+    if (events.length / size >= this.options.maxEvents) {
+      this.events.truncated = true;
+      return;
+    }
+
+    const now = Date.now();
+    const last = this.events.last[stream] || { t: this.metrics.startTime, x: 0, y: 0 };
+    const position = { t: now, x: Math.round(x), y: Math.round(y) };
+    events.push(kind, Math.max(0, now - last.t));
+    if (size === 4) {
+      events.push(position.x - last.x, position.y - last.y);
+    }
+    this.events.last[stream] = position;
+  }
+
+  /**
+   * Attach listeners of the raw mouse button, touch and key events
+   */
+  attachRawEventListeners() {
+    this.rawEventHandlers = {
+      mousedown: event => this.recordEvent('mouse', 1, event.clientX, event.clientY),
+      mouseup: event => this.recordEvent('mouse', 2, event.clientX, event.clientY),
+      touchstart: event => this.recordTouch(1, event),
+      touchmove: event => this.recordTouch(0, event),
+      touchend: event => this.recordTouch(2, event),
+      keydown: event => {
+        if (!event.repeat) {
+          this.recordEvent('keys', 1);
+        }
+      },
+      keyup: () => this.recordEvent('keys', 2)
+    };
+
+    for (const [type, handler] of Object.entries(this.rawEventHandlers)) {
+      document.addEventListener(type, handler, { capture: true, passive: true });
+    }
+  }
+
+  /**
+   * Record an event of the primary touch
+   */
+  recordTouch(kind, event) {
+    const touch = event.changedTouches && event.changedTouches[0];
+    if (touch) {
+      this.recordEvent('touch', kind, touch.clientX, touch.clientY);
+    }
+  }
+
+  /**
+   * Remove listeners of the raw events
+   */
+  removeRawEventListeners() {
+    if (this.rawEventHandlers) {
+      for (const [type, handler] of Object.entries(this.rawEventHandlers)) {
+        document.removeEventListener(type, handler, { capture: true });
+      }
+      this.rawEventHandlers = null;
+    }
+  }
+
+  /**
+   * Create empty keystrokes. Keys still held are carried over from the previous keystrokes,
+   * so a key pressed before a report and released after it is not lost.
+   */
+  newKeystrokes(previous = null) {
+    const held = new Map(previous ? previous.held : []);
+    return { strokes: [...held.values()], held };
+  }
+
+  /**
+   * Attach listeners of the key presses and releases for keystroke dynamics.
+   * The key code only pairs a press with its release and is not sent;
+   * the only key property sent is whether it is Backspace or Delete.
+   */
+  attachKeystrokeListeners() {
+    this.keystrokeHandlers = {
+      keydown: event => {
+        const { strokes, held } = this.keystrokes;
+        if (event.repeat || held.has(event.code) || strokes.length >= this.options.maxEvents) {
+          return;
+        }
+        const stroke = {
+          down: performance.now(),
+          up: null,
+          backspace: event.key === 'Backspace' || event.key === 'Delete'
+        };
+        strokes.push(stroke);
+        held.set(event.code, stroke);
+      },
+      keyup: event => {
+        const stroke = this.keystrokes.held.get(event.code);
+        if (stroke) {
+          stroke.up = performance.now();
+          this.keystrokes.held.delete(event.code);
+        }
+      }
+    };
+
+    for (const [type, handler] of Object.entries(this.keystrokeHandlers)) {
+      document.addEventListener(type, handler, { capture: true, passive: true });
+    }
+  }
+
+  /**
+   * Remove listeners of the keystrokes
+   */
+  removeKeystrokeListeners() {
+    if (this.keystrokeHandlers) {
+      for (const [type, handler] of Object.entries(this.keystrokeHandlers)) {
+        document.removeEventListener(type, handler, { capture: true });
+      }
+      this.keystrokeHandlers = null;
+    }
+  }
+
+  /**
+   * Encode the released keystrokes of the report in the order of presses as [dwell, flight, backspace]:
+   * dwell — time the key was held, flight — time from the release of the previous key
+   * to the press (negative if the keys overlap, 0 for the first keystroke), backspace — 1 for Backspace
+   * and Delete, 0 otherwise. Times are in milliseconds. Key values are not sent.
+   */
+  encodeKeystrokes() {
+    const encoded = [];
+    let previous = null;
+    for (const stroke of this.keystrokes.strokes) {
+      if (stroke.up === null) {
+        continue;
+      }
+      const flight = previous ? stroke.down - previous.up : 0;
+      encoded.push(Math.round(stroke.up - stroke.down), Math.round(flight), stroke.backspace ? 1 : 0);
+      previous = stroke;
+    }
+    return encoded;
+  }
+
   /**
    * Start automatic reporting
    */
@@ -488,6 +777,8 @@ class BehavioralMetricsCollector {
       sessionDuration: 0,
       browser: this.metrics.browser
     };
+    this.events = this.newEvents();
+    this.keystrokes = this.newKeystrokes(this.keystrokes);
 
     this.lastClickTime = null;
     this.lastScrollTime = null;
@@ -507,6 +798,14 @@ class BehavioralMetricsCollector {
     }
   }
 
+  /**
+   * Return the raw event streams of the report
+   */
+  encodeEvents() {
+    const { mouse, touch, keys, truncated } = this.events;
+    return { mouse, touch, keys, truncated };
+  }
+
   /**
    * Send metrics to server as a flat object
    */
@@ -553,6 +852,14 @@ class BehavioralMetricsCollector {
       // Timestamp
       timestamp: new Date().toISOString(),
 
+      // Raw events, if enabled
+      events: this.options.rawEvents ? this.encodeEvents() : undefined,
+      keystrokes: this.options.keystrokes ? this.encodeKeystrokes() : undefined,
+
+      // Replay protection
+      seq: this.nextSequence(),
+      nonce: crypto.randomUUID(),
+
       // Browser and Device Info
       userAgent: browserInfo.userAgent,
       language: browserInfo.language,
@@ -585,8 +892,14 @@ class BehavioralMetricsCollector {
       .then(response => {
         if (response.ok) {
           this.log('Metrics sent successfully');
+          if (this.options.challengeAddress) {
+            this.solveChallenge();
+          }
         } else {
           this.log('Failed to send metrics. Status:', response.status);
+          if (response.status === 403 && this.options.sessionAddress && !this.sessionRequest) {
+            this.renewSession();
+          }
         }
       })
       .catch(error => {
@@ -598,6 +911,93 @@ class BehavioralMetricsCollector {
 
 }
 
+/**
+ * Proof-of-work solver running in a Web Worker.
+ * Finds a nonce such that SHA-256 of "<seed>:<nonce>" starts with the required number of zero bits.
+ * Self-contained, because its source is loaded into the worker.
+ */
+function challengeSolver() {
+  const K = new Uint32Array([
+    0x428a2f98, 0x71374491, 0xb5c0fbcf, 0xe9b5dba5, 0x3956c25b, 0x59f111f1, 0x923f82a4, 0xab1c5ed5,
+    0xd807aa98, 0x12835b01, 0x243185be, 0x550c7dc3, 0x72be5d74, 0x80deb1fe, 0x9bdc06a7, 0xc19bf174,
+    0xe49b69c1, 0xefbe4786, 0x0fc19dc6, 0x240ca1cc, 0x2de92c6f, 0x4a7484aa, 0x5cb0a9dc, 0x76f988da,
+    0x983e5152, 0xa831c66d, 0xb00327c8, 0xbf597fc7, 0xc6e00bf3, 0xd5a79147, 0x06ca6351, 0x14292967,
+    0x27b70a85, 0x2e1b2138, 0x4d2c6dfc, 0x53380d13, 0x650a7354, 0x766a0abb, 0x81c2c92e, 0x92722c85,
+    0xa2bfe8a1, 0xa81a664b, 0xc24b8b70, 0xc76c51a3, 0xd192e819, 0xd6990624, 0xf40e3585, 0x106aa070,
+    0x19a4c116, 0x1e376c08, 0x2748774c, 0x34b0bcb5, 0x391c0cb3, 0x4ed8aa4a, 0x5b9cca4f, 0x682e6ff3,
+    0x748f82ee, 0x78a5636f, 0x84c87814, 0x8cc70208, 0x90befffa, 0xa4506ceb, 0xbef9a3f7, 0xc67178f2
+  ]);
+  const W = new Uint32Array(64);
+  const H = new Uint32Array(8);
+
+  const rotr = (x, n) => (x >>> n) | (x << (32 - n));
+
+  // Hash of an ASCII string, the words are left in H
+  function sha256(text) {
+    const blocks = (text.length + 9 + 63) >> 6;
+    const bytes = new Uint8Array(blocks * 64);
+    for (let i = 0; i < text.length; i++) {
+      bytes[i] = text.charCodeAt(i);
+    }
+    bytes[text.length] = 0x80;
+    const bitLength = text.length * 8;
+    bytes[bytes.length - 4] = bitLength >>> 24;
+    bytes[bytes.length - 3] = bitLength >>> 16;
+    bytes[bytes.length - 2] = bitLength >>> 8;
+    bytes[bytes.length - 1] = bitLength;
+
+    H.set([0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a, 0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19]);
+    for (let offset = 0; offset < bytes.length; offset += 64) {
+      for (let i = 0; i < 16; i++) {
+        const j = offset + i * 4;
+        W[i] = (bytes[j] << 24) | (bytes[j + 1] << 16) | (bytes[j + 2] << 8) | bytes[j + 3];
+      }
+      for (let i = 16; i < 64; i++) {
+        const s0 = rotr(W[i - 15], 7) ^ rotr(W[i - 15], 18) ^ (W[i - 15] >>> 3);
+        const s1 = rotr(W[i - 2], 17) ^ rotr(W[i - 2], 19) ^ (W[i - 2] >>> 10);
+        W[i] = W[i - 16] + s0 + W[i - 7] + s1;
+      }
+
+      let a = H[0], b = H[1], c = H[2], d = H[3], e = H[4], f = H[5], g = H[6], h = H[7];
+      for (let i = 0; i < 64; i++) {
+        const t1 = (h + (rotr(e, 6) ^ rotr(e, 11) ^ rotr(e, 25)) + ((e & f) ^ (~e & g)) + K[i] + W[i]) | 0;
+        const t2 = ((rotr(a, 2) ^ rotr(a, 13) ^ rotr(a, 22)) + ((a & b) ^ (a & c) ^ (b & c))) | 0;
+        h = g;
+        g = f;
+        f = e;
+        e = (d + t1) | 0;
+        d = c;
+        c = b;
+        b = a;
+        a = (t1 + t2) | 0;
+      }
+      H[0] += a; H[1] += b; H[2] += c; H[3] += d; H[4] += e; H[5] += f; H[6] += g; H[7] += h;
+    }
+  }
+
+  function leadingZeroBits() {
+    let zeros = 0;
+    for (let i = 0; i < 8; i++) {
+      if (H[i] !== 0) {
+        return zeros + Math.clz32(H[i]);
+      }
+      zeros += 32;
+    }
+    return zeros;
+  }
+
+  self.onmessage = event => {
+    const { seed, difficulty } = event.data;
+    for (let nonce = 0; ; nonce++) {
+      sha256(seed + ':' + nonce);
+      if (leadingZeroBits() >= difficulty) {
+        self.postMessage(String(nonce));
+        return;
+      }
+    }
+  };
+}
+
 // Export for use as module or standalone
 if (typeof module !== 'undefined' && module.exports) {
   module.exports = BehavioralMetricsCollector;
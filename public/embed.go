@@ -0,0 +1,8 @@
+package public
+
+import _ "embed"
+
+// Collector is the behavioral metrics collector script embedded into the binary.
+//
+//go:embed collector.js
+var Collector []byte